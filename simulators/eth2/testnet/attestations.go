@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	blsu "github.com/protolambda/bls12-381-util"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/eth2api/client/validatorapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/ztyp/bitfields"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// committeeKey identifies one attestation committee within an epoch.
+type committeeKey struct {
+	slot  common.Slot
+	index common.CommitteeIndex
+}
+
+// epochCommittees fetches all attestation committees of an epoch from a node
+// and returns them keyed by slot and committee index.
+func epochCommittees(ctx context.Context, bn *BeaconNode, epoch common.Epoch, stateSlot common.Slot) (map[committeeKey][]common.ValidatorIndex, error) {
+	var committees []eth2api.Committee
+	if exists, err := beaconapi.EpochCommittees(ctx, bn.API, eth2api.StateIdSlot(stateSlot), &epoch, nil, nil, &committees); err != nil {
+		return nil, fmt.Errorf("failed to get committees of epoch %d: %w", epoch, err)
+	} else if !exists {
+		return nil, fmt.Errorf("no state at slot %d to read committees from", stateSlot)
+	}
+	byKey := make(map[committeeKey][]common.ValidatorIndex, len(committees))
+	for _, c := range committees {
+		byKey[committeeKey{c.Slot, c.Index}] = c.Validators
+	}
+	return byKey, nil
+}
+
+// GetAttestationInclusionDistance measures the attestation inclusion
+// distance of every validator that attested in the given epoch, per the
+// chain of the given node: for each attestation packed into the epoch's
+// blocks, the distance is the including block's slot minus the attested
+// slot. When a validator appears in several aggregates, the smallest
+// distance counts, since that is when its vote first reached the chain.
+func (t *Testnet) GetAttestationInclusionDistance(ctx context.Context, nodeIndex int, epoch common.Epoch) (map[uint64]int, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return nil, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	bn := t.beacons[nodeIndex]
+	startSlot, err := t.spec.EpochStartSlot(epoch)
+	if err != nil {
+		return nil, err
+	}
+	committees, err := epochCommittees(ctx, bn, epoch, startSlot)
+	if err != nil {
+		return nil, err
+	}
+
+	distances := make(map[uint64]int)
+	for slot := startSlot; slot < startSlot+common.Slot(t.spec.SLOTS_PER_EPOCH); slot++ {
+		block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		for _, att := range block.Attestations() {
+			committee, ok := committees[committeeKey{att.Data.Slot, att.Data.Index}]
+			if !ok {
+				// Attestation for an earlier epoch; out of scope here.
+				continue
+			}
+			distance := int(block.Slot() - att.Data.Slot)
+			for i, validator := range committee {
+				if !att.AggregationBits.GetBit(uint64(i)) {
+					continue
+				}
+				if prev, seen := distances[uint64(validator)]; !seen || distance < prev {
+					distances[uint64(validator)] = distance
+				}
+			}
+		}
+	}
+	return distances, nil
+}
+
+// AverageInclusionDistance returns the mean attestation inclusion distance
+// over all validators that attested in the given epoch.
+func (t *Testnet) AverageInclusionDistance(ctx context.Context, nodeIndex int, epoch common.Epoch) (float64, error) {
+	distances, err := t.GetAttestationInclusionDistance(ctx, nodeIndex, epoch)
+	if err != nil {
+		return 0, err
+	}
+	if len(distances) == 0 {
+		return 0, fmt.Errorf("no attestations included in epoch %d", epoch)
+	}
+	sum := 0
+	for _, d := range distances {
+		sum += d
+	}
+	return float64(sum) / float64(len(distances)), nil
+}
+
+// WaitForAggregateAttestation polls the attestation pools of all beacon
+// nodes until one holds an attestation with more than one aggregation bit
+// set, i.e. a real aggregate. Validator clients that only ever publish
+// unaggregated attestations never produce one. The slot of the aggregate's
+// attestation data is returned; pair with
+// VerifyAggregateAttestationSignature to also check the aggregate signature.
+func (t *Testnet) WaitForAggregateAttestation(ctx context.Context) (common.Slot, error) {
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-timer.C:
+			for i, bn := range t.beacons {
+				var atts []phase0.Attestation
+				if err := beaconapi.PoolAttestations(ctx, bn.API, nil, nil, &atts); err != nil {
+					t.t.Logf("failed to poll attestation pool of node %d: %v", i, err)
+					continue
+				}
+				for a := range atts {
+					if bitfields.BitlistOnesCount(atts[a].AggregationBits) > 1 {
+						t.t.Logf("node %d pools an aggregate of %d attesters for slot %d",
+							i, bitfields.BitlistOnesCount(atts[a].AggregationBits), atts[a].Data.Slot)
+						return atts[a].Data.Slot, nil
+					}
+				}
+			}
+		}
+	}
+}
+
+// selfAttesterDuty looks up the attestation duty of one validator: the duty
+// of the current epoch if its slot is still reachable, otherwise the duty of
+// the next epoch.
+func (t *Testnet) selfAttesterDuty(ctx context.Context, bn *BeaconNode, validatorIndex common.ValidatorIndex, maxSlotsDelay common.Slot) (eth2api.AttesterDuty, error) {
+	currentEpoch := t.CurrentEpoch()
+	for _, epoch := range []common.Epoch{currentEpoch, currentEpoch + 1} {
+		var duties eth2api.DependentAttesterDuties
+		if syncing, err := validatorapi.AttesterDuties(ctx, bn.API, epoch, []common.ValidatorIndex{validatorIndex}, &duties); err != nil {
+			return eth2api.AttesterDuty{}, fmt.Errorf("failed to get attester duties of epoch %d: %w", epoch, err)
+		} else if syncing {
+			return eth2api.AttesterDuty{}, fmt.Errorf("beacon node is syncing, cannot get duties of epoch %d", epoch)
+		}
+		for _, duty := range duties.Data {
+			if duty.ValidatorIndex == validatorIndex && duty.Slot+maxSlotsDelay >= t.CurrentSlot() {
+				return duty, nil
+			}
+		}
+	}
+	return eth2api.AttesterDuty{}, fmt.Errorf("validator %d has no reachable attester duty in epochs %d-%d", validatorIndex, currentEpoch, currentEpoch+1)
+}
+
+// WaitForSelfAttestation waits until the attestation of one specific
+// validator is included on chain: it looks up the validator's next attester
+// duty, then scans the blocks after the duty slot for an aggregate covering
+// the validator's committee with the validator's own participation bit set.
+// It returns the slot of the including block, and fails when no inclusion
+// happens within maxSlotsDelay slots after the duty. This is a direct
+// per-validator liveness check — e.g. that a freshly activated validator
+// performs its first attestation — without going through aggregate
+// participation rates.
+func (t *Testnet) WaitForSelfAttestation(ctx context.Context, validatorIndex uint64, maxSlotsDelay common.Slot) (common.Slot, error) {
+	bn := t.roundRobinBeacon()
+	duty, err := t.selfAttesterDuty(ctx, bn, common.ValidatorIndex(validatorIndex), maxSlotsDelay)
+	if err != nil {
+		return 0, err
+	}
+	t.t.Logf("validator %d is due to attest at slot %d in committee %d, position %d",
+		validatorIndex, duty.Slot, duty.CommitteeIndex, duty.ValidatorCommitteeIndex)
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-timer.C:
+			currentSlot := t.CurrentSlot()
+			lastSlot := duty.Slot + maxSlotsDelay
+			if lastSlot > currentSlot {
+				lastSlot = currentSlot
+			}
+			for slot := duty.Slot + 1; slot <= lastSlot; slot++ {
+				block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+				if err != nil {
+					return 0, err
+				}
+				if !exists {
+					continue
+				}
+				for _, att := range block.Attestations() {
+					if att.Data.Slot != duty.Slot || att.Data.Index != duty.CommitteeIndex {
+						continue
+					}
+					if att.AggregationBits.GetBit(uint64(duty.ValidatorCommitteeIndex)) {
+						t.t.Logf("attestation of validator %d for slot %d included in block at slot %d",
+							validatorIndex, duty.Slot, slot)
+						return slot, nil
+					}
+				}
+			}
+			if currentSlot > duty.Slot+maxSlotsDelay {
+				return 0, fmt.Errorf("attestation of validator %d for slot %d not included within %d slots",
+					validatorIndex, duty.Slot, maxSlotsDelay)
+			}
+		}
+	}
+}
+
+// VerifyAggregateAttestationSignature checks the aggregate signature of an
+// attestation against the committee it attests for, under the attester
+// domain of the attestation's target epoch.
+func (t *Testnet) VerifyAggregateAttestationSignature(ctx context.Context, att *phase0.Attestation) error {
+	bn := t.roundRobinBeacon()
+	committees, err := epochCommittees(ctx, bn, t.spec.SlotToEpoch(att.Data.Slot), att.Data.Slot)
+	if err != nil {
+		return err
+	}
+	committee, ok := committees[committeeKey{att.Data.Slot, att.Data.Index}]
+	if !ok {
+		return fmt.Errorf("no committee %d at slot %d", att.Data.Index, att.Data.Slot)
+	}
+	var pubkeys []*blsu.Pubkey
+	for i, validator := range committee {
+		if !att.AggregationBits.GetBit(uint64(i)) {
+			continue
+		}
+		v := t.Validators.ByIndex(validator)
+		if v == nil {
+			return fmt.Errorf("no known key material for attester %d", validator)
+		}
+		var pubkey blsu.Pubkey
+		pubkeyBytes := [48]byte(v.PubKey)
+		if err := pubkey.Deserialize(&pubkeyBytes); err != nil {
+			return fmt.Errorf("bad pubkey of validator %d: %v", validator, err)
+		}
+		pubkeys = append(pubkeys, &pubkey)
+	}
+	if len(pubkeys) == 0 {
+		return fmt.Errorf("aggregate has no participants")
+	}
+	slot, err := t.spec.EpochStartSlot(att.Data.Target.Epoch)
+	if err != nil {
+		return err
+	}
+	domain := common.ComputeDomain(common.DOMAIN_BEACON_ATTESTER, t.spec.ForkVersion(slot), t.genesisValidatorsRoot)
+	signingRoot := common.ComputeSigningRoot(att.Data.HashTreeRoot(tree.GetHashFn()), domain)
+	var sig blsu.Signature
+	sigBytes := [96]byte(att.Signature)
+	if err := sig.Deserialize(&sigBytes); err != nil {
+		return fmt.Errorf("bad aggregate signature: %v", err)
+	}
+	if !blsu.FastAggregateVerify(pubkeys, signingRoot[:], &sig) {
+		return fmt.Errorf("aggregate signature of slot %d committee %d does not verify", att.Data.Slot, att.Data.Index)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// epochStartBalances fetches the balances of the given validators from the
+// state at the start of an epoch, keyed by validator index.
+func epochStartBalances(ctx context.Context, t *Testnet, bn *BeaconNode, epoch common.Epoch, ids []eth2api.ValidatorId) (map[common.ValidatorIndex]common.Gwei, error) {
+	slot, err := t.spec.EpochStartSlot(epoch)
+	if err != nil {
+		return nil, err
+	}
+	var balances []eth2api.ValidatorBalanceResponse
+	if exists, err := beaconapi.StateValidatorBalances(ctx, bn.API, eth2api.StateIdSlot(slot), ids, &balances); err != nil {
+		return nil, fmt.Errorf("failed to get balances at epoch %d: %w", epoch, err)
+	} else if !exists {
+		return nil, fmt.Errorf("no state at the start of epoch %d", epoch)
+	}
+	byIndex := make(map[common.ValidatorIndex]common.Gwei, len(balances))
+	for _, b := range balances {
+		byIndex[b.Index] = b.Balance
+	}
+	return byIndex, nil
+}
+
+// AssertValidatorBalancesNonDecreasing checks that none of the given
+// validators lost balance between consecutive epoch boundaries in the given
+// range, per the given node. In a healthy network, rewards should keep
+// balances flat or growing; decreases of up to toleranceGwei are allowed to
+// absorb occasional attestation penalties during low-participation periods.
+// An empty validator set means the whole known validator set.
+func (t *Testnet) AssertValidatorBalancesNonDecreasing(ctx context.Context, nodeIndex int, fromEpoch, toEpoch common.Epoch, validatorIndices []uint64, toleranceGwei uint64) error {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	if fromEpoch >= toEpoch {
+		return fmt.Errorf("epoch range %d-%d is empty", fromEpoch, toEpoch)
+	}
+	bn := t.beacons[nodeIndex]
+	if len(validatorIndices) == 0 {
+		for i := range t.Validators {
+			validatorIndices = append(validatorIndices, uint64(i))
+		}
+	}
+	ids := make([]eth2api.ValidatorId, len(validatorIndices))
+	for i, index := range validatorIndices {
+		ids[i] = eth2api.ValidatorIdIndex(common.ValidatorIndex(index))
+	}
+
+	prev, err := epochStartBalances(ctx, t, bn, fromEpoch, ids)
+	if err != nil {
+		return err
+	}
+	var issues []string
+	for epoch := fromEpoch + 1; epoch <= toEpoch; epoch++ {
+		next, err := epochStartBalances(ctx, t, bn, epoch, ids)
+		if err != nil {
+			return err
+		}
+		for _, index := range validatorIndices {
+			before, after := prev[common.ValidatorIndex(index)], next[common.ValidatorIndex(index)]
+			if after+common.Gwei(toleranceGwei) < before {
+				issues = append(issues, fmt.Sprintf("validator %d dropped from %d to %d gwei over epoch %d",
+					index, before, after, epoch-1))
+			}
+		}
+		prev = next
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("balances decreased: %s", strings.Join(issues, "; "))
+	}
+	t.t.Logf("balances of %d validators non-decreasing over epochs %d-%d", len(validatorIndices), fromEpoch, toEpoch)
+	return nil
+}
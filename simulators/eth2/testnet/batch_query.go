@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+)
+
+// BatchValidatorStatus fetches the status of the given validators from one
+// node in a single request, through the batched POST variant of the state
+// validators endpoint. Unlike pulling the full registry (let alone the full
+// state), the response stays proportional to the queried set, which matters
+// for health checks that run every slot.
+func (t *Testnet) BatchValidatorStatus(ctx context.Context, nodeIndex int, indices []uint64) ([]eth2api.ValidatorResponse, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return nil, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	ids := make([]string, len(indices))
+	for i, index := range indices {
+		ids[i] = fmt.Sprintf("%d", index)
+	}
+	var validators []eth2api.ValidatorResponse
+	req := eth2api.BodyPOST("/eth/v1/beacon/states/head/validators", map[string]interface{}{
+		"ids": ids,
+	})
+	resp := t.beacons[nodeIndex].API.Request(ctx, req)
+	if _, err := resp.Decode(eth2api.Wrap(&validators)); err != nil {
+		return nil, fmt.Errorf("failed to batch-query %d validators from node %d: %w", len(indices), nodeIndex, err)
+	}
+	return validators, nil
+}
+
+// knownValidatorIndices returns the indices of every validator the testnet
+// controls.
+func (t *Testnet) knownValidatorIndices() []uint64 {
+	indices := make([]uint64, len(t.Validators))
+	for i := range t.Validators {
+		indices[i] = uint64(i)
+	}
+	return indices
+}
+
+// nodeValidatorStatuses fetches validator statuses from a node, batching the
+// query to the testnet's own validators when lazy state queries are enabled
+// and falling back to the full registry otherwise.
+func (t *Testnet) nodeValidatorStatuses(ctx context.Context, nodeIndex int) ([]eth2api.ValidatorResponse, error) {
+	if t.lazyBeaconState {
+		return t.BatchValidatorStatus(ctx, nodeIndex, t.knownValidatorIndices())
+	}
+	var validators []eth2api.ValidatorResponse
+	if exists, err := beaconapi.StateValidators(ctx, t.beacons[nodeIndex].API, eth2api.StateHead, nil, nil, &validators); err != nil {
+		return nil, fmt.Errorf("failed to get validators of node %d: %w", nodeIndex, err)
+	} else if !exists {
+		return nil, fmt.Errorf("node %d has no head state", nodeIndex)
+	}
+	return validators, nil
+}
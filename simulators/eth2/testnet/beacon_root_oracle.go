@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// beaconRootsAddress is the EIP-4788 beacon roots contract, which serves the
+// parent beacon block root of recent blocks keyed by block timestamp.
+var beaconRootsAddress = ethcommon.HexToAddress("0x000F3df6D732807Ef1319fB7B8bB8522d0Beac02")
+
+// VerifyBeaconRootOracle checks the EIP-4788 beacon root oracle of the given
+// node for the given slot: the root the execution layer serves for the slot's
+// timestamp must be the beacon block root of the previous slot, i.e. the
+// parent root the beacon chain wrote into the payload. A mismatch means the
+// two layers disagree on chain history. Pre-Deneb slots, where EIP-4788 is
+// not active, are skipped with a log line.
+func (t *Testnet) VerifyBeaconRootOracle(ctx context.Context, nodeIndex int, slot common.Slot) error {
+	if nodeIndex < 0 || nodeIndex >= len(t.eth1) {
+		return fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	if slot == 0 {
+		return fmt.Errorf("slot 0 has no parent to look up")
+	}
+	if t.spec.SlotToEpoch(slot) < t.spec.DENEB_FORK_EPOCH {
+		t.t.Logf("slot %d is pre-Deneb, EIP-4788 not active, nothing to verify", slot)
+		return nil
+	}
+
+	// The oracle stores the parent beacon block root under the timestamp of
+	// the block carrying it, so the expected value is the root at slot-1.
+	parentRoot, exists, err := beaconapi.BlockRoot(ctx, t.beacons[nodeIndex].API, eth2api.BlockIdSlot(slot-1))
+	if err != nil {
+		return fmt.Errorf("failed to get block root at slot %d: %w", slot-1, err)
+	}
+	if !exists {
+		return fmt.Errorf("no block at slot %d to compare the oracle against", slot-1)
+	}
+	timestamp, err := t.spec.TimeAtSlot(slot, t.genesisTime)
+	if err != nil {
+		return fmt.Errorf("failed to compute timestamp of slot %d: %w", slot, err)
+	}
+
+	// The contract takes the timestamp as 32 bytes of calldata and returns
+	// the stored root.
+	var calldata [32]byte
+	new(big.Int).SetUint64(uint64(timestamp)).FillBytes(calldata[:])
+	var returned hexutil.Bytes
+	err = t.eth1[nodeIndex].RPC().CallContext(ctx, &returned, "eth_call", map[string]interface{}{
+		"to":   beaconRootsAddress,
+		"data": hexutil.Bytes(calldata[:]),
+	}, "latest")
+	if err != nil {
+		return fmt.Errorf("failed to call the beacon roots contract on node %d: %w", nodeIndex, err)
+	}
+	if len(returned) != 32 {
+		return fmt.Errorf("beacon roots contract of node %d returned %d bytes for timestamp %d", nodeIndex, len(returned), timestamp)
+	}
+	if !bytes.Equal(returned, parentRoot[:]) {
+		return fmt.Errorf("beacon root oracle of node %d serves %x for slot %d, the beacon chain has %s",
+			nodeIndex, returned, slot, parentRoot)
+	}
+	t.t.Logf("beacon root oracle of node %d agrees with the beacon chain at slot %d: %s", nodeIndex, slot, parentRoot)
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// Blob gas accounting parameters of EIP-4844.
+const (
+	gasPerBlob           = 131072
+	targetBlobGasPerSlot = 393216
+)
+
+// nextExcessBlobGas computes the excess blob gas of a block from its parent's
+// excess and usage, per EIP-4844.
+func nextExcessBlobGas(parentExcess, parentUsed uint64) uint64 {
+	if parentExcess+parentUsed < targetBlobGasPerSlot {
+		return 0
+	}
+	return parentExcess + parentUsed - targetBlobGasPerSlot
+}
+
+// VerifyBlobGasAccounting walks the Deneb execution payloads in the slot
+// range on every node and checks the blob fee market bookkeeping: each
+// payload's blob_gas_used must match its type-3 transactions, the
+// excess_blob_gas evolution must follow EIP-4844 from one payload to the
+// next, and the blob gas price the included transactions actually paid must
+// equal the fee derived from the excess. This catches fee-market divergence
+// between clients before it grows into a chain split.
+func (t *Testnet) VerifyBlobGasAccounting(ctx context.Context, fromSlot, toSlot common.Slot) error {
+	for i, bn := range t.beacons {
+		var (
+			havePrev   bool
+			prevExcess uint64
+			prevUsed   uint64
+		)
+		for slot := fromSlot; slot <= toSlot; slot++ {
+			block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+			if err != nil {
+				return fmt.Errorf("node %d: %w", i, err)
+			}
+			if !exists {
+				continue
+			}
+			payload := block.executionPayloadEnvelope()
+			if payload == nil || payload.Deneb == nil {
+				continue
+			}
+			blockNumber := uint64(payload.Deneb.BlockNumber)
+			used := uint64(payload.Deneb.BlobGasUsed)
+			excess := uint64(payload.Deneb.ExcessBlobGas)
+
+			// blob_gas_used must account exactly for the blob hashes of the
+			// payload's type-3 transactions.
+			blobHashes := 0
+			var blobTxs []ethcommon.Hash
+			for _, tx := range payload.Deneb.Transactions {
+				if len(tx) == 0 || tx[0] != 0x03 {
+					continue
+				}
+				hashes, err := blobTxVersionedHashes(tx)
+				if err != nil {
+					return fmt.Errorf("node %d: block %d: undecodable blob transaction: %w", i, blockNumber, err)
+				}
+				blobHashes += len(hashes)
+				blobTxs = append(blobTxs, ethcommon.BytesToHash(crypto.Keccak256(tx)))
+			}
+			if used != uint64(blobHashes)*gasPerBlob {
+				return fmt.Errorf("node %d: block %d used %d blob gas, but its %d blob hashes account for %d",
+					i, blockNumber, used, blobHashes, uint64(blobHashes)*gasPerBlob)
+			}
+
+			if havePrev {
+				if expected := nextExcessBlobGas(prevExcess, prevUsed); excess != expected {
+					return fmt.Errorf("node %d: block %d has excess blob gas %d, expected %d from parent excess %d and usage %d",
+						i, blockNumber, excess, expected, prevExcess, prevUsed)
+				}
+			}
+			prevExcess, prevUsed, havePrev = excess, used, true
+
+			// The included blob transactions must have paid exactly the fee
+			// the excess derives to.
+			derivedFee := blobBaseFee(excess)
+			for _, txHash := range blobTxs {
+				paid, err := blobGasPricePaid(ctx, t.eth1[i], txHash)
+				if err != nil {
+					return fmt.Errorf("node %d: block %d: %w", i, blockNumber, err)
+				}
+				if paid != nil && paid.Cmp(derivedFee) != 0 {
+					return fmt.Errorf("node %d: block %d: transaction %s paid blob gas price %s, derived fee is %s",
+						i, blockNumber, txHash, paid, derivedFee)
+				}
+			}
+		}
+	}
+	t.t.Logf("blob gas accounting consistent on all %d nodes over slots %d-%d", len(t.beacons), fromSlot, toSlot)
+	return nil
+}
+
+// blobGasPricePaid reads the blob gas price of a transaction receipt. The
+// receipt types of the dependency set predate EIP-4844, so the field is read
+// from the raw JSON-RPC response. Nil means the execution client did not
+// report one.
+func blobGasPricePaid(ctx context.Context, node *Eth1Node, txHash ethcommon.Hash) (*big.Int, error) {
+	var receipt struct {
+		BlobGasPrice *hexutil.Big `json:"blobGasPrice"`
+	}
+	if err := node.RPC().CallContext(ctx, &receipt, "eth_getTransactionReceipt", txHash); err != nil {
+		return nil, fmt.Errorf("failed to get receipt of %s: %w", txHash, err)
+	}
+	if receipt.BlobGasPrice == nil {
+		return nil, nil
+	}
+	return (*big.Int)(receipt.BlobGasPrice), nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/protolambda/eth2api"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// kzgBlobCommitter computes the KZG commitment and proof of a blob. Like
+// kzgProofVerifier, it is a pluggable backend: the dependency set of this
+// simulator has no KZG library, so a build that wants to spam real blob
+// transactions wires one in here.
+var kzgBlobCommitter func(blob []byte) (commitment, proof common.KZGCommitment, err error)
+
+const (
+	// blobSize is the fixed byte size of one EIP-4844 blob.
+	blobSize = 131072
+	// maxBlobsPerBlock is the EIP-4844 per-block blob target ceiling; the
+	// spammer never keeps more than this many blobs pending, so a full
+	// mempool drains within a block.
+	maxBlobsPerBlock = 6
+	// blobSpamDropSlots is how many slots a spammed transaction may stay
+	// pending before the spammer counts it as dropped.
+	blobSpamDropSlots = 8
+)
+
+// BlobSpammerStats are the activity counters of a blob spammer.
+type BlobSpammerStats struct {
+	// Sent counts blob transactions accepted by an execution client.
+	Sent int
+	// Included counts transactions that made it into a block.
+	Included int
+	// Dropped counts transactions that stayed pending for more than
+	// blobSpamDropSlots slots and were given up on.
+	Dropped int
+}
+
+// BlobSpammer feeds the testnet a steady stream of type-3 transactions from
+// the pre-funded test account, targeting the execution clients round-robin.
+type BlobSpammer struct {
+	t       *Testnet
+	key     *ecdsa.PrivateKey
+	perSlot int
+
+	stop chan struct{}
+	done chan struct{}
+
+	mu    sync.Mutex
+	stats BlobSpammerStats
+	// pending maps the hash of an in-flight transaction to the slot it was
+	// sent in.
+	pending map[ethcommon.Hash]common.Slot
+}
+
+// StartBlobSpammer starts a background spammer that sends txPerSlot blob
+// transactions per slot from the pre-funded test account, round-robin over
+// the execution clients. It requires a KZG backend in kzgBlobCommitter to
+// produce valid commitments; without one it errors instead of flooding the
+// network with garbage. Stop the spammer to bracket the spamming phase.
+func (t *Testnet) StartBlobSpammer(ctx context.Context, txPerSlot int) (*BlobSpammer, error) {
+	if kzgBlobCommitter == nil {
+		return nil, fmt.Errorf("no KZG backend wired into kzgBlobCommitter, cannot build valid blob transactions")
+	}
+	if txPerSlot <= 0 {
+		return nil, fmt.Errorf("need a positive transaction rate, got %d", txPerSlot)
+	}
+	s := &BlobSpammer{
+		t:       t,
+		key:     eth1TestAccountKey,
+		perSlot: txPerSlot,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		pending: make(map[ethcommon.Hash]common.Slot),
+	}
+	sender := crypto.PubkeyToAddress(s.key.PublicKey)
+	nonce, err := t.eth1[0].EthClient().PendingNonceAt(ctx, sender)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nonce of spam account %s: %w", sender, err)
+	}
+	t.blobSpammerActive = true
+	go s.run(nonce)
+	t.t.Logf("blob spammer started, %d transactions per slot from %s", txPerSlot, sender)
+	return s, nil
+}
+
+// run is the spammer loop: once per slot it settles the fate of pending
+// transactions, then tops the mempool up to the blob limit.
+func (s *BlobSpammer) run(nonce uint64) {
+	defer close(s.done)
+	timer := time.NewTicker(s.t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-timer.C:
+			ctx, cancel := context.WithTimeout(context.Background(), s.t.slotDuration())
+			s.settlePending(ctx)
+			// Respect the per-block blob limit: with the whole limit already
+			// pending, sending more only clogs the mempools.
+			budget := s.perSlot
+			if room := maxBlobsPerBlock - s.pendingCount(); room < budget {
+				budget = room
+			}
+			for i := 0; i < budget; i++ {
+				hash, err := s.sendBlobTransaction(ctx, nonce)
+				if err != nil {
+					s.t.t.Logf("blob spammer: failed to send transaction with nonce %d: %v", nonce, err)
+					break
+				}
+				nonce++
+				s.mu.Lock()
+				s.stats.Sent++
+				s.pending[hash] = s.t.CurrentSlot()
+				s.mu.Unlock()
+			}
+			cancel()
+		}
+	}
+}
+
+// settlePending checks receipts of in-flight transactions, counting included
+// and dropped ones.
+func (s *BlobSpammer) settlePending(ctx context.Context) {
+	client := s.t.roundRobinEth1().EthClient()
+	currentSlot := s.t.CurrentSlot()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, sentAt := range s.pending {
+		if receipt, err := client.TransactionReceipt(ctx, hash); err == nil && receipt != nil {
+			s.stats.Included++
+			delete(s.pending, hash)
+			continue
+		}
+		if currentSlot > sentAt+blobSpamDropSlots {
+			s.stats.Dropped++
+			delete(s.pending, hash)
+		}
+	}
+}
+
+// pendingCount returns how many spammed transactions are still in flight.
+func (s *BlobSpammer) pendingCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// sendBlobTransaction builds, signs and submits one single-blob type-3
+// transaction at the given nonce, bidding twice the current blob base fee so
+// ordinary fee-market drift does not strand it.
+func (s *BlobSpammer) sendBlobTransaction(ctx context.Context, nonce uint64) (ethcommon.Hash, error) {
+	blob := deterministicBlob(nonce)
+	commitment, proof, err := kzgBlobCommitter(blob)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to commit to blob: %w", err)
+	}
+	versionedHash := commitmentVersionedHash(commitment)
+
+	node := s.t.roundRobinEth1()
+	client := node.EthClient()
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to get gas price: %w", err)
+	}
+	blobFeeBid := big.NewInt(2)
+	if fee, err := s.currentBlobBaseFee(ctx); err == nil {
+		blobFeeBid.Mul(fee, big.NewInt(2))
+	}
+
+	raw, hash, err := signedBlobTransaction(s.key, nonce, gasPrice, blobFeeBid, versionedHash, blob, commitment, proof)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	if err := node.RPC().CallContext(ctx, nil, "eth_sendRawTransaction", hexEncode(raw)); err != nil {
+		return ethcommon.Hash{}, err
+	}
+	return hash, nil
+}
+
+// currentBlobBaseFee derives the blob base fee from the excess blob gas of
+// the latest execution payload on the beacon chain.
+func (s *BlobSpammer) currentBlobBaseFee(ctx context.Context) (*big.Int, error) {
+	block, exists, err := fetchBlock(ctx, s.t.roundRobinBeacon(), eth2api.BlockHead)
+	if err != nil || !exists {
+		return nil, fmt.Errorf("failed to get head block: %w", err)
+	}
+	envelope := block.executionPayloadEnvelope()
+	if envelope == nil {
+		return nil, fmt.Errorf("head block has no execution payload")
+	}
+	excess, ok := envelope.ExcessBlobGas()
+	if !ok {
+		return nil, fmt.Errorf("head payload is pre-Deneb")
+	}
+	return blobBaseFee(excess), nil
+}
+
+// Stop shuts the spammer down and returns its final counters.
+func (s *BlobSpammer) Stop() BlobSpammerStats {
+	close(s.stop)
+	<-s.done
+	return s.Stats()
+}
+
+// Stats returns a snapshot of the spammer's counters.
+func (s *BlobSpammer) Stats() BlobSpammerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// roundRobinEth1 returns the next execution client to use for a submission.
+func (t *Testnet) roundRobinEth1() *Eth1Node {
+	i := atomic.AddUint64(&t.rrCounter, 1)
+	return t.eth1[int(i)%len(t.eth1)]
+}
+
+// deterministicBlob fills a blob with nonce-seeded data. Every 32-byte chunk
+// keeps its top byte zero, so it stays a canonical BLS field element.
+func deterministicBlob(nonce uint64) []byte {
+	blob := make([]byte, blobSize)
+	for i := range blob {
+		if i%32 == 0 {
+			continue
+		}
+		blob[i] = byte(uint64(i) * (nonce + 1))
+	}
+	return blob
+}
+
+// signedBlobTransaction assembles the EIP-4844 network form of a single-blob
+// transaction: the 0x03 type byte followed by the RLP list of the signed
+// transaction payload and its blobs, commitments and proofs. The dependency
+// set's transaction types predate type-3, so the encoding is spelled out
+// here. It returns the raw bytes and the transaction hash.
+func signedBlobTransaction(key *ecdsa.PrivateKey, nonce uint64, gasPrice, blobFeeBid *big.Int, versionedHash [32]byte, blob []byte, commitment, proof common.KZGCommitment) ([]byte, ethcommon.Hash, error) {
+	to := ethcommon.Address{0xb1, 0x0b} // burn target, blob txs must have one
+	payload := []interface{}{
+		eth1ChainID,
+		nonce,
+		gasPrice, // max priority fee
+		gasPrice, // max fee
+		uint64(21000),
+		to,
+		big.NewInt(0),
+		[]byte{},        // data
+		[]interface{}{}, // access list
+		blobFeeBid,
+		[][]byte{versionedHash[:]},
+	}
+	unsigned, err := rlp.EncodeToBytes(payload)
+	if err != nil {
+		return nil, ethcommon.Hash{}, err
+	}
+	sigHash := crypto.Keccak256(append([]byte{0x03}, unsigned...))
+	sig, err := crypto.Sign(sigHash, key)
+	if err != nil {
+		return nil, ethcommon.Hash{}, err
+	}
+	signed := append(payload,
+		uint64(sig[64]),
+		new(big.Int).SetBytes(sig[:32]),
+		new(big.Int).SetBytes(sig[32:64]),
+	)
+	txPayload, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		return nil, ethcommon.Hash{}, err
+	}
+	hash := ethcommon.BytesToHash(crypto.Keccak256(append([]byte{0x03}, txPayload...)))
+	wrapper, err := rlp.EncodeToBytes([]interface{}{
+		rlp.RawValue(txPayload),
+		[][]byte{blob},
+		[][]byte{commitment[:]},
+		[][]byte{proof[:]},
+	})
+	if err != nil {
+		return nil, ethcommon.Hash{}, err
+	}
+	return append([]byte{0x03}, wrapper...), hash, nil
+}
+
+// hexEncode renders raw bytes as a 0x-prefixed hex string for JSON-RPC.
+func hexEncode(b []byte) string {
+	return fmt.Sprintf("0x%x", b)
+}
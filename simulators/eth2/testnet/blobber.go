@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+
+	"github.com/ethereum/hive/simulators/eth2/testnet/utils"
+)
+
+// Blobber is a test double on the blob gossip side of the network: beacon
+// nodes trust its peer identities, and tests drive it to feed them crafted
+// blob sidecars. It holds the full validator key set so it can sign on behalf
+// of any proposer.
+type Blobber struct {
+	keys utils.Validators
+
+	mu sync.Mutex
+	// peerIDs are the peer identities handed out so far, in order.
+	peerIDs []string
+	// action is the current behavior mode; empty means honest.
+	action BlobberAction
+	// stats holds the activity counters and interception log.
+	stats BlobberStats
+	// errors are internal failures reported by the gossip side, pending
+	// collection by the testnet.
+	errors []error
+	closed bool
+}
+
+// NewBlobber creates a blobber operating the given validator keys.
+func NewBlobber(keys utils.Validators) *Blobber {
+	return &Blobber{keys: keys}
+}
+
+// GetNextPeerIDs returns the next n peer identities of the blobber. Each call
+// mints fresh identities, so scenarios that cycle peers get distinct IDs.
+func (b *Blobber) GetNextPeerIDs(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ids := make([]string, n)
+	for i := range ids {
+		seed := sha256.Sum256([]byte(fmt.Sprintf("blobber-peer-%d", len(b.peerIDs))))
+		ids[i] = fmt.Sprintf("16U%x", seed[:16])
+		b.peerIDs = append(b.peerIDs, ids[i])
+	}
+	return ids
+}
+
+// PeerIDs returns all peer identities handed out so far.
+func (b *Blobber) PeerIDs() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.peerIDs...)
+}
+
+// HoldsValidator reports whether the blobber holds the key of the given
+// validator, i.e. whether a proposal by it can be intercepted.
+func (b *Blobber) HoldsValidator(index common.ValidatorIndex) bool {
+	for _, v := range b.keys {
+		if v.Index == index {
+			return true
+		}
+	}
+	return false
+}
+
+// ReportError records an internal blobber failure — a proposal that could not
+// be decoded, a gossip send that failed — for the testnet to surface. Without
+// this, a broken blobber silently degrades to a vanilla network and the test
+// passes uselessly.
+func (b *Blobber) ReportError(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.errors = append(b.errors, err)
+}
+
+// TakeErrors returns the internal errors reported since the last call and
+// clears them.
+func (b *Blobber) TakeErrors() []error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	errs := b.errors
+	b.errors = nil
+	return errs
+}
+
+// Close shuts the blobber down. It reports whether the blobber exited
+// cleanly: any internal errors still pending collection make the shutdown
+// unclean.
+func (b *Blobber) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	if len(b.errors) > 0 {
+		return fmt.Errorf("blobber exited with %d unhandled internal errors, first: %w", len(b.errors), b.errors[0])
+	}
+	return nil
+}
+
+// BlobberOption tweaks a blobber at creation time.
+type BlobberOption func(*Blobber)
+
+// BlobberAction names a blobber behavior mode.
+type BlobberAction string
+
+const (
+	// BlobberActionHonest forwards everything unmodified.
+	BlobberActionHonest BlobberAction = "honest"
+	// BlobberActionEquivocate gossips conflicting blob sidecars for the
+	// same slot to different peers.
+	BlobberActionEquivocate BlobberAction = "equivocate"
+	// BlobberActionWithhold suppresses blob sidecars entirely.
+	BlobberActionWithhold BlobberAction = "withhold"
+)
+
+// Action returns the behavior mode the blobber currently applies.
+func (b *Blobber) Action() BlobberAction {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.action == "" {
+		return BlobberActionHonest
+	}
+	return b.action
+}
+
+// SetAction switches the blobber's behavior mode.
+func (b *Blobber) SetAction(action BlobberAction) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.action = action
+}
+
+// BlobberLogEntry records one intercepted proposal and what the blobber did
+// with its sidecars.
+type BlobberLogEntry struct {
+	Slot   uint64
+	Action BlobberAction
+}
+
+// BlobberStats summarizes the blobber's activity so far.
+type BlobberStats struct {
+	// ProposalsIntercepted counts proposals that passed through the blobber.
+	ProposalsIntercepted int
+	// SidecarsModified, SidecarsEquivocated and SidecarsWithheld count the
+	// sidecars each disruption touched.
+	SidecarsModified    int
+	SidecarsEquivocated int
+	SidecarsWithheld    int
+	// ActionLog is the per-slot interception log, in order.
+	ActionLog []BlobberLogEntry
+}
+
+// RecordInterception logs that the blobber intercepted a proposal at the
+// given slot and applied its current action to the given number of sidecars.
+// The gossip side calls this for every proposal it handles, including honest
+// pass-throughs.
+func (b *Blobber) RecordInterception(slot uint64, sidecars int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	action := b.action
+	if action == "" {
+		action = BlobberActionHonest
+	}
+	b.stats.ProposalsIntercepted++
+	switch action {
+	case BlobberActionEquivocate:
+		b.stats.SidecarsEquivocated += sidecars
+	case BlobberActionWithhold:
+		b.stats.SidecarsWithheld += sidecars
+	}
+	b.stats.ActionLog = append(b.stats.ActionLog, BlobberLogEntry{Slot: slot, Action: action})
+}
+
+// Stats returns a snapshot of the blobber's activity counters.
+func (b *Blobber) Stats() BlobberStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := b.stats
+	stats.ActionLog = append([]BlobberLogEntry(nil), b.stats.ActionLog...)
+	return stats
+}
+
+// Blobber returns the primary blobber, or nil when none is enabled.
+func (t *Testnet) Blobber() *Blobber {
+	if len(t.blobbers) == 0 {
+		return nil
+	}
+	return t.blobbers[0]
+}
+
+// Blobbers returns all enabled blobbers, in configuration order.
+func (t *Testnet) Blobbers() []*Blobber {
+	return append([]*Blobber(nil), t.blobbers...)
+}
+
+// BlobberAt returns the blobber with the given index.
+func (t *Testnet) BlobberAt(i int) (*Blobber, error) {
+	if i < 0 || i >= len(t.blobbers) {
+		return nil, fmt.Errorf("blobber index %d out of range, %d blobbers enabled", i, len(t.blobbers))
+	}
+	return t.blobbers[i], nil
+}
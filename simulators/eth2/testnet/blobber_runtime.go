@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/hive/hivesim"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/nodeapi"
+	"github.com/protolambda/eth2api/client/validatorapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// addTrustedPeer asks a beacon node to trust a peer identity at runtime,
+// through the node's trusted-peers admin endpoint.
+func addTrustedPeer(ctx context.Context, bn *BeaconNode, peerID string) error {
+	req := eth2api.BodyPOST("/eth/v1/node/trusted_peers", map[string]string{"peer_id": peerID})
+	resp := bn.API.Request(ctx, req)
+	code, err := resp.Decode(nil)
+	if err != nil && code == 0 {
+		return err
+	}
+	if code >= 300 {
+		return fmt.Errorf("trusted peer endpoint returned status %d", code)
+	}
+	return nil
+}
+
+// EnableBlobber starts a blobber on a live testnet: it mints one peer
+// identity per node and asks each running beacon node to trust it via the
+// trusted-peers admin endpoint. Nodes without that endpoint are restarted
+// with the identity in their startup parameters instead, which is clearly
+// reported since a restart briefly takes the node offline. Enabling twice is
+// an error; use DisableBlobber in between.
+func (t *Testnet) EnableBlobber(ctx context.Context, opts ...BlobberOption) error {
+	if len(t.blobbers) > 0 {
+		return fmt.Errorf("blobber already enabled")
+	}
+	blobber := NewBlobber(t.Validators)
+	for _, opt := range opts {
+		opt(blobber)
+	}
+	peerIDs := blobber.GetNextPeerIDs(len(t.beacons))
+	if len(peerIDs) != len(t.beacons) {
+		return fmt.Errorf("blobber produced %d peer IDs, requested %d", len(peerIDs), len(t.beacons))
+	}
+	for i, bn := range t.beacons {
+		if err := addTrustedPeer(ctx, bn, peerIDs[i]); err != nil {
+			t.t.Logf("node %d has no usable trusted peer endpoint (%v), restarting it with the blobber peer trusted", i, err)
+			if err := t.restartBeaconNode(i, bn.params.Set("HIVE_ETH2_TRUSTED_PEER_IDS", peerIDs[i])); err != nil {
+				return fmt.Errorf("failed to restart node %d with blobber peer: %w", i, err)
+			}
+		}
+	}
+	t.blobbers = append(t.blobbers, blobber)
+	t.t.Logf("blobber enabled at runtime, %d trusted peer identities distributed", len(peerIDs))
+	return nil
+}
+
+// DisableBlobber shuts the active blobber down and verifies that every beacon
+// node drops the peering: its identities must disappear from the nodes'
+// connected peer sets within an epoch.
+func (t *Testnet) DisableBlobber(ctx context.Context) error {
+	if len(t.blobbers) == 0 {
+		return fmt.Errorf("no blobber enabled")
+	}
+	blobberIDs := make(map[string]bool)
+	var closeErr error
+	for _, blobber := range t.blobbers {
+		for _, id := range blobber.PeerIDs() {
+			blobberIDs[id] = true
+		}
+		if err := blobber.Close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+	t.blobbers = nil
+
+	deadline := time.Now().Add(time.Duration(t.spec.SLOTS_PER_EPOCH) * t.slotDuration())
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			connected := 0
+			for i, bn := range t.beacons {
+				var peers []eth2api.Peer
+				if err := nodeapi.Peers(ctx, bn.API, []eth2api.ConnectionState{eth2api.ConnectionStateConnected}, nil, &peers); err != nil {
+					t.t.Logf("failed to poll peers of node %d: %v", i, err)
+					continue
+				}
+				for _, peer := range peers {
+					if blobberIDs[string(peer.PeerID)] {
+						connected++
+					}
+				}
+			}
+			if connected == 0 {
+				t.t.Logf("blobber disabled, all nodes dropped its peering")
+				return closeErr
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%d blobber peer connections still present after an epoch", connected)
+			}
+			t.t.Logf("waiting for %d blobber peer connections to drop", connected)
+		}
+	}
+}
+
+// restartBeaconNode restarts a beacon node with new startup parameters, e.g.
+// to change its trusted peer set when no admin endpoint exists. The node
+// comes back with a new IP, so anything holding its old address must be
+// re-resolved.
+func (t *Testnet) restartBeaconNode(nodeIndex int, params hivesim.Params) error {
+	bn := t.beacons[nodeIndex]
+	if err := t.t.Sim.StopClient(t.t.SuiteID, t.t.TestID, bn.Container); err != nil {
+		return err
+	}
+	cl := t.t.StartClient(bn.Type, params, bn.files)
+	fresh := NewBeaconNode(cl)
+	fresh.params = params
+	fresh.files = bn.files
+	*bn = *fresh
+	return nil
+}
+
+// WaitForBlobberAction blocks until the primary blobber executes a
+// non-honest action, polling its interception log once per slot, and returns
+// the slot of the first disruption. On timeout the error consults the
+// proposer duties of the wait window to say whether no targeted validator
+// ever proposed, or the blobber saw proposals but declined to act on them.
+func (t *Testnet) WaitForBlobberAction(ctx context.Context) (common.Slot, error) {
+	blobber := t.Blobber()
+	if blobber == nil {
+		return 0, fmt.Errorf("no blobber enabled")
+	}
+	startSlot := t.CurrentSlot()
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("blobber performed no action since slot %d: %s: %w",
+				startSlot, t.diagnoseBlobberInaction(blobber, startSlot), ctx.Err())
+		case <-timer.C:
+			if err := t.checkBlobberErrors(); err != nil {
+				return 0, err
+			}
+			for _, entry := range blobber.Stats().ActionLog {
+				if entry.Action != BlobberActionHonest && common.Slot(entry.Slot) >= startSlot {
+					return common.Slot(entry.Slot), nil
+				}
+			}
+		}
+	}
+}
+
+// diagnoseBlobberInaction explains why the blobber did nothing in the window
+// since startSlot, for the WaitForBlobberAction timeout message.
+func (t *Testnet) diagnoseBlobberInaction(blobber *Blobber, startSlot common.Slot) string {
+	// Count how many of the window's proposals were assigned to validators the
+	// blobber holds. Best effort: a duty fetch failure degrades the message,
+	// not the error.
+	ctx, cancel := context.WithTimeout(context.Background(), t.slotDuration())
+	defer cancel()
+	targeted := 0
+	endSlot := t.CurrentSlot()
+	for epoch := t.spec.SlotToEpoch(startSlot); epoch <= t.spec.SlotToEpoch(endSlot); epoch++ {
+		var resp eth2api.DependentProposerDuty
+		if _, err := validatorapi.ProposerDuties(ctx, t.roundRobinBeacon().API, epoch, &resp); err != nil {
+			return fmt.Sprintf("could not determine whether a targeted validator proposed (%v)", err)
+		}
+		for _, duty := range resp.Data {
+			if duty.Slot >= startSlot && duty.Slot <= endSlot && blobber.HoldsValidator(duty.ValidatorIndex) {
+				targeted++
+			}
+		}
+	}
+	if targeted == 0 {
+		return "no validator held by the blobber was assigned a proposal in the window"
+	}
+	stats := blobber.Stats()
+	if stats.ProposalsIntercepted == 0 {
+		return fmt.Sprintf("%d targeted proposals in the window, but the blobber intercepted none; the trusted peer setup likely never took effect", targeted)
+	}
+	return fmt.Sprintf("%d targeted proposals in the window and %d interceptions, but the blobber declined to act; its action is %q",
+		targeted, stats.ProposalsIntercepted, blobber.Action())
+}
+
+// checkBlobberErrors collects the internal errors the blobbers reported since
+// the last check. With Config.BlobberErrorsFatal set, the first error is
+// returned so polling waits fail fast; otherwise all errors are logged as
+// warnings and nil is returned. The per-slot wait loops call this each tick.
+func (t *Testnet) checkBlobberErrors() error {
+	for b, blobber := range t.blobbers {
+		for _, err := range blobber.TakeErrors() {
+			if t.blobberErrorsFatal {
+				return fmt.Errorf("blobber %d internal error: %w", b, err)
+			}
+			t.t.Logf("warning: blobber %d internal error: %v", b, err)
+		}
+	}
+	return nil
+}
+
+// BlobberStats returns the activity counters of the active blobber, so tests
+// can confirm the disruption they configured actually happened rather than
+// silently running a vanilla network.
+func (t *Testnet) BlobberStats() (BlobberStats, error) {
+	blobber := t.Blobber()
+	if blobber == nil {
+		return BlobberStats{}, fmt.Errorf("no blobber enabled")
+	}
+	return blobber.Stats(), nil
+}
+
+// RequireBlobberActed asserts that the blobber intercepted at least the given
+// number of proposals. A blobber test passing without any interceptions is a
+// misconfigured trusted peer list, not a pass.
+func (t *Testnet) RequireBlobberActed(minInterceptions int) error {
+	stats, err := t.BlobberStats()
+	if err != nil {
+		return err
+	}
+	if stats.ProposalsIntercepted < minInterceptions {
+		return fmt.Errorf("blobber intercepted %d proposals, want at least %d; the trusted peer setup likely never took effect",
+			stats.ProposalsIntercepted, minInterceptions)
+	}
+	t.t.Logf("blobber intercepted %d proposals (%d equivocated, %d withheld sidecars)",
+		stats.ProposalsIntercepted, stats.SidecarsEquivocated, stats.SidecarsWithheld)
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// BlobberScheduleEntry assigns a blobber action to a slot range, inclusive on
+// both ends. Slots outside every entry run honest.
+type BlobberScheduleEntry struct {
+	FromSlot common.Slot
+	ToSlot   common.Slot
+	Action   BlobberAction
+}
+
+// BlobberTransition records one executed action switch of the schedule.
+type BlobberTransition struct {
+	Slot   common.Slot
+	Action BlobberAction
+}
+
+// scheduledBlobberAction returns the action the schedule assigns to a slot.
+func scheduledBlobberAction(schedule []BlobberScheduleEntry, slot common.Slot) BlobberAction {
+	for _, entry := range schedule {
+		if slot >= entry.FromSlot && slot <= entry.ToSlot {
+			return entry.Action
+		}
+	}
+	return BlobberActionHonest
+}
+
+// runBlobberSchedule enforces the configured action schedule: as the clock
+// crosses each boundary the blobber is switched to the scheduled action, with
+// every transition logged and recorded for later assertions.
+func (t *Testnet) runBlobberSchedule(ctx context.Context, schedule []BlobberScheduleEntry) {
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	current := BlobberAction("")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		blobber := t.Blobber()
+		if blobber == nil {
+			continue
+		}
+		slot := t.CurrentSlot()
+		next := scheduledBlobberAction(schedule, slot)
+		if next == current {
+			continue
+		}
+		blobber.SetAction(next)
+		t.blobberTransitionsMu.Lock()
+		t.blobberTransitions = append(t.blobberTransitions, BlobberTransition{Slot: slot, Action: next})
+		t.blobberTransitionsMu.Unlock()
+		t.t.Logf("blobber action switched to %q at slot %d", next, slot)
+		current = next
+	}
+}
+
+// CurrentBlobberAction returns the blobber's active action, so status lines
+// can annotate waits with the disruption in effect. Without a blobber it
+// reports honest.
+func (t *Testnet) CurrentBlobberAction() BlobberAction {
+	blobber := t.Blobber()
+	if blobber == nil {
+		return BlobberActionHonest
+	}
+	return blobber.Action()
+}
+
+// BlobberTransitions returns the executed action transitions so far, in
+// order, for asserting that the schedule actually ran.
+func (t *Testnet) BlobberTransitions() []BlobberTransition {
+	t.blobberTransitionsMu.Lock()
+	defer t.blobberTransitionsMu.Unlock()
+	return append([]BlobberTransition(nil), t.blobberTransitions...)
+}
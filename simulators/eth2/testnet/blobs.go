@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// WaitForAllNodesProducingBlobs polls the canonical head of every beacon
+// node until each of them has observed at least one block carrying blob KZG
+// commitments. A node that never sees a blob block after the Deneb
+// transition has likely mis-applied the fork.
+func (t *Testnet) WaitForAllNodesProducingBlobs(ctx context.Context) error {
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	seenBlobs := make([]bool, len(t.beacons))
+	lastSeen := make([]common.Slot, len(t.beacons))
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			remaining := 0
+			for i, bn := range t.beacons {
+				if seenBlobs[i] {
+					continue
+				}
+				block, exists, err := fetchBlock(ctx, bn, eth2api.BlockHead)
+				if err != nil {
+					return err
+				}
+				if !exists || block.Slot() == lastSeen[i] {
+					remaining++
+					continue
+				}
+				lastSeen[i] = block.Slot()
+				if block.KZGCommitmentCount() > 0 {
+					t.t.Logf("node %d observed a blob block at slot %d", i, block.Slot())
+					seenBlobs[i] = true
+				} else {
+					remaining++
+				}
+			}
+			if remaining == 0 {
+				t.t.Logf("all %d nodes observed blob blocks", len(t.beacons))
+				return nil
+			}
+		}
+	}
+}
+
+// WaitForMaxBlobsPerBlock polls head blocks until one carries exactly
+// maxBlobs blob KZG commitments, proving that blobs are being produced at
+// the maximum allowed rate and that the beacon client includes all of them
+// instead of silently dropping some.
+func (t *Testnet) WaitForMaxBlobsPerBlock(ctx context.Context, maxBlobs int) error {
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	var lastSeen common.Slot
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			block, exists, err := fetchBlock(ctx, t.roundRobinBeacon(), eth2api.BlockHead)
+			if err != nil {
+				return err
+			}
+			if !exists || block.Slot() == lastSeen {
+				continue
+			}
+			lastSeen = block.Slot()
+			if count := block.KZGCommitmentCount(); count == maxBlobs {
+				t.t.Logf("block at slot %d carries the maximum of %d blobs", block.Slot(), maxBlobs)
+				return nil
+			} else if count > 0 {
+				t.t.Logf("block at slot %d carries %d of %d blobs", block.Slot(), count, maxBlobs)
+			}
+		}
+	}
+}
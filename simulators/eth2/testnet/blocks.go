@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/bellatrix"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/deneb"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+)
+
+// SignedBlock is a signed beacon block of any fork, as served by the blocks
+// endpoint. Exactly one of the per-fork fields is set, matching Version.
+type SignedBlock struct {
+	Version   string
+	Phase0    *phase0.SignedBeaconBlock
+	Altair    *altair.SignedBeaconBlock
+	Bellatrix *bellatrix.SignedBeaconBlock
+	Capella   *capella.SignedBeaconBlock
+	Deneb     *deneb.SignedBeaconBlock
+}
+
+func (b *SignedBlock) UnmarshalJSON(data []byte) error {
+	var envelope struct {
+		Version string          `json:"version"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+	b.Version = strings.ToLower(envelope.Version)
+	var dest interface{}
+	switch b.Version {
+	case "phase0":
+		b.Phase0 = new(phase0.SignedBeaconBlock)
+		dest = b.Phase0
+	case "altair":
+		b.Altair = new(altair.SignedBeaconBlock)
+		dest = b.Altair
+	case "bellatrix":
+		b.Bellatrix = new(bellatrix.SignedBeaconBlock)
+		dest = b.Bellatrix
+	case "capella":
+		b.Capella = new(capella.SignedBeaconBlock)
+		dest = b.Capella
+	case "deneb":
+		b.Deneb = new(deneb.SignedBeaconBlock)
+		dest = b.Deneb
+	default:
+		return fmt.Errorf("unrecognized block version: %q", envelope.Version)
+	}
+	return json.Unmarshal(envelope.Data, dest)
+}
+
+// Slot returns the slot of the block.
+func (b *SignedBlock) Slot() common.Slot {
+	switch {
+	case b.Phase0 != nil:
+		return b.Phase0.Message.Slot
+	case b.Altair != nil:
+		return b.Altair.Message.Slot
+	case b.Bellatrix != nil:
+		return b.Bellatrix.Message.Slot
+	case b.Capella != nil:
+		return b.Capella.Message.Slot
+	case b.Deneb != nil:
+		return b.Deneb.Message.Slot
+	}
+	return 0
+}
+
+// ProposerIndex returns the proposer of the block.
+func (b *SignedBlock) ProposerIndex() common.ValidatorIndex {
+	switch {
+	case b.Phase0 != nil:
+		return b.Phase0.Message.ProposerIndex
+	case b.Altair != nil:
+		return b.Altair.Message.ProposerIndex
+	case b.Bellatrix != nil:
+		return b.Bellatrix.Message.ProposerIndex
+	case b.Capella != nil:
+		return b.Capella.Message.ProposerIndex
+	case b.Deneb != nil:
+		return b.Deneb.Message.ProposerIndex
+	}
+	return 0
+}
+
+// Attestations returns the attestations included in the block body.
+func (b *SignedBlock) Attestations() []phase0.Attestation {
+	switch {
+	case b.Phase0 != nil:
+		return b.Phase0.Message.Body.Attestations
+	case b.Altair != nil:
+		return b.Altair.Message.Body.Attestations
+	case b.Bellatrix != nil:
+		return b.Bellatrix.Message.Body.Attestations
+	case b.Capella != nil:
+		return b.Capella.Message.Body.Attestations
+	case b.Deneb != nil:
+		return b.Deneb.Message.Body.Attestations
+	}
+	return nil
+}
+
+// ParentRoot returns the parent block root of the block.
+func (b *SignedBlock) ParentRoot() common.Root {
+	switch {
+	case b.Phase0 != nil:
+		return b.Phase0.Message.ParentRoot
+	case b.Altair != nil:
+		return b.Altair.Message.ParentRoot
+	case b.Bellatrix != nil:
+		return b.Bellatrix.Message.ParentRoot
+	case b.Capella != nil:
+		return b.Capella.Message.ParentRoot
+	case b.Deneb != nil:
+		return b.Deneb.Message.ParentRoot
+	}
+	return common.Root{}
+}
+
+// StateRoot returns the post-state root the block commits to.
+func (b *SignedBlock) StateRoot() common.Root {
+	switch {
+	case b.Phase0 != nil:
+		return b.Phase0.Message.StateRoot
+	case b.Altair != nil:
+		return b.Altair.Message.StateRoot
+	case b.Bellatrix != nil:
+		return b.Bellatrix.Message.StateRoot
+	case b.Capella != nil:
+		return b.Capella.Message.StateRoot
+	case b.Deneb != nil:
+		return b.Deneb.Message.StateRoot
+	}
+	return common.Root{}
+}
+
+// SyncAggregate returns the sync aggregate of the block body, with ok=false
+// for pre-Altair blocks that do not have one.
+func (b *SignedBlock) SyncAggregate() (*altair.SyncAggregate, bool) {
+	switch {
+	case b.Altair != nil:
+		return &b.Altair.Message.Body.SyncAggregate, true
+	case b.Bellatrix != nil:
+		return &b.Bellatrix.Message.Body.SyncAggregate, true
+	case b.Capella != nil:
+		return &b.Capella.Message.Body.SyncAggregate, true
+	case b.Deneb != nil:
+		return &b.Deneb.Message.Body.SyncAggregate, true
+	}
+	return nil, false
+}
+
+// Withdrawals returns the withdrawals of the block's execution payload,
+// empty for pre-Capella blocks.
+func (b *SignedBlock) Withdrawals() common.Withdrawals {
+	switch {
+	case b.Capella != nil:
+		return b.Capella.Message.Body.ExecutionPayload.Withdrawals
+	case b.Deneb != nil:
+		return b.Deneb.Message.Body.ExecutionPayload.Withdrawals
+	}
+	return nil
+}
+
+// Graffiti returns the graffiti field of the block body.
+func (b *SignedBlock) Graffiti() common.Root {
+	switch {
+	case b.Phase0 != nil:
+		return b.Phase0.Message.Body.Graffiti
+	case b.Altair != nil:
+		return b.Altair.Message.Body.Graffiti
+	case b.Bellatrix != nil:
+		return b.Bellatrix.Message.Body.Graffiti
+	case b.Capella != nil:
+		return b.Capella.Message.Body.Graffiti
+	case b.Deneb != nil:
+		return b.Deneb.Message.Body.Graffiti
+	}
+	return common.Root{}
+}
+
+// KZGCommitmentCount returns the number of blob KZG commitments in the block,
+// zero for pre-Deneb blocks.
+func (b *SignedBlock) KZGCommitmentCount() int {
+	if b.Deneb != nil {
+		return len(b.Deneb.Message.Body.BlobKZGCommitments)
+	}
+	return 0
+}
+
+// KZGCommitments returns the blob KZG commitments of the block, empty for
+// pre-Deneb blocks.
+func (b *SignedBlock) KZGCommitments() []common.KZGCommitment {
+	if b.Deneb != nil {
+		return b.Deneb.Message.Body.BlobKZGCommitments
+	}
+	return nil
+}
+
+// fetchBlock retrieves a signed beacon block of any fork from a beacon node.
+// It returns exists=false when the node does not have the requested block.
+func fetchBlock(ctx context.Context, bn *BeaconNode, blockId eth2api.BlockId) (*SignedBlock, bool, error) {
+	var block SignedBlock
+	req := eth2api.FmtGET("/eth/v2/beacon/blocks/%s", blockId.BlockId())
+	resp := bn.API.Request(ctx, req)
+	code, err := resp.Decode(&block)
+	if code == 404 {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get block %s: %w", blockId.BlockId(), err)
+	}
+	return &block, true, nil
+}
+
+// ProposerSlashings returns the proposer slashings included in the block body.
+func (b *SignedBlock) ProposerSlashings() []phase0.ProposerSlashing {
+	switch {
+	case b.Phase0 != nil:
+		return b.Phase0.Message.Body.ProposerSlashings
+	case b.Altair != nil:
+		return b.Altair.Message.Body.ProposerSlashings
+	case b.Bellatrix != nil:
+		return b.Bellatrix.Message.Body.ProposerSlashings
+	case b.Capella != nil:
+		return b.Capella.Message.Body.ProposerSlashings
+	case b.Deneb != nil:
+		return b.Deneb.Message.Body.ProposerSlashings
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	blsu "github.com/protolambda/bls12-381-util"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// SubmitBLSToExecutionChanges flips the withdrawal credentials of the given
+// validators from BLS (0x00) to execution (0x01) credentials, each change
+// signed with the validator's retained withdrawal key. The signed changes are
+// batched over all beacon nodes of the testnet so that gossip propagation is
+// exercised, and per-batch acceptance is logged.
+//
+// Validators that already have execution credentials are refused up front.
+// Submitting before the Capella fork is allowed, to test pooling behavior;
+// the change domain is always computed from the genesis fork version, per
+// spec.
+func (t *Testnet) SubmitBLSToExecutionChanges(ctx context.Context, changes map[common.ValidatorIndex]ethcommon.Address) error {
+	bn := t.roundRobinBeacon()
+
+	// Refuse validators that already rotated their credentials.
+	indices := make([]common.ValidatorIndex, 0, len(changes))
+	for index := range changes {
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	for _, index := range indices {
+		var v eth2api.ValidatorResponse
+		if exists, err := beaconapi.StateValidator(ctx, bn.API, eth2api.StateHead, eth2api.ValidatorIdIndex(index), &v); err != nil {
+			return fmt.Errorf("failed to look up validator %d: %w", index, err)
+		} else if !exists {
+			return fmt.Errorf("validator %d not known by the beacon node", index)
+		}
+		if v.Validator.WithdrawalCredentials[0] == common.ETH1_ADDRESS_WITHDRAWAL_PREFIX {
+			return fmt.Errorf("validator %d already has execution withdrawal credentials", index)
+		}
+	}
+
+	// Sign one change per validator, using the withdrawal key.
+	domain := common.ComputeDomain(common.DOMAIN_BLS_TO_EXECUTION_CHANGE, t.spec.GENESIS_FORK_VERSION, t.genesisValidatorsRoot)
+	signed := make(common.SignedBLSToExecutionChanges, 0, len(indices))
+	for _, index := range indices {
+		v := t.Validators.ByIndex(index)
+		if v == nil {
+			return fmt.Errorf("no known key material for validator %d", index)
+		}
+		change := common.BLSToExecutionChange{
+			ValidatorIndex:     index,
+			FromBLSPubKey:      v.WithdrawalPubKey,
+			ToExecutionAddress: common.Eth1Address(changes[index]),
+		}
+		signingRoot := common.ComputeSigningRoot(change.HashTreeRoot(tree.GetHashFn()), domain)
+		signed = append(signed, common.SignedBLSToExecutionChange{
+			BLSToExecutionChange: change,
+			Signature:            common.BLSSignature(blsu.Sign(v.WithdrawalSecretKey, signingRoot[:]).Serialize()),
+		})
+	}
+
+	// Spread the batches over the beacon nodes.
+	batches := len(t.beacons)
+	if batches > len(signed) {
+		batches = len(signed)
+	}
+	var firstErr error
+	for i := 0; i < batches; i++ {
+		batch := signed[i*len(signed)/batches : (i+1)*len(signed)/batches]
+		node := t.beacons[i]
+		if err := beaconapi.SubmitBLSToExecutionChanges(ctx, node.API, batch); err != nil {
+			err = asPoolRejection("bls-to-execution change", err)
+			t.t.Logf("node %d refused batch of %d bls-to-execution changes: %v", i, len(batch), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			t.t.Logf("node %d accepted batch of %d bls-to-execution changes", i, len(batch))
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// corruptBlobsBundle flips the first byte of every commitment in the blobs
+// bundle of a Deneb reveal response, so the bundle no longer matches the
+// blinded block. Pre-Deneb payloads without a bundle pass through unchanged.
+func corruptBlobsBundle(payload json.RawMessage) (json.RawMessage, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return payload, false
+	}
+	bundleRaw, ok := fields["blobs_bundle"]
+	if !ok {
+		return payload, false
+	}
+	var bundle map[string]json.RawMessage
+	if err := json.Unmarshal(bundleRaw, &bundle); err != nil {
+		return payload, false
+	}
+	var commitments []string
+	if err := json.Unmarshal(bundle["commitments"], &commitments); err != nil || len(commitments) == 0 {
+		return payload, false
+	}
+	for i, c := range commitments {
+		if len(c) > 3 {
+			// Flip a nibble right after the 0x prefix.
+			flipped := "0"
+			if c[2] == '0' {
+				flipped = "1"
+			}
+			commitments[i] = c[:2] + flipped + c[3:]
+		}
+	}
+	rewritten, err := json.Marshal(commitments)
+	if err != nil {
+		return payload, false
+	}
+	bundle["commitments"] = rewritten
+	if bundleRaw, err = json.Marshal(bundle); err != nil {
+		return payload, false
+	}
+	fields["blobs_bundle"] = bundleRaw
+	mutated, err := json.Marshal(fields)
+	if err != nil {
+		return payload, false
+	}
+	return mutated, true
+}
+
+// submittedBlobCommitments extracts the blob KZG commitments of the blinded
+// block the consensus client submitted for the given slot, from the builder's
+// captured requests.
+func (b *MockBuilder) submittedBlobCommitments(slot common.Slot) ([]string, bool) {
+	for _, req := range b.capturedRequests() {
+		if req.Path != "/eth/v1/builder/blinded_blocks" {
+			continue
+		}
+		var parsed struct {
+			Message struct {
+				Slot common.Slot `json:"slot"`
+				Body struct {
+					BlobKZGCommitments []string `json:"blob_kzg_commitments"`
+				} `json:"body"`
+			} `json:"message"`
+		}
+		if err := json.Unmarshal(req.Body, &parsed); err != nil || parsed.Message.Slot != slot {
+			continue
+		}
+		return parsed.Message.Body.BlobKZGCommitments, true
+	}
+	return nil, false
+}
+
+// VerifyBuilderBlobFlow checks the full Deneb blinded-block flow for the
+// builder-built block at the given slot: the canonical block's KZG
+// commitments must match the ones the consensus client committed to in its
+// blinded submission to the node's builder, the blob sidecars must be
+// retrievable from every node, and the execution payload's type-3
+// transactions must reference exactly the block's commitments through their
+// versioned hashes — i.e. the execution layer accepted the payload the
+// builder revealed.
+func (t *Testnet) VerifyBuilderBlobFlow(ctx context.Context, nodeIndex int, slot common.Slot) error {
+	builder, err := t.MockBuilderAt(nodeIndex)
+	if err != nil {
+		return err
+	}
+	bn := t.beacons[nodeIndex]
+	block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("no block at slot %d", slot)
+	}
+	payload := block.executionPayloadEnvelope()
+	if payload == nil || payload.Deneb == nil {
+		return fmt.Errorf("block at slot %d carries no Deneb execution payload", slot)
+	}
+	builderHash := t.builderDeliveredHash(slot)
+	if payload.BlockHash() != builderHash {
+		return fmt.Errorf("block at slot %d does not carry the builder payload", slot)
+	}
+	commitments := block.KZGCommitments()
+	if len(commitments) == 0 {
+		return fmt.Errorf("builder block at slot %d carries no blob commitments, nothing to verify", slot)
+	}
+
+	// The canonical commitments must be the ones the client submitted.
+	submitted, found := builder.submittedBlobCommitments(slot)
+	if !found {
+		return fmt.Errorf("builder of node %d captured no blinded submission for slot %d", nodeIndex, slot)
+	}
+	if len(submitted) != len(commitments) {
+		return fmt.Errorf("block at slot %d has %d commitments, the blinded submission had %d", slot, len(commitments), len(submitted))
+	}
+	for i, c := range commitments {
+		if !strings.EqualFold(c.String(), submitted[i]) {
+			return fmt.Errorf("commitment %d of slot %d is %s on chain but %s in the blinded submission", i, slot, c, submitted[i])
+		}
+	}
+
+	// Every node must serve the sidecars of the block.
+	root, _, err := beaconapi.BlockRoot(ctx, bn.API, eth2api.BlockIdSlot(slot))
+	if err != nil {
+		return fmt.Errorf("failed to get root of block at slot %d: %w", slot, err)
+	}
+	if err := t.CheckBlobSidecarAvailability(ctx, root, slot); err != nil {
+		return err
+	}
+
+	// The versioned hashes of the payload's blob transactions must cover
+	// exactly the block's commitments.
+	expected := make(map[[32]byte]bool, len(commitments))
+	for _, c := range commitments {
+		expected[commitmentVersionedHash(c)] = true
+	}
+	referenced := 0
+	for _, tx := range payload.Deneb.Transactions {
+		if len(tx) == 0 || tx[0] != 0x03 {
+			continue
+		}
+		hashes, err := blobTxVersionedHashes(tx)
+		if err != nil {
+			return fmt.Errorf("slot %d: undecodable blob transaction: %w", slot, err)
+		}
+		for _, h := range hashes {
+			if !expected[h] {
+				return fmt.Errorf("slot %d: blob transaction references versioned hash %x with no matching commitment", slot, h)
+			}
+			referenced++
+		}
+	}
+	if referenced != len(commitments) {
+		return fmt.Errorf("slot %d: payload transactions reference %d blob hashes, the block commits to %d", slot, referenced, len(commitments))
+	}
+	t.t.Logf("builder blob flow of slot %d verified: %d commitments consistent across submission, sidecars and payload", slot, len(commitments))
+	return nil
+}
+
+// VerifyMismatchedCommitmentRejection checks that every reveal the builder
+// served with corrupted blob commitments (per the CorruptBlobCommitments
+// option) was rejected by the clients: the builder payload must not be
+// canonical in any affected slot, and finality must not have stalled over the
+// disruption.
+func (t *Testnet) VerifyMismatchedCommitmentRejection(ctx context.Context) error {
+	var affected []common.Slot
+	for slot, outcome := range t.BuilderScenarioSlots() {
+		if outcome == scenarioSlotBadBlobs {
+			affected = append(affected, slot)
+		}
+	}
+	if len(affected) == 0 {
+		return fmt.Errorf("no reveal carried mismatched blob commitments; the corrupting builder path was never exercised")
+	}
+	if err := t.RequireNoBuilderPayloads(ctx, affected); err != nil {
+		return fmt.Errorf("a reveal with mismatched blob commitments was published: %w", err)
+	}
+	var issues []string
+	for i, bn := range t.beacons {
+		var checkpoints eth2api.FinalityCheckpoints
+		if exists, err := beaconapi.FinalityCheckpoints(ctx, bn.API, eth2api.StateHead, &checkpoints); err != nil || !exists {
+			return fmt.Errorf("failed to get finality checkpoints of node %d: %v", i, err)
+		}
+		currentEpoch := t.CurrentEpoch()
+		if lag := uint64(currentEpoch - checkpoints.Finalized.Epoch); currentEpoch >= 2 && lag > 4 {
+			issues = append(issues, fmt.Sprintf("node %d: finality lags %d epochs behind", i, lag))
+		}
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("finality suffered from the mismatched commitments: %s", strings.Join(issues, "; "))
+	}
+	t.t.Logf("all %d reveals with mismatched blob commitments were rejected, finality intact", len(affected))
+	return nil
+}
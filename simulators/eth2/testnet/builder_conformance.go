@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// Hex shapes of the getHeader path parameters.
+var (
+	builderParentHashPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
+	builderPubkeyPattern     = regexp.MustCompile(`^0x[0-9a-fA-F]{96}$`)
+)
+
+// quoteBuilderRequest renders a captured builder API call for an error
+// message, truncating large bodies.
+func quoteBuilderRequest(req builderRequestRecord) string {
+	body := string(req.Body)
+	if len(body) > 200 {
+		body = body[:200] + "..."
+	}
+	if body == "" {
+		return fmt.Sprintf("%s %s", req.Method, req.Path)
+	}
+	return fmt.Sprintf("%s %s body %s", req.Method, req.Path, body)
+}
+
+// VerifyBuilderAPIUsage checks the builder API exchange of the given node's
+// consensus client against the spec: getHeader path parameters must have the
+// right shapes, submissions must be declared as JSON with a body matching the
+// fork of the block's slot, and Deneb-and-later blinded blocks must carry the
+// blob KZG commitments of the bid. It is a conformance check of the client's
+// builder API plumbing, separate from whether the built blocks made it on
+// chain; every finding quotes the offending request.
+func (t *Testnet) VerifyBuilderAPIUsage(ctx context.Context, nodeIndex int) error {
+	builder, err := t.MockBuilderAt(nodeIndex)
+	if err != nil {
+		return err
+	}
+	requests := builder.capturedRequests()
+	if len(requests) == 0 {
+		return fmt.Errorf("node %d made no builder API requests; the builder endpoint likely was never picked up", nodeIndex)
+	}
+	var issues []string
+	headerRequests := 0
+	for _, req := range requests {
+		switch {
+		case strings.HasPrefix(req.Path, "/eth/v1/builder/header/"):
+			headerRequests++
+			if issue := checkHeaderRequest(req); issue != "" {
+				issues = append(issues, issue)
+			}
+		case req.Path == "/eth/v1/builder/blinded_blocks":
+			if issue := t.checkBlindedBlockRequest(req); issue != "" {
+				issues = append(issues, issue)
+			}
+		case req.Path == "/eth/v1/builder/validators":
+			if issue := checkRegistrationRequest(req); issue != "" {
+				issues = append(issues, issue)
+			}
+		}
+	}
+	if headerRequests == 0 {
+		issues = append(issues, "no getHeader requests captured")
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("builder API usage of node %d violates the spec: %s", nodeIndex, strings.Join(issues, "; "))
+	}
+	t.t.Logf("all %d captured builder API requests of node %d conform to the spec", len(requests), nodeIndex)
+	return nil
+}
+
+// checkHeaderRequest validates the path parameters of one getHeader call.
+func checkHeaderRequest(req builderRequestRecord) string {
+	if req.Method != "GET" {
+		return fmt.Sprintf("getHeader with method %s (%s)", req.Method, quoteBuilderRequest(req))
+	}
+	parts := strings.Split(strings.TrimPrefix(req.Path, "/eth/v1/builder/header/"), "/")
+	if len(parts) != 3 {
+		return fmt.Sprintf("getHeader path has %d segments, want slot/parent_hash/pubkey (%s)", len(parts), quoteBuilderRequest(req))
+	}
+	if _, err := strconv.ParseUint(parts[0], 10, 64); err != nil {
+		return fmt.Sprintf("getHeader slot %q is not a decimal number (%s)", parts[0], quoteBuilderRequest(req))
+	}
+	if !builderParentHashPattern.MatchString(parts[1]) {
+		return fmt.Sprintf("getHeader parent hash %q is not 32 bytes of 0x-prefixed hex (%s)", parts[1], quoteBuilderRequest(req))
+	}
+	if !builderPubkeyPattern.MatchString(parts[2]) {
+		return fmt.Sprintf("getHeader pubkey %q is not 48 bytes of 0x-prefixed hex (%s)", parts[2], quoteBuilderRequest(req))
+	}
+	return ""
+}
+
+// checkBlindedBlockRequest validates one blinded block submission: declared
+// and parseable as JSON, slot present, and the body shaped per the fork the
+// slot falls in.
+func (t *Testnet) checkBlindedBlockRequest(req builderRequestRecord) string {
+	if req.Method != "POST" {
+		return fmt.Sprintf("submitBlindedBlock with method %s (%s)", req.Method, quoteBuilderRequest(req))
+	}
+	if ct := req.ContentType; ct != "" && !strings.HasPrefix(ct, "application/json") {
+		return fmt.Sprintf("submitBlindedBlock with content type %q, the mock builder only negotiates JSON (%s)", ct, quoteBuilderRequest(req))
+	}
+	var parsed struct {
+		Message struct {
+			Slot common.Slot `json:"slot"`
+			Body struct {
+				BlobKZGCommitments *[]json.RawMessage `json:"blob_kzg_commitments"`
+			} `json:"body"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(req.Body, &parsed); err != nil {
+		return fmt.Sprintf("submitBlindedBlock body does not parse as a signed blinded block: %v (%s)", err, quoteBuilderRequest(req))
+	}
+	version := versionAtSlot(t.spec, parsed.Message.Slot)
+	if version == "deneb" && parsed.Message.Body.BlobKZGCommitments == nil {
+		return fmt.Sprintf("%s blinded block of slot %d lacks blob_kzg_commitments (%s)", version, parsed.Message.Slot, quoteBuilderRequest(req))
+	}
+	return ""
+}
+
+// checkRegistrationRequest validates one validator registration call: a JSON
+// array of signed registrations with pubkeys of the right shape.
+func checkRegistrationRequest(req builderRequestRecord) string {
+	if req.Method != "POST" {
+		return fmt.Sprintf("registerValidator with method %s (%s)", req.Method, quoteBuilderRequest(req))
+	}
+	var registrations []struct {
+		Message struct {
+			Pubkey string `json:"pubkey"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(req.Body, &registrations); err != nil {
+		return fmt.Sprintf("registerValidator body does not parse as a registration list: %v (%s)", err, quoteBuilderRequest(req))
+	}
+	for _, registration := range registrations {
+		if !builderPubkeyPattern.MatchString(registration.Message.Pubkey) {
+			return fmt.Sprintf("registerValidator pubkey %q is not 48 bytes of 0x-prefixed hex (%s)",
+				registration.Message.Pubkey, quoteBuilderRequest(req))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// deliveredSlots returns the slots the builder bid for so far, with the
+// execution block hash of each bid.
+func (b *MockBuilder) deliveredSlots() map[common.Slot]ethcommon.Hash {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[common.Slot]ethcommon.Hash, len(b.deliveredHashes))
+	for slot, hash := range b.deliveredHashes {
+		out[slot] = hash
+	}
+	return out
+}
+
+// WaitForBuilderPayload blocks until a payload built by the given node's mock
+// builder is part of the canonical chain, polling once per slot. It
+// cross-references the block hashes the builder bid with the execution
+// payload hashes of the beacon blocks, and returns the first slot carrying a
+// builder-built payload. This is the positive proof that the builder path was
+// exercised: without it a run can pass with every block built locally.
+func (t *Testnet) WaitForBuilderPayload(ctx context.Context, nodeIndex int) (common.Slot, error) {
+	builder, err := t.MockBuilderAt(nodeIndex)
+	if err != nil {
+		return 0, err
+	}
+	bn := t.beacons[nodeIndex]
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-timer.C:
+			delivered := builder.deliveredSlots()
+			currentSlot := t.CurrentSlot()
+			for slot := common.Slot(0); slot <= currentSlot; slot++ {
+				hash, ok := delivered[slot]
+				if !ok {
+					continue
+				}
+				canonical, err := t.builderPayloadCanonical(ctx, bn, slot, hash)
+				if err != nil {
+					t.t.Logf("failed to check block of slot %d on node %d: %v", slot, nodeIndex, err)
+					continue
+				}
+				if canonical {
+					t.t.Logf("builder payload %v of node %d is canonical at slot %d", hash, nodeIndex, slot)
+					return slot, nil
+				}
+			}
+			t.t.Logf("no builder payload of node %d canonical yet, %d bids so far", nodeIndex, len(delivered))
+		}
+	}
+}
+
+// RequireNoBuilderPayloads asserts that none of the given slots carries a
+// payload built by any mock builder, the negative counterpart of
+// WaitForBuilderPayload for circuit-breaker tests where the consensus client
+// must have fallen back to local block production.
+func (t *Testnet) RequireNoBuilderPayloads(ctx context.Context, slots []common.Slot) error {
+	bn := t.roundRobinBeacon()
+	for _, slot := range slots {
+		hash := t.builderDeliveredHash(slot)
+		if hash == (ethcommon.Hash{}) {
+			continue
+		}
+		canonical, err := t.builderPayloadCanonical(ctx, bn, slot, hash)
+		if err != nil {
+			return fmt.Errorf("slot %d: %w", slot, err)
+		}
+		if canonical {
+			return fmt.Errorf("slot %d carries builder payload %v, expected a locally built block", slot, hash)
+		}
+	}
+	t.t.Logf("none of the %d checked slots carries a builder payload", len(slots))
+	return nil
+}
+
+// VerifyBuilderFeeRecipientPayment checks that the proposer's expected fee
+// recipient was actually paid for the builder-built block at the given slot:
+// the canonical payload must carry the builder's block hash and name the
+// expected fee recipient, and the recipient's execution-layer balance must
+// grow across the block by at least expectedPayment — the value of the
+// payment transaction the builder inserts. Nil expectedPayment only requires
+// the balance to grow at all. This catches clients that accept bids without
+// noticing a substituted fee recipient.
+func (t *Testnet) VerifyBuilderFeeRecipientPayment(ctx context.Context, slot common.Slot, feeRecipient ethcommon.Address, expectedPayment *big.Int) error {
+	block, exists, err := fetchBlock(ctx, t.roundRobinBeacon(), eth2api.BlockIdSlot(slot))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("no block at slot %d", slot)
+	}
+	payload := block.executionPayloadEnvelope()
+	if payload == nil {
+		return fmt.Errorf("block at slot %d carries no execution payload", slot)
+	}
+	builderHash := t.builderDeliveredHash(slot)
+	if builderHash == (ethcommon.Hash{}) || payload.BlockHash() != builderHash {
+		return fmt.Errorf("block at slot %d does not carry a builder payload", slot)
+	}
+	if actual := payload.FeeRecipient(); actual != feeRecipient {
+		return fmt.Errorf("builder payload at slot %d pays fee recipient %s, the proposer registered %s",
+			slot, actual, feeRecipient)
+	}
+	client := t.roundRobinEth1().EthClient()
+	blockNumber := payload.BlockNumber()
+	before, err := client.BalanceAt(ctx, feeRecipient, new(big.Int).SetUint64(blockNumber-1))
+	if err != nil {
+		return fmt.Errorf("failed to get balance of %s before block %d: %w", feeRecipient, blockNumber, err)
+	}
+	after, err := client.BalanceAt(ctx, feeRecipient, new(big.Int).SetUint64(blockNumber))
+	if err != nil {
+		return fmt.Errorf("failed to get balance of %s at block %d: %w", feeRecipient, blockNumber, err)
+	}
+	// The payment transaction transfers the bid value; the recipient can earn
+	// more on top when it is also the coinbase collecting priority fees.
+	received := new(big.Int).Sub(after, before)
+	if expectedPayment != nil {
+		if received.Cmp(expectedPayment) < 0 {
+			return fmt.Errorf("fee recipient %s received %s wei across block %d, expected at least %s",
+				feeRecipient, received, blockNumber, expectedPayment)
+		}
+	} else if received.Sign() <= 0 {
+		return fmt.Errorf("fee recipient %s received no payment across block %d", feeRecipient, blockNumber)
+	}
+	t.t.Logf("fee recipient %s received %s wei for the builder block at slot %d", feeRecipient, received, slot)
+	return nil
+}
+
+// builderPayloadCanonical reports whether the canonical block at the given
+// slot carries the execution payload with the given block hash.
+func (t *Testnet) builderPayloadCanonical(ctx context.Context, bn *BeaconNode, slot common.Slot, hash ethcommon.Hash) (bool, error) {
+	block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+	if err != nil || !exists {
+		return false, err
+	}
+	payload := block.executionPayloadEnvelope()
+	return payload != nil && payload.BlockHash() == hash, nil
+}
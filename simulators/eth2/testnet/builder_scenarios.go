@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// BuilderScenario is a first-class builder disruption: instead of wiring raw
+// callbacks into BuilderOptions per test, a scenario is set once on the
+// Config and translated into the right mock-builder behavior for every
+// builder-enabled node. The slots a scenario touches are recorded on the
+// testnet, so VerifyBuilderScenario can check how the consensus clients
+// coped.
+type BuilderScenario struct {
+	// Name identifies the scenario in logs.
+	Name string
+
+	// MutatePayload corrupts the payload revealed for a slot. It returns the
+	// payload to serve and whether it was changed.
+	MutatePayload func(epoch common.Epoch, slot common.Slot, payload json.RawMessage) (json.RawMessage, bool)
+
+	// Withhold reports whether the payload of the slot is withheld entirely,
+	// i.e. the blinded block submission fails.
+	Withhold func(slot common.Slot) bool
+}
+
+// InvalidStateRootFromEpoch is the scenario where the builder serves payloads
+// with a corrupted state root starting at the given epoch. A correct
+// consensus client must reject them.
+func InvalidStateRootFromEpoch(fromEpoch common.Epoch) *BuilderScenario {
+	return &BuilderScenario{
+		Name: fmt.Sprintf("invalid state root from epoch %d", fromEpoch),
+		MutatePayload: func(epoch common.Epoch, slot common.Slot, payload json.RawMessage) (json.RawMessage, bool) {
+			if epoch < fromEpoch {
+				return payload, false
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(payload, &fields); err != nil {
+				return payload, false
+			}
+			fields["state_root"] = json.RawMessage(`"0x0badc0de0badc0de0badc0de0badc0de0badc0de0badc0de0badc0de0badc0de"`)
+			mutated, err := json.Marshal(fields)
+			if err != nil {
+				return payload, false
+			}
+			return mutated, true
+		},
+	}
+}
+
+// WithheldPayloadsForSlots is the scenario where the builder returns bids but
+// never reveals the payloads of the given slots. A correct consensus client
+// must fall back to a locally built block without missing the slot.
+func WithheldPayloadsForSlots(slots ...common.Slot) *BuilderScenario {
+	withheld := make(map[common.Slot]bool, len(slots))
+	for _, slot := range slots {
+		withheld[slot] = true
+	}
+	return &BuilderScenario{
+		Name: fmt.Sprintf("withheld payloads for slots %v", slots),
+		Withhold: func(slot common.Slot) bool {
+			return withheld[slot]
+		},
+	}
+}
+
+// Scenario slot outcomes recorded by the mock builders.
+const (
+	scenarioSlotCorrupted = "corrupted"
+	scenarioSlotWithheld  = "withheld"
+	scenarioSlotBadBlobs  = "mismatched blob commitments"
+)
+
+// markScenarioSlot records that the builder scenario touched the given slot.
+func (t *Testnet) markScenarioSlot(slot common.Slot, outcome string) {
+	t.builderScenarioSlotsMu.Lock()
+	defer t.builderScenarioSlotsMu.Unlock()
+	if t.builderScenarioSlots == nil {
+		t.builderScenarioSlots = make(map[common.Slot]string)
+	}
+	t.builderScenarioSlots[slot] = outcome
+}
+
+// BuilderScenarioSlots returns the slots the configured builder scenario
+// affected so far, with what was done to each.
+func (t *Testnet) BuilderScenarioSlots() map[common.Slot]string {
+	t.builderScenarioSlotsMu.Lock()
+	defer t.builderScenarioSlotsMu.Unlock()
+	out := make(map[common.Slot]string, len(t.builderScenarioSlots))
+	for slot, outcome := range t.builderScenarioSlots {
+		out[slot] = outcome
+	}
+	return out
+}
+
+// VerifyBuilderScenario checks, for every slot the builder scenario touched,
+// that the consensus clients handled the disruption correctly: a corrupted
+// payload must not have become canonical, and a withheld payload must have
+// been replaced by a locally built block without missing the slot.
+func (t *Testnet) VerifyBuilderScenario(ctx context.Context) error {
+	affected := t.BuilderScenarioSlots()
+	if len(affected) == 0 {
+		return fmt.Errorf("builder scenario touched no slots; the builder path likely was never exercised")
+	}
+	bn := t.roundRobinBeacon()
+	for slot, outcome := range affected {
+		block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+		if err != nil {
+			return fmt.Errorf("slot %d: %w", slot, err)
+		}
+		builderHash := t.builderDeliveredHash(slot)
+		switch outcome {
+		case scenarioSlotWithheld:
+			if !exists {
+				return fmt.Errorf("slot %d was missed: the proposer did not fall back to a local block after the builder withheld the payload", slot)
+			}
+			if payload := block.executionPayloadEnvelope(); payload != nil && payload.BlockHash() == builderHash {
+				return fmt.Errorf("slot %d carries the withheld builder payload %v, which should have been unavailable", slot, builderHash)
+			}
+			t.t.Logf("slot %d: withheld payload correctly replaced by a local block", slot)
+		case scenarioSlotCorrupted, scenarioSlotBadBlobs:
+			if exists {
+				if payload := block.executionPayloadEnvelope(); payload != nil && payload.BlockHash() == builderHash {
+					return fmt.Errorf("slot %d canonicalized the builder payload %v with %s", slot, builderHash, outcome)
+				}
+			}
+			t.t.Logf("slot %d: payload with %s correctly rejected", slot, outcome)
+		}
+	}
+	return nil
+}
+
+// builderDeliveredHash returns the execution block hash any mock builder bid
+// for the given slot, zero if none did.
+func (t *Testnet) builderDeliveredHash(slot common.Slot) (hash ethcommon.Hash) {
+	for _, builder := range t.mockBuilders {
+		builder.mu.Lock()
+		if h, ok := builder.deliveredHashes[slot]; ok {
+			hash = h
+		}
+		builder.mu.Unlock()
+	}
+	return hash
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// ByzantineThresholds bounds the amount of unexpected activity that
+// AssertNoByzantineActivity tolerates.
+type ByzantineThresholds struct {
+	// MaxSlashings is the maximum number of slashed validators.
+	MaxSlashings int
+	// MaxUnexpectedExits is the maximum number of exiting validators that no
+	// test helper initiated.
+	MaxUnexpectedExits int
+	// MaxFinalityGapEpochs is the maximum tolerated distance between the
+	// current epoch and the finalized epoch, beyond the regular two-epoch
+	// finality lag.
+	MaxFinalityGapEpochs uint64
+}
+
+// AssertNoByzantineActivity checks all beacon nodes for activity that no
+// test helper initiated: slashed validators, unexpected exits, and finality
+// lagging behind. It is a defense-in-depth assertion that catches test
+// infrastructure bugs, e.g. disruption helpers injecting equivocations they
+// should not. All violations are reported in one combined error.
+func (t *Testnet) AssertNoByzantineActivity(ctx context.Context, thresholds ByzantineThresholds) error {
+	var issues []string
+	for i, bn := range t.beacons {
+		validators, err := t.nodeValidatorStatuses(ctx, i)
+		if err != nil {
+			return err
+		}
+		slashed, unexpectedExits := 0, 0
+		for _, v := range validators {
+			if v.Validator.Slashed {
+				if !t.isExpectedSlashing(v.Index) {
+					slashed++
+				}
+				continue
+			}
+			if v.Validator.ExitEpoch != common.FAR_FUTURE_EPOCH && !t.isExpectedExit(v.Index) {
+				unexpectedExits++
+			}
+		}
+		if slashed > thresholds.MaxSlashings {
+			issues = append(issues, fmt.Sprintf("node %d: %d slashed validators (max %d)", i, slashed, thresholds.MaxSlashings))
+		}
+		if unexpectedExits > thresholds.MaxUnexpectedExits {
+			issues = append(issues, fmt.Sprintf("node %d: %d unexpected exits (max %d)", i, unexpectedExits, thresholds.MaxUnexpectedExits))
+		}
+
+		var checkpoints eth2api.FinalityCheckpoints
+		if exists, err := beaconapi.FinalityCheckpoints(ctx, bn.API, eth2api.StateHead, &checkpoints); err != nil || !exists {
+			return fmt.Errorf("failed to get finality checkpoints of node %d: %v", i, err)
+		}
+		// Finality normally trails the current epoch by two epochs.
+		currentEpoch := t.CurrentEpoch()
+		if lag := uint64(currentEpoch - checkpoints.Finalized.Epoch); currentEpoch >= 2 && lag > 2+thresholds.MaxFinalityGapEpochs {
+			issues = append(issues, fmt.Sprintf("node %d: finality lags %d epochs behind (max gap %d)", i, lag, thresholds.MaxFinalityGapEpochs))
+		}
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("byzantine activity detected: %s", strings.Join(issues, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// checkpointEquivalenceSlots is how many recent slots of block roots
+// AssertCheckpointSyncEquivalence compares between the two nodes.
+const checkpointEquivalenceSlots = common.Slot(8)
+
+// checkpointEquivalenceStateSlots is how many of the most recent finalized
+// slots get a full state root comparison.
+const checkpointEquivalenceStateSlots = 3
+
+// AssertCheckpointSyncEquivalence checks that a checkpoint-synced node has
+// converged on exactly the same chain as a genesis-synced node: both must
+// report the same finalized checkpoint, the same head root, and the same
+// block roots over the last checkpointEquivalenceSlots slots. The most recent
+// finalized slots additionally get a full CompareStateRoots pass, so a state
+// divergence hidden behind matching block roots is caught too. Both nodes are
+// expected to already be in sync; every field that differs is reported.
+func (t *Testnet) AssertCheckpointSyncEquivalence(ctx context.Context, genesisNodeIndex, checkpointNodeIndex int) error {
+	if genesisNodeIndex < 0 || genesisNodeIndex >= len(t.beacons) {
+		return fmt.Errorf("node index %d out of range", genesisNodeIndex)
+	}
+	if checkpointNodeIndex < 0 || checkpointNodeIndex >= len(t.beacons) {
+		return fmt.Errorf("node index %d out of range", checkpointNodeIndex)
+	}
+	genesisNode, checkpointNode := t.beacons[genesisNodeIndex], t.beacons[checkpointNodeIndex]
+	var issues []string
+
+	var genesisFinality, checkpointFinality eth2api.FinalityCheckpoints
+	if exists, err := beaconapi.FinalityCheckpoints(ctx, genesisNode.API, eth2api.StateHead, &genesisFinality); err != nil || !exists {
+		return fmt.Errorf("failed to get finality checkpoints of node %d: %v", genesisNodeIndex, err)
+	}
+	if exists, err := beaconapi.FinalityCheckpoints(ctx, checkpointNode.API, eth2api.StateHead, &checkpointFinality); err != nil || !exists {
+		return fmt.Errorf("failed to get finality checkpoints of node %d: %v", checkpointNodeIndex, err)
+	}
+	if genesisFinality.Finalized != checkpointFinality.Finalized {
+		issues = append(issues, fmt.Sprintf("finalized checkpoints differ: genesis-synced node %d has %s, checkpoint-synced node %d has %s",
+			genesisNodeIndex, &genesisFinality.Finalized, checkpointNodeIndex, &checkpointFinality.Finalized))
+	}
+	if genesisFinality.CurrentJustified != checkpointFinality.CurrentJustified {
+		issues = append(issues, fmt.Sprintf("justified checkpoints differ: genesis-synced node %d has %s, checkpoint-synced node %d has %s",
+			genesisNodeIndex, &genesisFinality.CurrentJustified, checkpointNodeIndex, &checkpointFinality.CurrentJustified))
+	}
+
+	genesisHead, _, err := beaconapi.BlockRoot(ctx, genesisNode.API, eth2api.BlockHead)
+	if err != nil {
+		return fmt.Errorf("failed to get head root of node %d: %w", genesisNodeIndex, err)
+	}
+	checkpointHead, _, err := beaconapi.BlockRoot(ctx, checkpointNode.API, eth2api.BlockHead)
+	if err != nil {
+		return fmt.Errorf("failed to get head root of node %d: %w", checkpointNodeIndex, err)
+	}
+	if genesisHead != checkpointHead {
+		issues = append(issues, fmt.Sprintf("head roots differ: genesis-synced node %d has %s, checkpoint-synced node %d has %s",
+			genesisNodeIndex, genesisHead, checkpointNodeIndex, checkpointHead))
+	}
+
+	// Compare the block roots over a recent window of slots, one slot at a
+	// time, so a divergence is pinned to the exact slot it starts at. Only
+	// the window up to the current head matters; a slot may legitimately be
+	// empty on both nodes, but never on only one.
+	headSlot := t.CurrentSlot()
+	fromSlot := common.Slot(0)
+	if headSlot > checkpointEquivalenceSlots {
+		fromSlot = headSlot - checkpointEquivalenceSlots
+	}
+	for slot := fromSlot; slot <= headSlot; slot++ {
+		genesisRoot, genesisHas, err := beaconapi.BlockRoot(ctx, genesisNode.API, eth2api.BlockIdSlot(slot))
+		if err != nil {
+			return fmt.Errorf("failed to get block root at slot %d from node %d: %w", slot, genesisNodeIndex, err)
+		}
+		checkpointRoot, checkpointHas, err := beaconapi.BlockRoot(ctx, checkpointNode.API, eth2api.BlockIdSlot(slot))
+		if err != nil {
+			return fmt.Errorf("failed to get block root at slot %d from node %d: %w", slot, checkpointNodeIndex, err)
+		}
+		switch {
+		case genesisHas != checkpointHas:
+			issues = append(issues, fmt.Sprintf("slot %d: genesis-synced node %d has a block: %v, checkpoint-synced node %d has a block: %v",
+				slot, genesisNodeIndex, genesisHas, checkpointNodeIndex, checkpointHas))
+		case genesisHas && genesisRoot != checkpointRoot:
+			issues = append(issues, fmt.Sprintf("slot %d: block roots differ: genesis-synced node %d has %s, checkpoint-synced node %d has %s",
+				slot, genesisNodeIndex, genesisRoot, checkpointNodeIndex, checkpointRoot))
+		}
+	}
+
+	// Matching block roots can still hide a state divergence served through
+	// the API, so the last finalized slots get a full state root comparison.
+	finalizedSlot, _ := t.spec.EpochStartSlot(genesisFinality.Finalized.Epoch)
+	for i := 0; i < checkpointEquivalenceStateSlots && finalizedSlot >= common.Slot(i); i++ {
+		slot := finalizedSlot - common.Slot(i)
+		if err := t.CompareStateRoots(ctx, slot); err != nil {
+			issues = append(issues, err.Error())
+		}
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("checkpoint-synced node %d is not equivalent to genesis-synced node %d: %s",
+			checkpointNodeIndex, genesisNodeIndex, strings.Join(issues, "; "))
+	}
+	t.t.Logf("checkpoint-synced node %d matches genesis-synced node %d: finality %s, head %s",
+		checkpointNodeIndex, genesisNodeIndex, &genesisFinality.Finalized, genesisHead)
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/eth2api/client/validatorapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// circuitBreakerRecoverySlots is how many slots after the builder failure
+// window VerifyBuilderCircuitBreaker keeps watching, so that fallback blocks
+// produced right after the disruption are judged too.
+const circuitBreakerRecoverySlots = common.Slot(4)
+
+// circuitBreakerMaxFinalityGapEpochs bounds how far finality may lag behind
+// its normal two-epoch distance after a builder disruption before the chain
+// counts as having stopped finalizing.
+const circuitBreakerMaxFinalityGapEpochs = 2
+
+// VerifyBuilderCircuitBreaker orchestrates a builder failure and checks the
+// consensus clients recover from it: every mock builder withholds its payload
+// reveals for failSlots slots, after which the helper verifies that no more
+// than maxMissedSlots proposals were missed, that no withheld builder payload
+// became canonical — i.e. the affected proposers fell back to locally built
+// blocks — and that finality kept progressing. Since circuit-breaker
+// thresholds differ between clients, the per-node outcome is reported:
+// proposals missed and how the builder kept being queried through the window.
+func (t *Testnet) VerifyBuilderCircuitBreaker(ctx context.Context, failSlots common.Slot, maxMissedSlots int) error {
+	nodes := t.builderEnabledNodes()
+	if len(nodes) == 0 {
+		return fmt.Errorf("no mock builders attached, nothing to trip a circuit breaker with")
+	}
+	fromSlot := t.CurrentSlot() + 1
+	toSlot := fromSlot + failSlots - 1
+	scenario := &BuilderScenario{
+		Name: fmt.Sprintf("circuit breaker: withhold reveals of slots %d-%d", fromSlot, toSlot),
+		Withhold: func(slot common.Slot) bool {
+			return slot >= fromSlot && slot <= toSlot
+		},
+	}
+	for _, i := range nodes {
+		t.mockBuilders[i].setScenario(scenario)
+	}
+	t.t.Logf("builders of nodes %v withhold payload reveals for slots %d-%d", nodes, fromSlot, toSlot)
+
+	// Sit out the failure window plus a recovery margin, then restore honest
+	// serving before judging the outcome.
+	lastSlot := toSlot + circuitBreakerRecoverySlots
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for t.CurrentSlot() <= lastSlot {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	for _, i := range nodes {
+		t.mockBuilders[i].setScenario(nil)
+	}
+
+	missed, err := t.AssertNoMissedProposals(ctx, fromSlot, lastSlot)
+	if err != nil {
+		return err
+	}
+	t.reportCircuitBreakerBehavior(ctx, nodes, fromSlot, lastSlot, missed)
+	if len(missed) > maxMissedSlots {
+		return fmt.Errorf("%d proposals missed at slots %v during the builder failure window, at most %d allowed",
+			len(missed), missed, maxMissedSlots)
+	}
+
+	var producedSlots []common.Slot
+	for slot := fromSlot; slot <= lastSlot; slot++ {
+		producedSlots = append(producedSlots, slot)
+	}
+	if err := t.RequireNoBuilderPayloads(ctx, producedSlots); err != nil {
+		return fmt.Errorf("a withheld builder payload became canonical: %w", err)
+	}
+
+	var issues []string
+	for i, bn := range t.beacons {
+		var checkpoints eth2api.FinalityCheckpoints
+		if exists, err := beaconapi.FinalityCheckpoints(ctx, bn.API, eth2api.StateHead, &checkpoints); err != nil || !exists {
+			return fmt.Errorf("failed to get finality checkpoints of node %d: %v", i, err)
+		}
+		currentEpoch := t.CurrentEpoch()
+		if lag := uint64(currentEpoch - checkpoints.Finalized.Epoch); currentEpoch >= 2 && lag > 2+circuitBreakerMaxFinalityGapEpochs {
+			issues = append(issues, fmt.Sprintf("node %d: finality lags %d epochs behind after the builder failure", i, lag))
+		}
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("chain stopped finalizing: %s", strings.Join(issues, "; "))
+	}
+	t.t.Logf("builder circuit breaker handled: %d missed proposals (max %d), all blocks built locally, finality intact",
+		len(missed), maxMissedSlots)
+	return nil
+}
+
+// reportCircuitBreakerBehavior logs, per node, how the client behaved through
+// a builder failure window: the proposals its validators missed, and in how
+// many slots it still queried its builder for headers.
+func (t *Testnet) reportCircuitBreakerBehavior(ctx context.Context, builderNodes []int, fromSlot, toSlot common.Slot, missed []common.Slot) {
+	duties := make(map[common.Slot]common.ValidatorIndex)
+	for epoch := t.spec.SlotToEpoch(fromSlot); epoch <= t.spec.SlotToEpoch(toSlot); epoch++ {
+		var resp eth2api.DependentProposerDuty
+		if _, err := validatorapi.ProposerDuties(ctx, t.roundRobinBeacon().API, epoch, &resp); err != nil {
+			t.t.Logf("failed to fetch proposer duties for the circuit breaker report: %v", err)
+			return
+		}
+		for _, duty := range resp.Data {
+			duties[duty.Slot] = duty.ValidatorIndex
+		}
+	}
+	missedByNode := make(map[int][]common.Slot)
+	for _, slot := range missed {
+		if owner, err := t.OwnerOfValidator(duties[slot]); err == nil {
+			missedByNode[owner] = append(missedByNode[owner], slot)
+		}
+	}
+	for _, i := range builderNodes {
+		stats, err := t.BuilderStats(i)
+		if err != nil {
+			continue
+		}
+		queried := 0
+		for slot := fromSlot; slot <= toSlot; slot++ {
+			if stats.HeaderRequestSlots[slot] > 0 {
+				queried++
+			}
+		}
+		t.t.Logf("node %d (%s): missed proposals at %v, queried its builder in %d of %d window slots",
+			i, t.beacons[i].Type, missedByNode[i], queried, toSlot-fromSlot+1)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// Config describes the shape of a testnet: how many validators participate,
+// which clients make up each node, and where the fork boundaries are placed.
+type Config struct {
+	// ValidatorCount is the total number of validators in the genesis state.
+	ValidatorCount uint64
+
+	// SlotTime overrides SECONDS_PER_SLOT. Zero means the mainnet default.
+	SlotTime uint64
+
+	// Fork schedule, expressed in epochs since genesis. The zero value
+	// activates a fork at genesis; unscheduled forks must be set to
+	// common.FAR_FUTURE_EPOCH.
+	AltairForkEpoch    common.Epoch
+	BellatrixForkEpoch common.Epoch
+	CapellaForkEpoch   common.Epoch
+	DenebForkEpoch     common.Epoch
+	ElectraForkEpoch   common.Epoch
+
+	// Nodes describes the client stack of each node in the network. The
+	// genesis validator set is split evenly over the nodes.
+	Nodes []NodeDefinition
+
+	// MaxConcurrentStartups bounds how many node bundles start in parallel.
+	// One falls back to strictly sequential startup; zero means no limit.
+	MaxConcurrentStartups int
+
+	// LazyBeaconState makes recurring health checks query validator status in
+	// batches instead of fetching full validator registries, which pays off
+	// with hundreds of validators.
+	LazyBeaconState bool
+
+	// EnableBuilders attaches a mock builder to every beacon node. It is the
+	// default for nodes whose definition leaves EnableBuilder unset; mixed
+	// builder/local topologies set the per-node knob instead.
+	EnableBuilders bool
+
+	// BuilderScenario configures a builder disruption — e.g.
+	// InvalidStateRootFromEpoch(3) or WithheldPayloadsForSlots(…) — applied
+	// by every builder-enabled node's mock builder.
+	BuilderScenario *BuilderScenario
+
+	// TrackForkchoiceUpdated routes every node's execution RPC through a
+	// recording proxy, so tests can inspect the forkchoice updates the
+	// beacon node sends to its execution client. Per-node tracking is set
+	// on the node definition instead.
+	TrackForkchoiceUpdated bool
+
+	// EngineResponseMutators installs in-flight response mutators — e.g.
+	// CorruptStateRootMutator() — on every node's execution proxy, keyed by
+	// method prefix. Setting any implies the proxy, like
+	// TrackForkchoiceUpdated.
+	EngineResponseMutators map[string]EngineResponseMutator
+
+	// LogEngineLatency makes wait helpers append the worst recent newPayload
+	// latency of the tracked nodes to their per-slot status lines — a
+	// slowly-validating execution client is the most common cause of missed
+	// slots. Requires TrackForkchoiceUpdated.
+	LogEngineLatency bool
+
+	// RequiredEngineCapabilities makes startup fail unless every execution
+	// client reports support for these engine API methods, e.g.
+	// engine_newPayloadV3 for Deneb runs.
+	RequiredEngineCapabilities []string
+
+	// EnableBlobber starts a blobber alongside the testnet and makes every
+	// beacon node trust its peer identities.
+	EnableBlobber bool
+
+	// BlobberTrustedPeerCount overrides how many blobber peer identities are
+	// minted and trusted at startup. Zero means one per node.
+	BlobberTrustedPeerCount int
+
+	// BlobberValidatorRange restricts the primary blobber to the validators
+	// with index in [Start, End), so only part of the proposer set misbehaves.
+	// Nil hands it the full validator set.
+	BlobberValidatorRange *IndexRange
+
+	// BlobberActionSchedule assigns blobber actions to slot ranges, letting a
+	// run mix honest phases with scheduled disruptions. Requires
+	// EnableBlobber.
+	BlobberActionSchedule []BlobberScheduleEntry
+
+	// BlobberErrorsFatal makes internal blobber errors fail polling waits
+	// instead of only logging warnings, for tests where a degraded blobber
+	// would make a pass meaningless.
+	BlobberErrorsFatal bool
+
+	// Blobbers configures additional blobbers, each attached to its own node
+	// subset, for scenarios with conflicting disruption sources. EnableBlobber
+	// remains the single-blobber shorthand covering all nodes.
+	Blobbers []BlobberDefinition
+}
+
+// IndexRange is a half-open range [Start, End) of validator indices.
+type IndexRange struct {
+	Start common.ValidatorIndex
+	End   common.ValidatorIndex
+}
+
+// BlobberDefinition attaches one blobber to a subset of nodes.
+type BlobberDefinition struct {
+	// Nodes are the indices of the beacon nodes trusting this blobber.
+	Nodes []int
+
+	// TrustedPeerCount overrides how many peer identities are minted. Zero
+	// means one per targeted node.
+	TrustedPeerCount int
+}
+
+// NodeDefinition names the client types making up one node of the testnet.
+type NodeDefinition struct {
+	ExecutionClient string
+	BeaconNode      string
+	ValidatorClient string
+
+	// Graffiti is stamped into the blocks proposed by this node's
+	// validators. Empty leaves the client default.
+	Graffiti string
+
+	// DuplicateKeysOfNode gives this node's validator client a copy of
+	// another node's keys instead of a tranche of its own, to provoke
+	// doppelganger detection. The testnet records the intentional overlap
+	// so correctness checks do not flag it.
+	DuplicateKeysOfNode *int
+
+	// EnableBuilder attaches a mock builder to this node's beacon client, so
+	// relay tests can mix builder-connected and local-only nodes.
+	EnableBuilder bool
+
+	// BuilderOptions configures this node's mock builder. Nil means the
+	// default recording-only builder.
+	BuilderOptions *BuilderOptions
+
+	// TrackForkchoiceUpdated routes this node's execution RPC through a
+	// recording proxy, like the global Config knob but for a single node.
+	TrackForkchoiceUpdated bool
+
+	// EngineResponseMutators installs in-flight response mutators on this
+	// node's execution proxy, keyed by method prefix, overriding any global
+	// mutator for the same prefix.
+	EngineResponseMutators map[string]EngineResponseMutator
+
+	// UseRemoteSigner starts a remote signer container holding this node's
+	// keys and points the validator client at it instead of handing it the
+	// key files directly.
+	UseRemoteSigner bool
+
+	// RemoteSigner names the signer client type. Empty means the default
+	// remote signer role.
+	RemoteSigner string
+
+	// JWTSecret overrides the engine API secret of this node's execution
+	// client, as 0x-prefixed hex. Empty means the testnet-wide default; all
+	// nodes must end up with the same secret.
+	JWTSecret string
+}
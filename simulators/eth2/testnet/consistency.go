@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// slotRoot is the block root one node serves for one slot; exists is false
+// for a missed slot (404).
+type slotRoot struct {
+	root   common.Root
+	exists bool
+	err    error
+}
+
+// AssertBlockRootsConsistency checks that every running beacon node serves
+// the same block root for every slot in [fromSlot, toSlot]. A slot that one
+// node misses (404) and another has a block for counts as a divergence too.
+// All slots are fetched concurrently and every divergence in the range is
+// reported in one combined error, since fork divergence is easier to debug
+// from the full picture than from the first mismatch.
+func (t *Testnet) AssertBlockRootsConsistency(ctx context.Context, fromSlot, toSlot common.Slot) error {
+	if fromSlot > toSlot {
+		return fmt.Errorf("invalid slot range %d-%d", fromSlot, toSlot)
+	}
+	slots := int(toSlot - fromSlot + 1)
+	roots := make([][]slotRoot, slots)
+	var wg sync.WaitGroup
+	for i := 0; i < slots; i++ {
+		i := i
+		roots[i] = make([]slotRoot, len(t.beacons))
+		for j, bn := range t.beacons {
+			j, bn := j, bn
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				root, exists, err := beaconapi.BlockRoot(ctx, bn.API, eth2api.BlockIdSlot(fromSlot+common.Slot(i)))
+				roots[i][j] = slotRoot{root: root, exists: exists, err: err}
+			}()
+		}
+	}
+	wg.Wait()
+
+	var divergences []string
+	for i := 0; i < slots; i++ {
+		slot := fromSlot + common.Slot(i)
+		reference := roots[i][0]
+		if reference.err != nil {
+			return fmt.Errorf("failed to get block root at slot %d from node 0: %w", slot, reference.err)
+		}
+		for j := 1; j < len(t.beacons); j++ {
+			r := roots[i][j]
+			if r.err != nil {
+				return fmt.Errorf("failed to get block root at slot %d from node %d: %w", slot, j, r.err)
+			}
+			switch {
+			case reference.exists != r.exists:
+				divergences = append(divergences, fmt.Sprintf(
+					"slot %d: node 0 %s, node %d %s", slot, describeRoot(reference), j, describeRoot(r)))
+			case reference.exists && reference.root != r.root:
+				divergences = append(divergences, fmt.Sprintf(
+					"slot %d: node 0 has %s, node %d has %s", slot, reference.root.String(), j, r.root.String()))
+			}
+		}
+	}
+	if len(divergences) > 0 {
+		return fmt.Errorf("block roots diverge: %s", strings.Join(divergences, "; "))
+	}
+	t.t.Logf("block roots of slots %d-%d consistent across %d nodes", fromSlot, toSlot, len(t.beacons))
+	return nil
+}
+
+func describeRoot(r slotRoot) string {
+	if !r.exists {
+		return "misses the slot"
+	}
+	return fmt.Sprintf("has %s", r.root.String())
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/protolambda/eth2api"
+)
+
+// pendingConsolidations reads the EIP-7251 pending consolidation queue of a
+// node's head state. Like the deposit index, it is pulled out of the debug
+// state endpoint directly: the spec types of this tree predate Electra, and
+// the standard API has no lighter way to expose the queue.
+func pendingConsolidations(ctx context.Context, bn *BeaconNode) ([]json.RawMessage, error) {
+	var state struct {
+		PendingConsolidations []json.RawMessage `json:"pending_consolidations"`
+	}
+	resp := bn.API.Request(ctx, eth2api.PlainGET("/eth/v2/debug/beacon/states/head"))
+	if _, err := resp.Decode(eth2api.Wrap(&state)); err != nil {
+		return nil, fmt.Errorf("failed to read pending consolidations: %w", err)
+	}
+	return state.PendingConsolidations, nil
+}
+
+// GetPendingConsolidationsCount returns the number of pending EIP-7251
+// consolidations in the given node's head state.
+func (t *Testnet) GetPendingConsolidationsCount(ctx context.Context, nodeIndex int) (int, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return 0, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	if !t.electraEnabled() {
+		return 0, fmt.Errorf("testnet is pre-Electra, states have no pending consolidations")
+	}
+	queue, err := pendingConsolidations(ctx, t.beacons[nodeIndex])
+	if err != nil {
+		return 0, err
+	}
+	return len(queue), nil
+}
+
+// WaitForPendingConsolidationsEmpty blocks until every node reports an empty
+// EIP-7251 pending consolidation queue, polling once per epoch. Use it before
+// final balance assertions in consolidation tests, so queued consolidations
+// are known to have executed. On pre-Electra testnets it returns immediately
+// with a logged notice.
+func (t *Testnet) WaitForPendingConsolidationsEmpty(ctx context.Context) error {
+	if !t.electraEnabled() {
+		t.t.Logf("notice: skipping pending consolidation wait, testnet is pre-Electra")
+		return nil
+	}
+	timer := time.NewTicker(t.slotDuration() * time.Duration(t.spec.SLOTS_PER_EPOCH))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			allEmpty := true
+			for i, bn := range t.beacons {
+				queue, err := pendingConsolidations(ctx, bn)
+				if err != nil {
+					t.t.Logf("failed to poll pending consolidations of node %d: %v", i, err)
+					allEmpty = false
+					break
+				}
+				if len(queue) > 0 {
+					t.t.Logf("node %d has %d pending consolidations", i, len(queue))
+					allEmpty = false
+					break
+				}
+			}
+			if allEmpty {
+				t.t.Logf("pending consolidation queue empty on all %d nodes", len(t.beacons))
+				return nil
+			}
+		}
+	}
+}
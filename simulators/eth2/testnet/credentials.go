@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// CredentialStats is the result of auditing the withdrawal credentials of
+// the on-chain validator registry.
+type CredentialStats struct {
+	// BLSCount is the number of validators with 0x00 BLS credentials.
+	BLSCount int
+	// ExecutionCount is the number of validators with 0x01 execution
+	// address credentials.
+	ExecutionCount int
+	// OtherCount is the number of validators with an unrecognized
+	// credential prefix.
+	OtherCount int
+
+	// MismatchedIndices are the validators whose on-chain credentials do
+	// not match the ones configured at preparation time.
+	MismatchedIndices []common.ValidatorIndex
+	// MalformedIndices are validators with 0x01 credentials that are not
+	// well-formed: non-zero padding or a zero execution address.
+	MalformedIndices []common.ValidatorIndex
+}
+
+// AuditWithdrawalCredentials downloads the validator registry from a
+// verification node, classifies every validator's withdrawal credentials,
+// and cross-checks them against the credentials the testnet was prepared
+// with. 0x01 credentials are additionally checked for well-formedness: the
+// padding bytes must be zero and the execution address must not be.
+func (t *Testnet) AuditWithdrawalCredentials(ctx context.Context) (CredentialStats, error) {
+	var stats CredentialStats
+	var validators []eth2api.ValidatorResponse
+	if exists, err := beaconapi.StateValidators(ctx, t.roundRobinBeacon().API, eth2api.StateHead, nil, nil, &validators); err != nil {
+		return stats, fmt.Errorf("failed to get validator registry: %w", err)
+	} else if !exists {
+		return stats, fmt.Errorf("no head state to audit")
+	}
+	for _, v := range validators {
+		creds := v.Validator.WithdrawalCredentials
+		switch creds[0] {
+		case common.BLS_WITHDRAWAL_PREFIX:
+			stats.BLSCount++
+		case common.ETH1_ADDRESS_WITHDRAWAL_PREFIX:
+			stats.ExecutionCount++
+			wellFormed := true
+			for _, b := range creds[1:12] {
+				if b != 0 {
+					wellFormed = false
+					break
+				}
+			}
+			address := creds[12:]
+			nonZero := false
+			for _, b := range address {
+				if b != 0 {
+					nonZero = true
+					break
+				}
+			}
+			if !wellFormed || !nonZero {
+				stats.MalformedIndices = append(stats.MalformedIndices, v.Index)
+			}
+		default:
+			stats.OtherCount++
+		}
+		if known := t.Validators.ByIndex(v.Index); known != nil && known.WithdrawalCredentials != creds {
+			stats.MismatchedIndices = append(stats.MismatchedIndices, v.Index)
+		}
+	}
+	t.t.Logf("withdrawal credential audit: %d BLS, %d execution, %d other, %d mismatched, %d malformed",
+		stats.BLSCount, stats.ExecutionCount, stats.OtherCount, len(stats.MismatchedIndices), len(stats.MalformedIndices))
+	return stats, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// withinDAWindow reports whether the slot is still inside the data
+// availability window, i.e. nodes are required to serve its blob sidecars.
+func (t *Testnet) withinDAWindow(slot common.Slot) bool {
+	windowEpochs := common.Epoch(t.spec.MIN_EPOCHS_FOR_BLOB_SIDECARS_REQUESTS)
+	currentEpoch := t.CurrentEpoch()
+	if currentEpoch < windowEpochs {
+		return true
+	}
+	return t.spec.SlotToEpoch(slot) >= currentEpoch-windowEpochs
+}
+
+// CheckBlobSidecarAvailability queries the blob sidecars of the given block
+// on every node and checks that the responses are consistent with the data
+// availability window: inside the window every node must serve the sidecars;
+// outside it a node may answer with a clean 404 or with the data, but never
+// with a response that fails to decode, and nodes that do serve must agree on
+// the sidecar count. Per-node results are logged.
+func (t *Testnet) CheckBlobSidecarAvailability(ctx context.Context, blockRoot common.Root, slot common.Slot) error {
+	inWindow := t.withinDAWindow(slot)
+	var issues []string
+	servedCount := -1
+	for i, bn := range t.beacons {
+		sidecars, exists, err := blobSidecars(ctx, bn, eth2api.BlockIdRoot(blockRoot))
+		switch {
+		case err != nil:
+			// A decode failure on a 200 response is a corrupted or partial
+			// answer, which is never acceptable.
+			issues = append(issues, fmt.Sprintf("node %d returned a corrupted sidecar response: %v", i, err))
+		case !exists:
+			if inWindow {
+				issues = append(issues, fmt.Sprintf("node %d does not serve sidecars of block %s inside the DA window", i, blockRoot))
+			} else {
+				t.t.Logf("node %d pruned sidecars of block %s (outside DA window, clean 404)", i, blockRoot)
+			}
+		default:
+			if servedCount == -1 {
+				servedCount = len(sidecars)
+			} else if len(sidecars) != servedCount {
+				issues = append(issues, fmt.Sprintf("node %d serves %d sidecars of block %s, other nodes serve %d",
+					i, len(sidecars), blockRoot, servedCount))
+			}
+			t.t.Logf("node %d serves %d sidecars of block %s", i, len(sidecars), blockRoot)
+		}
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("inconsistent blob availability of block %s (slot %d, in window: %v): %s",
+			blockRoot, slot, inWindow, strings.Join(issues, "; "))
+	}
+	return nil
+}
+
+// VerifyDataAvailability is the post-test data availability sweep: when a
+// blobber or blob spammer was active during the run, it walks every slot with
+// a block carrying blob commitments and runs the per-node sidecar consistency
+// check on it. Without any blob activity it returns immediately, since there
+// is nothing to have diverged.
+func (t *Testnet) VerifyDataAvailability(ctx context.Context) error {
+	if len(t.blobbers) == 0 && !t.blobSpammerActive {
+		t.t.Logf("skipping data availability sweep, no blobber or blob spammer was active")
+		return nil
+	}
+	bn := t.roundRobinBeacon()
+	checked := 0
+	for slot := common.Slot(0); slot <= t.CurrentSlot(); slot++ {
+		block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+		if err != nil {
+			return err
+		}
+		if !exists || block.KZGCommitmentCount() == 0 {
+			continue
+		}
+		root, rootExists, err := beaconapi.BlockRoot(ctx, bn.API, eth2api.BlockIdSlot(slot))
+		if err != nil || !rootExists {
+			return fmt.Errorf("failed to get root of block at slot %d: %w", slot, err)
+		}
+		if err := t.CheckBlobSidecarAvailability(ctx, root, slot); err != nil {
+			return err
+		}
+		checked++
+	}
+	t.t.Logf("data availability sweep checked %d blocks with blobs", checked)
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	blsu "github.com/protolambda/bls12-381-util"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/tree"
+
+	"github.com/ethereum/hive/simulators/eth2/testnet/utils"
+)
+
+// depositContractAddress is where the execution genesis of the client images
+// places the deposit contract.
+var depositContractAddress = ethcommon.HexToAddress("0x4242424242424242424242424242424242424242")
+
+// depositSelector is the 4-byte selector of
+// deposit(bytes,bytes,bytes,bytes32).
+var depositSelector = []byte{0x22, 0x89, 0x51, 0x18}
+
+// signedDepositData builds the deposit data of a new validator, signed with
+// its key under the deposit domain.
+func (t *Testnet) signedDepositData(v *utils.Validator, amount common.Gwei) common.DepositData {
+	data := common.DepositData{
+		Pubkey:                v.PubKey,
+		WithdrawalCredentials: v.WithdrawalCredentials,
+		Amount:                amount,
+	}
+	// Deposits are signed with the genesis fork version and an empty
+	// genesis validators root, so they are valid on any fork.
+	domain := common.ComputeDomain(common.DOMAIN_DEPOSIT, t.spec.GENESIS_FORK_VERSION, common.Root{})
+	signingRoot := common.ComputeSigningRoot(data.MessageRoot(), domain)
+	data.Signature = common.BLSSignature(blsu.Sign(v.SecretKey, signingRoot[:]).Serialize())
+	return data
+}
+
+// depositCallData ABI-encodes a call of the deposit contract's
+// deposit(bytes,bytes,bytes,bytes32) function.
+func depositCallData(data *common.DepositData) []byte {
+	root := data.HashTreeRoot(tree.GetHashFn())
+	out := make([]byte, 0, 4+13*32)
+	out = append(out, depositSelector...)
+	appendWord := func(v uint64) {
+		var word [32]byte
+		big.NewInt(int64(v)).FillBytes(word[:])
+		out = append(out, word[:]...)
+	}
+	appendBytes := func(b []byte) {
+		appendWord(uint64(len(b)))
+		out = append(out, b...)
+		if pad := len(b) % 32; pad != 0 {
+			out = append(out, make([]byte, 32-pad)...)
+		}
+	}
+	appendWord(0x80)  // offset of pubkey
+	appendWord(0xe0)  // offset of withdrawal credentials
+	appendWord(0x120) // offset of signature
+	out = append(out, root[:]...)
+	appendBytes(data.Pubkey[:])
+	appendBytes(data.WithdrawalCredentials[:])
+	appendBytes(data.Signature[:])
+	return out
+}
+
+// Deposits sends one real deposit transaction per given validator to the
+// deposit contract, from the pre-funded test account, and returns the eth1
+// block number in which each deposit was included. Each deposit is for the
+// maximum effective balance.
+//
+// The deposits surface on the beacon chain through eth1-data voting before
+// Electra, and through EIP-6110 execution-layer deposit requests after it;
+// both pipelines start from the same contract call made here.
+func (t *Testnet) Deposits(ctx context.Context, newValidators utils.Validators) ([]uint64, error) {
+	if t.electraEnabled() && t.CurrentEpoch() >= t.spec.ELECTRA_FORK_EPOCH {
+		t.t.Logf("submitting %d deposits via EIP-6110 execution-layer deposit requests", len(newValidators))
+	} else {
+		t.t.Logf("submitting %d deposits via eth1-data voting", len(newValidators))
+	}
+	amount := t.spec.MAX_EFFECTIVE_BALANCE
+	value := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(1e9)) // Gwei to Wei
+
+	node := t.eth1[0]
+	blockNumbers := make([]uint64, 0, len(newValidators))
+	for _, v := range newValidators {
+		data := t.signedDepositData(v, amount)
+		txHash, err := sendTestTransaction(ctx, node, &depositContractAddress, value, depositCallData(&data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to send deposit of validator %s: %w", v.PubKey.String(), err)
+		}
+		blockNumber, err := waitForReceipt(ctx, node, txHash)
+		if err != nil {
+			return nil, fmt.Errorf("deposit of validator %s not included: %w", v.PubKey.String(), err)
+		}
+		blockNumbers = append(blockNumbers, blockNumber)
+	}
+	t.invalidateOwnerCache()
+	return blockNumbers, nil
+}
+
+// depositContractLogCount counts the deposit events the deposit contract has
+// emitted, i.e. the number of deposits the execution chain knows about.
+func (t *Testnet) depositContractLogCount(ctx context.Context) (uint64, error) {
+	logs, err := t.eth1[0].EthClient().FilterLogs(ctx, ethereum.FilterQuery{
+		Addresses: []ethcommon.Address{depositContractAddress},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deposit contract logs: %w", err)
+	}
+	return uint64(len(logs)), nil
+}
+
+// processedDepositIndex reads the eth1 deposit index of a node's head state,
+// i.e. how many deposits the beacon chain has processed. The index is pulled
+// out of the debug state endpoint directly, since the standard API has no
+// lighter way to expose it.
+func processedDepositIndex(ctx context.Context, bn *BeaconNode) (uint64, error) {
+	var state struct {
+		Eth1DepositIndex common.DepositIndex `json:"eth1_deposit_index"`
+	}
+	resp := bn.API.Request(ctx, eth2api.PlainGET("/eth/v2/debug/beacon/states/head"))
+	if _, err := resp.Decode(eth2api.Wrap(&state)); err != nil {
+		return 0, fmt.Errorf("failed to read eth1 deposit index: %w", err)
+	}
+	return uint64(state.Eth1DepositIndex), nil
+}
+
+// WaitForDepositQueueEmpty blocks until the beacon chain has processed every
+// deposit the deposit contract has seen, comparing the head state's eth1
+// deposit index against the contract's log count once per slot. Deposits
+// drain at MAX_DEPOSITS_PER_BLOCK per block, so use this before waiting on
+// activations to avoid timing out on a still-filling queue.
+func (t *Testnet) WaitForDepositQueueEmpty(ctx context.Context) error {
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			total, err := t.depositContractLogCount(ctx)
+			if err != nil {
+				return err
+			}
+			processed, err := processedDepositIndex(ctx, t.roundRobinBeacon())
+			if err != nil {
+				t.t.Logf("failed to poll processed deposits: %v", err)
+				continue
+			}
+			if processed >= total {
+				t.t.Logf("deposit queue empty, all %d deposits processed", total)
+				return nil
+			}
+			t.t.Logf("deposit queue: %d of %d deposits processed, %d pending", processed, total, total-processed)
+		}
+	}
+}
+
+// waitForReceipt polls until the given transaction is included and returns
+// its block number.
+func waitForReceipt(ctx context.Context, node *Eth1Node, txHash ethcommon.Hash) (uint64, error) {
+	client := node.EthClient()
+	timer := time.NewTicker(time.Second)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-timer.C:
+			receipt, err := client.TransactionReceipt(ctx, txHash)
+			if err != nil || receipt == nil {
+				continue
+			}
+			return receipt.BlockNumber.Uint64(), nil
+		}
+	}
+}
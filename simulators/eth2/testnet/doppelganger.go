@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// doppelgangerObservationEpochs is how long ExpectDoppelgangerShutdown
+// observes the duplicated keys; doppelganger detection needs at least one
+// full epoch of liveness data before a client decides to shut down.
+const doppelgangerObservationEpochs = 2
+
+// ExpectDoppelgangerShutdown verifies the outcome of a doppelganger scenario
+// on a node configured with DuplicateKeysOfNode: after the observation
+// window, none of the duplicated keys may be slashed, meaning the
+// late-started duplicate validator client detected the originals and refused
+// to attest.
+func (t *Testnet) ExpectDoppelgangerShutdown(ctx context.Context, nodeIndex int) error {
+	if _, ok := t.duplicateNodes[nodeIndex]; !ok {
+		return fmt.Errorf("node %d does not duplicate another node's keys", nodeIndex)
+	}
+	window := time.Duration(uint64(t.spec.SLOTS_PER_EPOCH)*doppelgangerObservationEpochs) * t.slotDuration()
+	t.t.Logf("observing doppelganger behavior of node %d for %v", nodeIndex, window)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(window):
+	}
+	for _, v := range t.validators[nodeIndex].Keys {
+		var resp eth2api.ValidatorResponse
+		if exists, err := beaconapi.StateValidator(ctx, t.roundRobinBeacon().API, eth2api.StateHead,
+			eth2api.ValidatorIdIndex(v.Index), &resp); err != nil {
+			return fmt.Errorf("failed to look up validator %d: %w", v.Index, err)
+		} else if !exists {
+			return fmt.Errorf("validator %d not found in head state", v.Index)
+		}
+		if resp.Validator.Slashed {
+			return fmt.Errorf("validator %d was slashed, duplicate client on node %d did not shut down", v.Index, nodeIndex)
+		}
+	}
+	t.t.Logf("no duplicated key of node %d was slashed, doppelganger shutdown worked", nodeIndex)
+	return nil
+}
+
+// ExpectSlashing waits until all given validators are slashed, for
+// doppelganger variants that run with detection disabled. The slashings are
+// recorded as expected so AssertNoByzantineActivity does not flag them.
+func (t *Testnet) ExpectSlashing(ctx context.Context, indices []common.ValidatorIndex) error {
+	for _, index := range indices {
+		t.markExpectedSlashing(index)
+	}
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	pending := make(map[common.ValidatorIndex]bool, len(indices))
+	for _, index := range indices {
+		pending[index] = true
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d of %d expected slashings did not happen: %w", len(pending), len(indices), ctx.Err())
+		case <-timer.C:
+			for index := range pending {
+				var resp eth2api.ValidatorResponse
+				if exists, err := beaconapi.StateValidator(ctx, t.roundRobinBeacon().API, eth2api.StateHead,
+					eth2api.ValidatorIdIndex(index), &resp); err != nil {
+					return fmt.Errorf("failed to look up validator %d: %w", index, err)
+				} else if !exists {
+					continue
+				}
+				if resp.Validator.Slashed {
+					t.t.Logf("validator %d slashed as expected", index)
+					delete(pending, index)
+				}
+			}
+			if len(pending) == 0 {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"time"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/validatorapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// ProposalStats summarizes the proposer duty outcomes of one node's
+// validators.
+type ProposalStats struct {
+	Assigned  int
+	Fulfilled int
+	Missed    int
+	// MissedSlots are the slots of the missed proposals.
+	MissedSlots []common.Slot
+}
+
+// DutyTracker follows proposer duties epoch by epoch and attributes each
+// fulfilled or missed proposal to the validator client owning the proposer,
+// turning vague "some slots were missed" failures into a per-node account.
+type DutyTracker struct {
+	t      *Testnet
+	cancel context.CancelFunc
+
+	mu    sync.Mutex
+	stats map[int]*ProposalStats
+}
+
+// StartDutyTracker starts tracking proposer duties in the background, from
+// the current epoch on. Starting an already started tracker is a no-op.
+func (t *Testnet) StartDutyTracker(ctx context.Context) *DutyTracker {
+	if t.dutyTracker != nil {
+		return t.dutyTracker
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	tracker := &DutyTracker{
+		t:      t,
+		cancel: cancel,
+		stats:  make(map[int]*ProposalStats),
+	}
+	t.dutyTracker = tracker
+	go tracker.run(ctx)
+	return tracker
+}
+
+// Stop ends the background tracking.
+func (d *DutyTracker) Stop() {
+	d.cancel()
+}
+
+// run fetches the proposer duties of each epoch and, once each duty's slot
+// has passed, checks whether a block appeared there.
+func (d *DutyTracker) run(ctx context.Context) {
+	t := d.t
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	duties := make(map[common.Slot]common.ValidatorIndex)
+	dutiesEpoch := common.Epoch(0)
+	haveDuties := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		epoch := t.CurrentEpoch()
+		if !haveDuties || epoch != dutiesEpoch {
+			var resp eth2api.DependentProposerDuty
+			if _, err := validatorapi.ProposerDuties(ctx, t.roundRobinBeacon().API, epoch, &resp); err != nil {
+				t.t.Logf("duty tracker: failed to fetch proposer duties of epoch %d: %v", epoch, err)
+				continue
+			}
+			for _, duty := range resp.Data {
+				duties[duty.Slot] = duty.ValidatorIndex
+			}
+			dutiesEpoch, haveDuties = epoch, true
+		}
+		// Judge duties one slot behind the clock, so late blocks still count.
+		for slot, proposer := range duties {
+			if slot+1 >= t.CurrentSlot() {
+				continue
+			}
+			delete(duties, slot)
+			owner, err := t.OwnerOfValidator(proposer)
+			if err != nil {
+				continue
+			}
+			_, exists, err := fetchBlock(ctx, t.roundRobinBeacon(), eth2api.BlockIdSlot(slot))
+			if err != nil {
+				t.t.Logf("duty tracker: failed to check slot %d: %v", slot, err)
+				continue
+			}
+			d.mu.Lock()
+			s := d.stats[owner]
+			if s == nil {
+				s = &ProposalStats{}
+				d.stats[owner] = s
+			}
+			s.Assigned++
+			if exists {
+				s.Fulfilled++
+			} else {
+				s.Missed++
+				s.MissedSlots = append(s.MissedSlots, slot)
+				t.t.Logf("duty tracker: node %d missed its proposal at slot %d", owner, slot)
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+// ProposalStats returns a snapshot of the per-node proposer duty outcomes
+// collected so far. The duty tracker must have been started.
+func (t *Testnet) ProposalStats() map[int]ProposalStats {
+	out := make(map[int]ProposalStats)
+	if t.dutyTracker == nil {
+		return out
+	}
+	t.dutyTracker.mu.Lock()
+	defer t.dutyTracker.mu.Unlock()
+	for node, s := range t.dutyTracker.stats {
+		out[node] = *s
+	}
+	return out
+}
+
+// RequireNoMissedProposals asserts that the given nodes (all nodes when none
+// are given) have not missed any tracked proposal, naming the offending
+// nodes and slots otherwise.
+func (t *Testnet) RequireNoMissedProposals(ctx context.Context, nodeIndexes ...int) error {
+	if t.dutyTracker == nil {
+		return fmt.Errorf("duty tracker not started")
+	}
+	if len(nodeIndexes) == 0 {
+		for i := range t.validators {
+			nodeIndexes = append(nodeIndexes, i)
+		}
+	}
+	stats := t.ProposalStats()
+	var issues []string
+	for _, i := range nodeIndexes {
+		if s, ok := stats[i]; ok && s.Missed > 0 {
+			issues = append(issues, fmt.Sprintf("node %d missed its proposals at slots %v", i, s.MissedSlots))
+		}
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%s", strings.Join(issues, "; "))
+	}
+	return nil
+}
+
+// AssertNoMissedProposals checks every slot in the given range against the
+// proposer duties of its epoch and returns the slots where the expected
+// proposer produced no block. Missing blocks are counted, not treated as
+// errors, so the caller can decide whether any misses are acceptable.
+func (t *Testnet) AssertNoMissedProposals(ctx context.Context, fromSlot, toSlot common.Slot) ([]common.Slot, error) {
+	bn := t.roundRobinBeacon()
+	duties := make(map[common.Slot]common.ValidatorIndex)
+	for epoch := t.spec.SlotToEpoch(fromSlot); epoch <= t.spec.SlotToEpoch(toSlot); epoch++ {
+		var resp eth2api.DependentProposerDuty
+		if _, err := validatorapi.ProposerDuties(ctx, bn.API, epoch, &resp); err != nil {
+			return nil, fmt.Errorf("failed to fetch proposer duties of epoch %d: %w", epoch, err)
+		}
+		for _, duty := range resp.Data {
+			duties[duty.Slot] = duty.ValidatorIndex
+		}
+	}
+	var missed []common.Slot
+	for slot := fromSlot; slot <= toSlot; slot++ {
+		if _, assigned := duties[slot]; !assigned {
+			continue
+		}
+		_, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missed = append(missed, slot)
+		}
+	}
+	return missed, nil
+}
+
+// AssertMissedProposalsInRange bounds-checks the number of missed proposals
+// in a slot range: tests that deliberately miss proposals use it to verify
+// the count is exactly what the scenario set up, no more and no less.
+func (t *Testnet) AssertMissedProposalsInRange(ctx context.Context, fromSlot, toSlot common.Slot, min, max int) error {
+	missed, err := t.AssertNoMissedProposals(ctx, fromSlot, toSlot)
+	if err != nil {
+		return err
+	}
+	if len(missed) < min || len(missed) > max {
+		return fmt.Errorf("%d missed proposals in slots %d-%d (at %v), want between %d and %d",
+			len(missed), fromSlot, toSlot, missed, min, max)
+	}
+	t.t.Logf("%d missed proposals in slots %d-%d, within bounds [%d, %d]", len(missed), fromSlot, toSlot, min, max)
+	return nil
+}
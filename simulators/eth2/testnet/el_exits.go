@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// withdrawalRequestPredeploy is the EIP-7002 withdrawal request contract
+// address, active from the Electra fork.
+var withdrawalRequestPredeploy = ethcommon.HexToAddress("0x00000961Ef480Eb55e80D19ad83579A64c007002")
+
+// electraEnabled reports whether the testnet schedules the Electra fork.
+func (t *Testnet) electraEnabled() bool {
+	return t.spec.ELECTRA_FORK_EPOCH != common.FAR_FUTURE_EPOCH
+}
+
+// InjectExecutionLayerExit triggers an EIP-7002 execution-layer exit of the
+// given validator: it sends a withdrawal request (with amount zero, i.e. a
+// full exit) to the predeploy from the pre-funded test account, through the
+// execution client of the given node. On pre-Electra testnets the call is
+// skipped with a logged warning, since the predeploy does not exist.
+func (t *Testnet) InjectExecutionLayerExit(ctx context.Context, nodeIndex int, validatorIndex uint64) (ethcommon.Hash, error) {
+	if !t.electraEnabled() {
+		t.t.Logf("warning: skipping execution-layer exit of validator %d, testnet is pre-Electra", validatorIndex)
+		return ethcommon.Hash{}, nil
+	}
+	if nodeIndex < 0 || nodeIndex >= len(t.eth1) {
+		return ethcommon.Hash{}, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	v := t.Validators.ByIndex(common.ValidatorIndex(validatorIndex))
+	if v == nil {
+		return ethcommon.Hash{}, fmt.Errorf("no known key material for validator %d", validatorIndex)
+	}
+	node := t.eth1[nodeIndex]
+
+	// The current request fee is returned by calling the predeploy with
+	// empty input.
+	feeData, err := node.EthClient().CallContract(ctx, ethereum.CallMsg{To: &withdrawalRequestPredeploy}, nil)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to read withdrawal request fee: %w", err)
+	}
+	fee := new(big.Int).SetBytes(feeData)
+
+	// Request data is the validator pubkey followed by the withdrawal
+	// amount; zero requests a full exit.
+	data := make([]byte, 0, 56)
+	data = append(data, v.PubKey[:]...)
+	var amount [8]byte
+	binary.BigEndian.PutUint64(amount[:], 0)
+	data = append(data, amount[:]...)
+
+	txHash, err := sendTestTransaction(ctx, node, &withdrawalRequestPredeploy, fee, data)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to submit withdrawal request: %w", err)
+	}
+	t.markExpectedExit(common.ValidatorIndex(validatorIndex))
+	t.t.Logf("submitted execution-layer exit of validator %d: tx %v", validatorIndex, txHash)
+	return txHash, nil
+}
+
+// RegisterWithdrawalAccountKey records the execution account key controlling
+// a 0x01 withdrawal address, so helpers can send EIP-7002 requests from it.
+// The address must be a funded account in the execution genesis.
+func (t *Testnet) RegisterWithdrawalAccountKey(address ethcommon.Address, key *ecdsa.PrivateKey) {
+	t.elWithdrawalKeysMu.Lock()
+	defer t.elWithdrawalKeysMu.Unlock()
+	if t.elWithdrawalKeys == nil {
+		t.elWithdrawalKeys = make(map[ethcommon.Address]*ecdsa.PrivateKey)
+	}
+	t.elWithdrawalKeys[address] = key
+}
+
+// withdrawalAccountKey returns the registered key of a withdrawal address.
+func (t *Testnet) withdrawalAccountKey(address ethcommon.Address) *ecdsa.PrivateKey {
+	t.elWithdrawalKeysMu.Lock()
+	defer t.elWithdrawalKeysMu.Unlock()
+	return t.elWithdrawalKeys[address]
+}
+
+// RequestELWithdrawal sends an EIP-7002 withdrawal request for the given
+// validator from its own 0x01 withdrawal address, which must have been
+// registered with RegisterWithdrawalAccountKey. A zero amount requests a
+// full exit, any other amount a partial withdrawal. Use WaitForELWithdrawal
+// to confirm the beacon chain processed the request.
+func (t *Testnet) RequestELWithdrawal(ctx context.Context, validatorIndex uint64, amountGwei common.Gwei) (ethcommon.Hash, error) {
+	if !t.electraEnabled() {
+		return ethcommon.Hash{}, fmt.Errorf("testnet is pre-Electra, no withdrawal request predeploy")
+	}
+	var v eth2api.ValidatorResponse
+	if exists, err := beaconapi.StateValidator(ctx, t.roundRobinBeacon().API, eth2api.StateHead,
+		eth2api.ValidatorIdIndex(common.ValidatorIndex(validatorIndex)), &v); err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to look up validator %d: %w", validatorIndex, err)
+	} else if !exists {
+		return ethcommon.Hash{}, fmt.Errorf("validator %d not found", validatorIndex)
+	}
+	creds := v.Validator.WithdrawalCredentials
+	if creds[0] != common.ETH1_ADDRESS_WITHDRAWAL_PREFIX {
+		return ethcommon.Hash{}, fmt.Errorf("validator %d has no 0x01 withdrawal credentials", validatorIndex)
+	}
+	address := ethcommon.BytesToAddress(creds[12:])
+	key := t.withdrawalAccountKey(address)
+	if key == nil {
+		return ethcommon.Hash{}, fmt.Errorf("no registered account key for withdrawal address %s", address)
+	}
+
+	node := t.eth1[0]
+	feeData, err := node.EthClient().CallContract(ctx, ethereum.CallMsg{To: &withdrawalRequestPredeploy}, nil)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to read withdrawal request fee: %w", err)
+	}
+	fee := new(big.Int).SetBytes(feeData)
+
+	data := make([]byte, 0, 56)
+	data = append(data, v.Validator.Pubkey[:]...)
+	var amount [8]byte
+	binary.BigEndian.PutUint64(amount[:], uint64(amountGwei))
+	data = append(data, amount[:]...)
+
+	txHash, err := sendTransactionFrom(ctx, node, key, &withdrawalRequestPredeploy, fee, data)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to submit withdrawal request: %w", err)
+	}
+	if amountGwei == 0 {
+		t.markExpectedExit(common.ValidatorIndex(validatorIndex))
+	}
+	t.t.Logf("submitted EIP-7002 withdrawal request of %d gwei for validator %d: tx %v", amountGwei, validatorIndex, txHash)
+	return txHash, nil
+}
+
+// WaitForELWithdrawal waits until the beacon chain has acted on an EIP-7002
+// withdrawal request of the given validator: for a full exit (zero amount)
+// it waits for the exit epoch to be set, for a partial withdrawal it waits
+// for a withdrawal of that validator to appear in an execution payload.
+func (t *Testnet) WaitForELWithdrawal(ctx context.Context, validatorIndex uint64, amountGwei common.Gwei) error {
+	if amountGwei == 0 {
+		return t.WaitForExecutionLayerExit(ctx, validatorIndex)
+	}
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	var lastSeen common.Slot
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			block, exists, err := fetchBlock(ctx, t.roundRobinBeacon(), eth2api.BlockHead)
+			if err != nil {
+				return err
+			}
+			if !exists || block.Slot() == lastSeen {
+				continue
+			}
+			lastSeen = block.Slot()
+			for _, w := range block.Withdrawals() {
+				if w.ValidatorIndex == common.ValidatorIndex(validatorIndex) {
+					t.t.Logf("partial withdrawal of %d gwei for validator %d in slot %d", w.Amount, validatorIndex, block.Slot())
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// WaitForExecutionLayerExit polls the beacon state until the given
+// validator's exit epoch is set, confirming that an EIP-7002 execution-layer
+// exit was processed. On pre-Electra testnets it returns immediately with a
+// logged warning.
+func (t *Testnet) WaitForExecutionLayerExit(ctx context.Context, validatorIndex uint64) error {
+	if !t.electraEnabled() {
+		t.t.Logf("warning: skipping execution-layer exit wait of validator %d, testnet is pre-Electra", validatorIndex)
+		return nil
+	}
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			var v eth2api.ValidatorResponse
+			if exists, err := beaconapi.StateValidator(ctx, t.roundRobinBeacon().API, eth2api.StateHead,
+				eth2api.ValidatorIdIndex(common.ValidatorIndex(validatorIndex)), &v); err != nil {
+				return fmt.Errorf("failed to look up validator %d: %w", validatorIndex, err)
+			} else if !exists {
+				continue
+			}
+			if v.Validator.ExitEpoch != common.FAR_FUTURE_EPOCH {
+				t.t.Logf("validator %d exits at epoch %d", validatorIndex, v.Validator.ExitEpoch)
+				return nil
+			}
+		}
+	}
+}
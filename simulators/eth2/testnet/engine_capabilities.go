@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// VerifyEngineAPIVersions checks that every execution client of the testnet
+// supports the given engine API methods, via engine_exchangeCapabilities.
+// A client silently missing a method would otherwise fall back to older
+// behavior and fail tests in confusing ways, so runs that depend on
+// fork-specific methods assert them up front through
+// Config.RequiredEngineCapabilities.
+func (t *Testnet) VerifyEngineAPIVersions(ctx context.Context, requiredMethods []string) error {
+	var issues []string
+	for i, node := range t.eth1 {
+		var capabilities []string
+		if err := node.RPC().CallContext(ctx, &capabilities, "engine_exchangeCapabilities", requiredMethods); err != nil {
+			issues = append(issues, fmt.Sprintf("node %d (%s): failed to exchange capabilities: %v", i, node.Type, err))
+			continue
+		}
+		supported := make(map[string]bool, len(capabilities))
+		for _, method := range capabilities {
+			supported[method] = true
+		}
+		for _, method := range requiredMethods {
+			if !supported[method] {
+				issues = append(issues, fmt.Sprintf("node %d (%s) does not support %s", i, node.Type, method))
+			}
+		}
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("missing engine API capabilities: %s", strings.Join(issues, "; "))
+	}
+	t.t.Logf("all %d execution clients support the %d required engine API methods", len(t.eth1), len(requiredMethods))
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// EngineFaultKind selects how the execution proxy answers a faulted call.
+type EngineFaultKind int
+
+const (
+	// EngineFaultRPCError answers with a JSON-RPC error object.
+	EngineFaultRPCError EngineFaultKind = iota
+	// EngineFaultInvalid answers with an INVALID payload status.
+	EngineFaultInvalid
+	// EngineFaultSyncing answers with a SYNCING payload status.
+	EngineFaultSyncing
+	// EngineFaultConnectionReset drops the connection without answering.
+	EngineFaultConnectionReset
+)
+
+// EngineFault scripts error responses for engine API calls passing through
+// the execution proxy. Matching calls are answered by the proxy instead of
+// the execution client, until the fault expires; the original requests are
+// still recorded so retry behavior can be verified afterwards.
+type EngineFault struct {
+	// Method matches calls by prefix, so "engine_newPayload" covers every
+	// version of the method.
+	Method string
+
+	Kind EngineFaultKind
+
+	// ErrorCode is the JSON-RPC error code of EngineFaultRPCError responses.
+	// Zero means -32000.
+	ErrorCode int
+
+	// MaxCalls expires the fault after that many matching calls; UntilSlot
+	// expires it once the wall clock passes the slot. Zero leaves the
+	// respective bound unset; at least one must be set.
+	MaxCalls  int
+	UntilSlot common.Slot
+}
+
+// expired reports whether the fault no longer applies, given how many calls
+// it already answered and the current slot.
+func (f *EngineFault) expired(calls int, slot common.Slot) bool {
+	if f.MaxCalls > 0 && calls >= f.MaxCalls {
+		return true
+	}
+	if f.UntilSlot > 0 && slot > f.UntilSlot {
+		return true
+	}
+	return false
+}
+
+// InjectEngineFault installs a scripted fault on the given node's execution
+// proxy, replacing any previous one. The node must have
+// TrackForkchoiceUpdated set so its proxy exists.
+func (t *Testnet) InjectEngineFault(nodeIndex int, fault EngineFault) error {
+	p, ok := t.executionProxies[nodeIndex]
+	if !ok {
+		return fmt.Errorf("node %d has no execution proxy to inject a fault into", nodeIndex)
+	}
+	if fault.Method == "" {
+		return fmt.Errorf("engine fault needs a method to match")
+	}
+	if fault.MaxCalls == 0 && fault.UntilSlot == 0 {
+		return fmt.Errorf("engine fault needs an expiry: set MaxCalls or UntilSlot")
+	}
+	p.mu.Lock()
+	p.fault = &fault
+	p.faultCalls = 0
+	p.mu.Unlock()
+	t.t.Logf("node %d: %s calls will be faulted", nodeIndex, fault.Method)
+	return nil
+}
+
+// activeFault returns the fault to apply to the given calls, counting the
+// match and retiring the fault once it expires.
+func (p *ExecutionRPCProxy) activeFault(calls []executionRPCCall) *EngineFault {
+	slot := p.t.CurrentSlot()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fault == nil || p.fault.expired(p.faultCalls, slot) {
+		if p.fault != nil {
+			p.lastFault, p.fault = p.fault, nil
+			p.faultClearedAt = time.Now()
+		}
+		return nil
+	}
+	for _, call := range calls {
+		if strings.HasPrefix(call.Method, p.fault.Method) {
+			p.faultCalls++
+			return p.fault
+		}
+	}
+	return nil
+}
+
+// serveFault answers a faulted request in place of the execution client.
+func (p *ExecutionRPCProxy) serveFault(w http.ResponseWriter, fault *EngineFault, calls []executionRPCCall) {
+	if fault.Kind == EngineFaultConnectionReset {
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		// No hijacking support; an empty 500 is the closest disruption.
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var id interface{}
+	var method string
+	for _, call := range calls {
+		if strings.HasPrefix(call.Method, fault.Method) {
+			id = call.ID
+			method = call.Method
+			break
+		}
+	}
+	var result interface{}
+	switch fault.Kind {
+	case EngineFaultRPCError:
+		code := fault.ErrorCode
+		if code == 0 {
+			code = -32000
+		}
+		writeJSONRPCResponse(w, id, nil, &jsonRPCError{Code: code, Message: "injected fault"})
+		return
+	case EngineFaultInvalid, EngineFaultSyncing:
+		status := "INVALID"
+		if fault.Kind == EngineFaultSyncing {
+			status = "SYNCING"
+		}
+		payloadStatus := map[string]interface{}{
+			"status":          status,
+			"latestValidHash": nil,
+			"validationError": nil,
+		}
+		// Forkchoice updates nest the payload status one level deeper than
+		// newPayload.
+		if strings.HasPrefix(method, "engine_forkchoiceUpdated") {
+			result = map[string]interface{}{"payloadStatus": payloadStatus, "payloadId": nil}
+		} else {
+			result = payloadStatus
+		}
+	}
+	writeJSONRPCResponse(w, id, result, nil)
+}
+
+// AssertEngineFaultRecovery waits until the injected fault of the given node
+// has expired and the beacon node has completed a healthy engine call on the
+// previously faulted method again, proving it retried and recovered. Polls
+// once per slot until the context ends.
+func (t *Testnet) AssertEngineFaultRecovery(ctx context.Context, nodeIndex int) error {
+	p, ok := t.executionProxies[nodeIndex]
+	if !ok {
+		return fmt.Errorf("node %d has no execution proxy", nodeIndex)
+	}
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("node %d did not recover from the engine fault: %w", nodeIndex, ctx.Err())
+		case <-timer.C:
+			p.mu.Lock()
+			active := p.fault != nil && !p.fault.expired(p.faultCalls, t.CurrentSlot())
+			lastFault := p.lastFault
+			clearedAt := p.faultClearedAt
+			p.mu.Unlock()
+			if active {
+				t.t.Logf("engine fault of node %d still active", nodeIndex)
+				continue
+			}
+			if lastFault == nil {
+				return fmt.Errorf("node %d has no engine fault to recover from", nodeIndex)
+			}
+			for _, rec := range t.EngineCalls(nodeIndex, EngineCallFilter{}) {
+				if rec.Time.After(clearedAt) && strings.HasPrefix(rec.Method, lastFault.Method) &&
+					!rec.Faulted && rec.HTTPStatus == http.StatusOK &&
+					(rec.Status == "" || rec.Status == "VALID") {
+					t.t.Logf("node %d recovered from the %s fault: healthy %s call at slot %d",
+						nodeIndex, lastFault.Method, rec.Method, rec.Slot)
+					return nil
+				}
+			}
+			t.t.Logf("node %d has not completed a healthy %s call since the fault expired", nodeIndex, lastFault.Method)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// EngineLatencyStats summarizes the response times of one engine API method
+// on one node.
+type EngineLatencyStats struct {
+	Count int
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// EngineLatencyReport holds per-node engine latency statistics, keyed by
+// node index and then by method name. The method name carries the
+// fork-specific version, so engine_newPayloadV2 and engine_newPayloadV3 are
+// reported separately.
+type EngineLatencyReport map[int]map[string]EngineLatencyStats
+
+// latencyPercentile returns the given percentile of an ascending-sorted
+// latency slice.
+func latencyPercentile(sorted []time.Duration, percentile float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(percentile * float64(len(sorted)-1))
+	return sorted[index]
+}
+
+// EngineLatencyReport computes latency percentiles of all recorded engine
+// calls, per node and method. Faulted calls never reached the execution
+// client and are excluded. A slowly-validating execution client is the most
+// common cause of missed slots, so this is the first place to look when a
+// run degrades.
+func (t *Testnet) EngineLatencyReport() EngineLatencyReport {
+	report := make(EngineLatencyReport)
+	for nodeIndex := range t.executionProxies {
+		byMethod := make(map[string][]time.Duration)
+		for _, rec := range t.EngineCalls(nodeIndex, EngineCallFilter{}) {
+			if rec.Faulted {
+				continue
+			}
+			byMethod[rec.Method] = append(byMethod[rec.Method], rec.Latency)
+		}
+		if len(byMethod) == 0 {
+			continue
+		}
+		stats := make(map[string]EngineLatencyStats, len(byMethod))
+		for method, latencies := range byMethod {
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			stats[method] = EngineLatencyStats{
+				Count: len(latencies),
+				P50:   latencyPercentile(latencies, 0.50),
+				P90:   latencyPercentile(latencies, 0.90),
+				P99:   latencyPercentile(latencies, 0.99),
+				Max:   latencies[len(latencies)-1],
+			}
+		}
+		report[nodeIndex] = stats
+	}
+	return report
+}
+
+// engineLatencyStatus returns a status suffix naming the worst newPayload
+// latency of the last few slots across the tracked nodes, for the per-slot
+// log lines of wait helpers. It is empty when LogEngineLatency is unset or
+// no newPayload was recorded recently.
+func (t *Testnet) engineLatencyStatus() string {
+	if !t.logEngineLatency {
+		return ""
+	}
+	currentSlot := t.CurrentSlot()
+	fromSlot := common.Slot(0)
+	if currentSlot > 2 {
+		fromSlot = currentSlot - 2
+	}
+	var worst time.Duration
+	worstNode := -1
+	for nodeIndex := range t.executionProxies {
+		for _, rec := range t.EngineCalls(nodeIndex, EngineCallFilter{FromSlot: fromSlot}) {
+			if strings.HasPrefix(rec.Method, "engine_newPayload") && rec.Latency > worst {
+				worst = rec.Latency
+				worstNode = nodeIndex
+			}
+		}
+	}
+	if worstNode < 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (worst recent newPayload %v on node %d)", worst, worstNode)
+}
+
+// WriteEngineLatencyCSV writes every recorded engine call as one CSV row,
+// for offline analysis of a run.
+func (t *Testnet) WriteEngineLatencyCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"node", "slot", "method", "latency_ms", "injected_ms", "http_status", "status", "faulted", "time"}); err != nil {
+		return err
+	}
+	for nodeIndex := range t.executionProxies {
+		for _, rec := range t.EngineCalls(nodeIndex, EngineCallFilter{}) {
+			row := []string{
+				strconv.Itoa(nodeIndex),
+				strconv.FormatUint(uint64(rec.Slot), 10),
+				rec.Method,
+				strconv.FormatFloat(float64(rec.Latency)/float64(time.Millisecond), 'f', 3, 64),
+				strconv.FormatFloat(float64(rec.InjectedLatency)/float64(time.Millisecond), 'f', 3, 64),
+				strconv.Itoa(rec.HTTPStatus),
+				rec.Status,
+				strconv.FormatBool(rec.Faulted),
+				rec.Time.Format(time.RFC3339Nano),
+			}
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// Stop shuts down the in-process helpers of the testnet — mock builders and
+// execution proxies — and exports the engine latency records as CSV for
+// offline analysis. Tests call it once they are done with the network.
+func (t *Testnet) Stop() {
+	for i, builder := range t.mockBuilders {
+		if err := builder.Close(); err != nil {
+			t.t.Logf("failed to stop builder of node %d: %v", i, err)
+		}
+	}
+	if len(t.executionProxies) == 0 {
+		return
+	}
+	file, err := os.CreateTemp("", "engine-latency-*.csv")
+	if err != nil {
+		t.t.Logf("failed to export engine latency CSV: %v", err)
+	} else {
+		if err := t.WriteEngineLatencyCSV(file); err != nil {
+			t.t.Logf("failed to export engine latency CSV: %v", err)
+		} else {
+			t.t.Logf("engine latency records exported to %s", file.Name())
+		}
+		file.Close()
+	}
+	for _, p := range t.executionProxies {
+		p.listener.Close()
+	}
+	for nodeIndex, stats := range t.EngineLatencyReport() {
+		for method, s := range stats {
+			t.t.Logf("node %d %s: %d calls, p50 %v, p90 %v, p99 %v, max %v",
+				nodeIndex, method, s.Count, s.P50, s.P90, s.P99, s.Max)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// EngineResponseMutator rewrites the result of an engine API response before
+// it reaches the beacon node. Returning the input unchanged applies no
+// mutation.
+type EngineResponseMutator func(json.RawMessage) json.RawMessage
+
+// EngineRequestMutator rewrites the params of an engine API call before they
+// reach the execution client.
+type EngineRequestMutator func([]json.RawMessage) []json.RawMessage
+
+// EngineMutationRecord is one mutation the execution proxy applied in
+// flight, so verification code can assert the network rejected exactly the
+// tampered payloads and nothing else.
+type EngineMutationRecord struct {
+	Time time.Time
+	Slot common.Slot
+	// Method is the mutated call; Kind is "request" or "response".
+	Method string
+	Kind   string
+}
+
+// WithResponseMutator installs a mutator on the proxy for every method
+// matching the given prefix, and returns the proxy for chaining. It is also
+// reachable at startup through the EngineResponseMutators config knobs.
+func (p *ExecutionRPCProxy) WithResponseMutator(method string, m EngineResponseMutator) *ExecutionRPCProxy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.responseMutators == nil {
+		p.responseMutators = make(map[string]EngineResponseMutator)
+	}
+	p.responseMutators[method] = m
+	return p
+}
+
+// WithRequestMutator installs a params mutator on the proxy for every method
+// matching the given prefix, and returns the proxy for chaining.
+func (p *ExecutionRPCProxy) WithRequestMutator(method string, m EngineRequestMutator) *ExecutionRPCProxy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.requestMutators == nil {
+		p.requestMutators = make(map[string]EngineRequestMutator)
+	}
+	p.requestMutators[method] = m
+	return p
+}
+
+// ExecutionProxyAt returns the execution RPC proxy of the given node, with
+// an error for nodes that do not have one.
+func (t *Testnet) ExecutionProxyAt(nodeIndex int) (*ExecutionRPCProxy, error) {
+	if p, ok := t.executionProxies[nodeIndex]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("node %d has no execution proxy", nodeIndex)
+}
+
+// AppliedEngineMutations returns the mutations the given node's proxy has
+// applied so far, oldest first.
+func (t *Testnet) AppliedEngineMutations(nodeIndex int) []EngineMutationRecord {
+	p, ok := t.executionProxies[nodeIndex]
+	if !ok {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]EngineMutationRecord(nil), p.mutations...)
+}
+
+// flipHexNibble corrupts a 0x-prefixed hex string by flipping the nibble
+// right after the prefix, leaving the length and shape intact.
+func flipHexNibble(s string) string {
+	if len(s) < 3 {
+		return s
+	}
+	flipped := "0"
+	if s[2] == '0' {
+		flipped = "1"
+	}
+	return s[:2] + flipped + s[3:]
+}
+
+// corruptHexJSONField flips a nibble of the named hex field in a JSON
+// object, descending into a nested executionPayload when the field is not at
+// the top level. It reports whether a mutation was applied.
+func corruptHexJSONField(raw json.RawMessage, field string) (json.RawMessage, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return raw, false
+	}
+	if valueRaw, ok := fields[field]; ok {
+		var value string
+		if err := json.Unmarshal(valueRaw, &value); err != nil {
+			return raw, false
+		}
+		mutatedValue, err := json.Marshal(flipHexNibble(value))
+		if err != nil {
+			return raw, false
+		}
+		fields[field] = mutatedValue
+	} else if payload, ok := fields["executionPayload"]; ok {
+		mutatedPayload, applied := corruptHexJSONField(payload, field)
+		if !applied {
+			return raw, false
+		}
+		fields["executionPayload"] = mutatedPayload
+	} else {
+		return raw, false
+	}
+	mutated, err := json.Marshal(fields)
+	if err != nil {
+		return raw, false
+	}
+	return mutated, true
+}
+
+// CorruptStateRootMutator returns a response mutator that flips a nibble of
+// the state root in a payload-carrying result, e.g. of getPayload, so the
+// payload no longer passes state verification.
+func CorruptStateRootMutator() EngineResponseMutator {
+	return func(result json.RawMessage) json.RawMessage {
+		mutated, _ := corruptHexJSONField(result, "stateRoot")
+		return mutated
+	}
+}
+
+// CorruptBlockHashMutator returns a response mutator that flips a nibble of
+// the block hash in a payload-carrying result, making the hash inconsistent
+// with the block contents.
+func CorruptBlockHashMutator() EngineResponseMutator {
+	return func(result json.RawMessage) json.RawMessage {
+		mutated, _ := corruptHexJSONField(result, "blockHash")
+		return mutated
+	}
+}
+
+// CorruptTransactionMutator returns a request mutator for newPayload calls
+// that flips a byte of the first transaction in the payload params, so the
+// execution client receives a payload that does not match its block hash.
+func CorruptTransactionMutator() EngineRequestMutator {
+	return func(params []json.RawMessage) []json.RawMessage {
+		if len(params) == 0 {
+			return params
+		}
+		var payload map[string]json.RawMessage
+		if err := json.Unmarshal(params[0], &payload); err != nil {
+			return params
+		}
+		var txs []string
+		if err := json.Unmarshal(payload["transactions"], &txs); err != nil || len(txs) == 0 {
+			return params
+		}
+		txs[0] = flipHexNibble(txs[0])
+		txsRaw, err := json.Marshal(txs)
+		if err != nil {
+			return params
+		}
+		payload["transactions"] = txsRaw
+		mutated, err := json.Marshal(payload)
+		if err != nil {
+			return params
+		}
+		out := append([]json.RawMessage(nil), params...)
+		out[0] = mutated
+		return out
+	}
+}
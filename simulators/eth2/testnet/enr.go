@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/protolambda/eth2api/client/nodeapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+
+	"github.com/protolambda/eth2api"
+)
+
+// eth2ENREntry is the raw "eth2" key of a beacon node's ENR: the SSZ-encoded
+// ENRForkID of fork digest, next fork version and next fork epoch.
+type eth2ENREntry []byte
+
+func (eth2ENREntry) ENRKey() string { return "eth2" }
+
+// nextScheduledFork returns the version and epoch of the first fork scheduled
+// after the given epoch. With nothing left on the schedule it returns the
+// current version and FAR_FUTURE_EPOCH, matching what the ENRForkID of a
+// fully forked node carries.
+func (t *Testnet) nextScheduledFork(epoch common.Epoch) (common.Version, common.Epoch) {
+	forks := []struct {
+		epoch   common.Epoch
+		version common.Version
+	}{
+		{t.spec.ALTAIR_FORK_EPOCH, t.spec.ALTAIR_FORK_VERSION},
+		{t.spec.BELLATRIX_FORK_EPOCH, t.spec.BELLATRIX_FORK_VERSION},
+		{t.spec.CAPELLA_FORK_EPOCH, t.spec.CAPELLA_FORK_VERSION},
+		{t.spec.DENEB_FORK_EPOCH, t.spec.DENEB_FORK_VERSION},
+		{t.spec.ELECTRA_FORK_EPOCH, t.spec.ELECTRA_FORK_VERSION},
+	}
+	next := common.Epoch(common.FAR_FUTURE_EPOCH)
+	var version common.Version
+	found := false
+	for _, fork := range forks {
+		if fork.epoch > epoch && fork.epoch < next {
+			next, version, found = fork.epoch, fork.version, true
+		}
+	}
+	if !found {
+		slot, _ := t.spec.EpochStartSlot(epoch)
+		return t.spec.ForkVersion(slot), common.FAR_FUTURE_EPOCH
+	}
+	return version, next
+}
+
+// VerifyENRRecords checks that every beacon node advertises the right eth2
+// entry in its ENR: the fork digest of the current fork at this testnet's
+// genesis validators root, and the next fork version of the configured
+// schedule. A stale digest makes discovery silently useless, since honest
+// peers score the node as belonging to a different network. All mismatching
+// nodes are listed in one error.
+func (t *Testnet) VerifyENRRecords(ctx context.Context) error {
+	currentEpoch := t.CurrentEpoch()
+	expectedDigest := common.ComputeForkDigest(t.spec.ForkVersion(t.CurrentSlot()), t.genesisValidatorsRoot)
+	expectedNextVersion, _ := t.nextScheduledFork(currentEpoch)
+
+	var issues []string
+	for i, bn := range t.beacons {
+		var identity eth2api.NetworkIdentity
+		if err := nodeapi.Identity(ctx, bn.API, &identity); err != nil {
+			issues = append(issues, fmt.Sprintf("node %d: failed to get identity: %v", i, err))
+			continue
+		}
+		record, err := enode.Parse(enode.ValidSchemes, identity.ENR)
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("node %d: undecodable ENR %q: %v", i, identity.ENR, err))
+			continue
+		}
+		var entry eth2ENREntry
+		if err := record.Record().Load(&entry); err != nil {
+			issues = append(issues, fmt.Sprintf("node %d: ENR has no eth2 entry: %v", i, err))
+			continue
+		}
+		if len(entry) < 16 {
+			issues = append(issues, fmt.Sprintf("node %d: eth2 ENR entry is %d bytes, want 16", i, len(entry)))
+			continue
+		}
+		if !bytes.Equal(entry[0:4], expectedDigest[:]) {
+			issues = append(issues, fmt.Sprintf("node %d: ENR fork digest is %x, want %s", i, entry[0:4], expectedDigest))
+		}
+		if !bytes.Equal(entry[4:8], expectedNextVersion[:]) {
+			issues = append(issues, fmt.Sprintf("node %d: ENR next fork version is %x, want %s", i, entry[4:8], expectedNextVersion))
+		}
+		_ = binary.LittleEndian.Uint64(entry[8:16]) // next fork epoch, informational
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("bad ENR records: %s", strings.Join(issues, "; "))
+	}
+	t.t.Logf("all %d nodes advertise fork digest %s in their ENR", len(t.beacons), expectedDigest)
+	return nil
+}
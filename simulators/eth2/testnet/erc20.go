@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+// erc20TransferTopic is keccak256("Transfer(address,address,uint256)"), the
+// topic of the ERC-20 Transfer event.
+var erc20TransferTopic = ethcommon.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+
+// erc20DeployCode is the creation bytecode of a minimal hand-assembled ERC-20
+// token: balances are stored keyed by holder address, the constructor assigns
+// the appended total supply to the deployer, and the runtime implements
+// transfer(address,uint256) with a balance check and Transfer event, plus
+// balanceOf(address). Enough token mechanics for execution-layer tests,
+// without pulling a compiler into the dependency set.
+const erc20DeployCode = "6020803803600039600051335560888060186000396000f3" + // constructor: sstore(caller, supply), return runtime
+	"60003560e01c8063a9059cbb1461002d57806370a082311461002057600080fd" + // selector dispatch
+	"5b6004355460005260206000f3" + // balanceOf(address)
+	"5b602435335481811061008357" + // transfer: load amount and sender balance, underflow check
+	"033355" + // debit sender
+	"6024356004355401600435" + "55" + // credit recipient
+	"602435600052600435337fddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef60206000a3" + // emit Transfer
+	"600160005260206000f3" + // return true
+	"5b600080fd" // underflow revert
+
+// DeployERC20 deploys the minimal ERC-20 token through the given node's
+// execution client, minting totalSupply to the pre-funded test account. It
+// waits for the deployment receipt and returns the contract address along
+// with the deployment transaction hash.
+func (t *Testnet) DeployERC20(ctx context.Context, nodeIndex int, totalSupply *big.Int) (ethcommon.Address, ethcommon.Hash, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.eth1) {
+		return ethcommon.Address{}, ethcommon.Hash{}, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	code, err := hex.DecodeString(erc20DeployCode)
+	if err != nil {
+		return ethcommon.Address{}, ethcommon.Hash{}, fmt.Errorf("bad ERC-20 bytecode: %w", err)
+	}
+	var supply [32]byte
+	totalSupply.FillBytes(supply[:])
+	node := t.eth1[nodeIndex]
+	txHash, err := sendTransactionFrom(ctx, node, eth1TestAccountKey, nil, big.NewInt(0), append(code, supply[:]...))
+	if err != nil {
+		return ethcommon.Address{}, ethcommon.Hash{}, fmt.Errorf("failed to deploy ERC-20: %w", err)
+	}
+
+	client := node.EthClient()
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ethcommon.Address{}, txHash, ctx.Err()
+		case <-timer.C:
+			receipt, err := client.TransactionReceipt(ctx, txHash)
+			if err != nil || receipt == nil {
+				t.t.Logf("ERC-20 deployment %s not yet included", txHash)
+				continue
+			}
+			if receipt.Status != 1 {
+				return ethcommon.Address{}, txHash, fmt.Errorf("ERC-20 deployment %s reverted", txHash)
+			}
+			t.t.Logf("ERC-20 token deployed at %s with supply %s", receipt.ContractAddress, totalSupply)
+			return receipt.ContractAddress, txHash, nil
+		}
+	}
+}
+
+// WaitForERC20Transfer polls the given node's execution client for an ERC-20
+// Transfer event on the contract, from and to the given addresses, of at
+// least minAmount tokens. It returns the hash of the transaction that emitted
+// the first matching event. Nil minAmount matches any amount.
+func (t *Testnet) WaitForERC20Transfer(ctx context.Context, nodeIndex int, contractAddress ethcommon.Address, from, to ethcommon.Address, minAmount *big.Int) (ethcommon.Hash, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.eth1) {
+		return ethcommon.Hash{}, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	client := t.eth1[nodeIndex].EthClient()
+	query := ethereum.FilterQuery{
+		Addresses: []ethcommon.Address{contractAddress},
+		Topics: [][]ethcommon.Hash{
+			{erc20TransferTopic},
+			{ethcommon.BytesToHash(from.Bytes())},
+			{ethcommon.BytesToHash(to.Bytes())},
+		},
+	}
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ethcommon.Hash{}, ctx.Err()
+		case <-timer.C:
+			logs, err := client.FilterLogs(ctx, query)
+			if err != nil {
+				t.t.Logf("failed to poll Transfer logs of %s: %v", contractAddress, err)
+				continue
+			}
+			for _, entry := range logs {
+				amount := new(big.Int).SetBytes(entry.Data)
+				if minAmount != nil && amount.Cmp(minAmount) < 0 {
+					continue
+				}
+				t.t.Logf("token transfer of %s from %s to %s in transaction %s", amount, from, to, entry.TxHash)
+				return entry.TxHash, nil
+			}
+			t.t.Logf("no matching Transfer event on %s yet", contractAddress)
+		}
+	}
+}
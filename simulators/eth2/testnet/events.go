@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// BeaconEvent is a single event received from a beacon node's SSE stream.
+type BeaconEvent struct {
+	Topic string
+	Data  json.RawMessage
+	// Slot is extracted from the event data, when the topic carries one.
+	Slot common.Slot
+}
+
+// eventWaitConfig collects the options of WaitForBeaconEvent.
+type eventWaitConfig struct {
+	fallbackPolling bool
+}
+
+// EventWaitOption configures WaitForBeaconEvent.
+type EventWaitOption func(*eventWaitConfig)
+
+// WithFallbackPolling makes WaitForBeaconEvent fall back to slot polling
+// when the beacon node does not support SSE streams.
+func WithFallbackPolling(enabled bool) EventWaitOption {
+	return func(cfg *eventWaitConfig) { cfg.fallbackPolling = enabled }
+}
+
+// WaitForBeaconEvent subscribes to the SSE event stream of the given node
+// for the given topics (e.g. "head", "finalized_checkpoint",
+// "attester_slashing") and returns the first event for which predicate
+// returns true. Unlike the polling based WaitFor* helpers, this reacts to
+// events that fire at unpredictable times without a polling delay.
+func (t *Testnet) WaitForBeaconEvent(ctx context.Context, nodeIndex int, topics []string, predicate func(event BeaconEvent) bool, opts ...EventWaitOption) (*BeaconEvent, error) {
+	var cfg eventWaitConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return nil, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	bn := t.beacons[nodeIndex]
+
+	event, err := streamBeaconEvents(ctx, bn, topics, predicate)
+	if err != nil && cfg.fallbackPolling {
+		t.t.Logf("SSE stream of node %d unavailable (%v), falling back to polling", nodeIndex, err)
+		return t.pollForBeaconEvent(ctx, bn, topics, predicate)
+	}
+	return event, err
+}
+
+// streamBeaconEvents reads the SSE stream of a beacon node and returns the
+// first event matching the predicate.
+func streamBeaconEvents(ctx context.Context, bn *BeaconNode, topics []string, predicate func(event BeaconEvent) bool) (*BeaconEvent, error) {
+	url := fmt.Sprintf("%s/eth/v1/events?topics=%s", bn.API.Addr, strings.Join(topics, ","))
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	// The stream is long-lived, so bypass the regular API client timeout.
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event stream: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("event stream request returned status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var current BeaconEvent
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			current.Topic = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			current.Data = json.RawMessage(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		case line == "":
+			if current.Topic != "" && current.Data != nil {
+				current.Slot = eventSlot(current.Data)
+				if predicate(current) {
+					event := current
+					return &event, nil
+				}
+			}
+			current = BeaconEvent{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("event stream interrupted: %w", err)
+	}
+	return nil, fmt.Errorf("event stream closed before a matching event")
+}
+
+// eventSlot extracts the slot field of an event payload, if it has one.
+func eventSlot(data json.RawMessage) common.Slot {
+	var payload struct {
+		Slot common.Slot `json:"slot"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return 0
+	}
+	return payload.Slot
+}
+
+// WaitForFinalitySSE is the event-driven counterpart of WaitForFinality: it
+// subscribes to the finalized_checkpoint SSE stream of every beacon node and
+// returns as soon as all of them have emitted a finalized checkpoint beyond
+// genesis, without the up-to-a-slot polling delay. It returns the most
+// advanced of the reported checkpoints.
+func (t *Testnet) WaitForFinalitySSE(ctx context.Context) (common.Checkpoint, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type nodeCheckpoint struct {
+		node       int
+		checkpoint common.Checkpoint
+	}
+	checkpoints := make(chan nodeCheckpoint)
+	for i := range t.beacons {
+		i := i
+		go t.streamFinalizedCheckpoints(ctx, i, func(checkpoint common.Checkpoint) {
+			select {
+			case checkpoints <- nodeCheckpoint{i, checkpoint}:
+			case <-ctx.Done():
+			}
+		})
+	}
+
+	latest := make([]common.Checkpoint, len(t.beacons))
+	for {
+		select {
+		case <-ctx.Done():
+			return common.Checkpoint{}, ctx.Err()
+		case cp := <-checkpoints:
+			latest[cp.node] = cp.checkpoint
+			best := latest[0]
+			allFinal := true
+			for _, checkpoint := range latest {
+				if checkpoint == (common.Checkpoint{}) {
+					allFinal = false
+					break
+				}
+				if checkpoint.Epoch > best.Epoch {
+					best = checkpoint
+				}
+			}
+			if allFinal {
+				return best, nil
+			}
+		}
+	}
+}
+
+// streamFinalizedCheckpoints feeds the finalized_checkpoint events of one
+// beacon node into report, reconnecting with exponential backoff whenever
+// the stream drops, until the context is canceled.
+func (t *Testnet) streamFinalizedCheckpoints(ctx context.Context, nodeIndex int, report func(common.Checkpoint)) {
+	const (
+		initialBackoff = time.Second
+		maxBackoff     = 16 * time.Second
+	)
+	backoff := initialBackoff
+	for ctx.Err() == nil {
+		_, err := streamBeaconEvents(ctx, t.beacons[nodeIndex], []string{"finalized_checkpoint"}, func(event BeaconEvent) bool {
+			var payload struct {
+				Block common.Root  `json:"block"`
+				Epoch common.Epoch `json:"epoch"`
+			}
+			if err := json.Unmarshal(event.Data, &payload); err == nil && payload.Epoch > 0 {
+				// A received event means the connection is healthy again.
+				backoff = initialBackoff
+				report(common.Checkpoint{Root: payload.Block, Epoch: payload.Epoch})
+			}
+			return false
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		t.t.Logf("finality event stream of node %d dropped (%v), reconnecting in %v", nodeIndex, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// pollForBeaconEvent emulates the head and finalized_checkpoint topics by
+// polling once per slot, for beacon nodes without SSE support.
+func (t *Testnet) pollForBeaconEvent(ctx context.Context, bn *BeaconNode, topics []string, predicate func(event BeaconEvent) bool) (*BeaconEvent, error) {
+	wantTopic := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		switch topic {
+		case "head", "finalized_checkpoint":
+			wantTopic[topic] = true
+		default:
+			t.t.Logf("warning: topic %q is not available via fallback polling", topic)
+		}
+	}
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	var (
+		lastHead      common.Root
+		lastFinalized common.Checkpoint
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			if wantTopic["head"] {
+				var header eth2api.BeaconBlockHeaderAndInfo
+				if exists, err := beaconapi.BlockHeader(ctx, bn.API, eth2api.BlockHead, &header); err == nil && exists && header.Root != lastHead {
+					lastHead = header.Root
+					data, _ := json.Marshal(map[string]interface{}{
+						"slot":  header.Header.Message.Slot,
+						"block": header.Root,
+					})
+					event := BeaconEvent{Topic: "head", Data: data, Slot: header.Header.Message.Slot}
+					if predicate(event) {
+						return &event, nil
+					}
+				}
+			}
+			if wantTopic["finalized_checkpoint"] {
+				var checkpoints eth2api.FinalityCheckpoints
+				if exists, err := beaconapi.FinalityCheckpoints(ctx, bn.API, eth2api.StateHead, &checkpoints); err == nil && exists &&
+					checkpoints.Finalized != lastFinalized && checkpoints.Finalized != (common.Checkpoint{}) {
+					lastFinalized = checkpoints.Finalized
+					data, _ := json.Marshal(map[string]interface{}{
+						"block": checkpoints.Finalized.Root,
+						"epoch": checkpoints.Finalized.Epoch,
+					})
+					event := BeaconEvent{Topic: "finalized_checkpoint", Data: data}
+					if predicate(event) {
+						return &event, nil
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// eth1ChainID is the chain id of the execution chain of the testnet.
+var eth1ChainID = big.NewInt(7)
+
+// eth1TestAccountKey is the secret key of the pre-funded account that the
+// execution genesis of the client images includes for test transactions.
+// Its address is 0xa94f5374Fce5edBC8E2a8697C15331677e6EbF0B.
+var eth1TestAccountKey, _ = crypto.HexToECDSA("45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+
+// EthClient returns a go-ethereum client connected to the node's JSON-RPC
+// endpoint.
+func (n *Eth1Node) EthClient() *ethclient.Client {
+	return ethclient.NewClient(n.RPC())
+}
+
+// executionHead is the part of an eth_getBlockByNumber response that head
+// comparisons care about.
+type executionHead struct {
+	Number    hexutil.Uint64 `json:"number"`
+	Hash      ethcommon.Hash `json:"hash"`
+	StateRoot ethcommon.Hash `json:"stateRoot"`
+	BaseFee   *hexutil.Big   `json:"baseFeePerGas"`
+}
+
+// executionHeadOf returns the latest block of one execution client.
+func executionHeadOf(ctx context.Context, node *Eth1Node) (executionHead, error) {
+	var head executionHead
+	if err := node.RPC().CallContext(ctx, &head, "eth_getBlockByNumber", "latest", false); err != nil {
+		return executionHead{}, err
+	}
+	return head, nil
+}
+
+// CompareExecutionHeads checks that all execution clients report the same
+// latest block. The beacon layer can look healthy for a while after the
+// Engine API or fork choice delivered different payloads to different
+// execution clients, so this catches execution-layer splits directly. On
+// disagreement the full per-node comparison is reported.
+func (t *Testnet) CompareExecutionHeads(ctx context.Context) error {
+	heads := make([]executionHead, len(t.eth1))
+	for i, node := range t.eth1 {
+		head, err := executionHeadOf(ctx, node)
+		if err != nil {
+			return fmt.Errorf("failed to get latest block of execution client %d: %w", i, err)
+		}
+		heads[i] = head
+	}
+	split := false
+	for i := 1; i < len(heads); i++ {
+		if heads[i].Hash != heads[0].Hash || heads[i].StateRoot != heads[0].StateRoot {
+			split = true
+			break
+		}
+	}
+	if !split {
+		t.t.Logf("all %d execution clients agree on head block %d (%s)", len(heads), heads[0].Number, heads[0].Hash)
+		return nil
+	}
+	comparison := make([]string, len(heads))
+	for i, head := range heads {
+		comparison[i] = fmt.Sprintf("node %d: block %d, hash %s, state root %s", i, head.Number, head.Hash, head.StateRoot)
+	}
+	return fmt.Errorf("execution clients diverge: %s", strings.Join(comparison, "; "))
+}
+
+// WaitForExecutionClientsSync polls once per slot until every execution
+// client agrees on the same head block, per CompareExecutionHeads.
+func (t *Testnet) WaitForExecutionClientsSync(ctx context.Context) error {
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			if err := t.CompareExecutionHeads(ctx); err != nil {
+				t.t.Logf("execution clients not in agreement yet: %v", err)
+				continue
+			}
+			return nil
+		}
+	}
+}
+
+// WaitForExecutionBlockNumber polls the given execution client once per slot
+// until its chain reaches the given block number, and returns the header of
+// that block. Tests that place assertions at a specific execution height —
+// e.g. a terminal block expected at a known number — wait on this instead of
+// sleeping.
+func (t *Testnet) WaitForExecutionBlockNumber(ctx context.Context, nodeIndex int, blockNumber uint64) (*types.Header, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.eth1) {
+		return nil, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	client := t.eth1[nodeIndex].EthClient()
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			latest, err := client.BlockNumber(ctx)
+			if err != nil {
+				t.t.Logf("failed to poll block number of execution client %d: %v", nodeIndex, err)
+				continue
+			}
+			if latest < blockNumber {
+				t.t.Logf("execution client %d at block %d, waiting for %d", nodeIndex, latest, blockNumber)
+				continue
+			}
+			header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(blockNumber))
+			if err != nil {
+				return nil, fmt.Errorf("failed to get header %d from execution client %d: %w", blockNumber, nodeIndex, err)
+			}
+			t.t.Logf("execution client %d reached block %d (%s)", nodeIndex, blockNumber, header.Hash())
+			return header, nil
+		}
+	}
+}
+
+// WaitForExecutionChainLength waits until the execution chain is at least
+// length blocks long, counting the genesis block. Pre-merge setups use it to
+// let the chain grow enough proof-of-work blocks to reach the terminal total
+// difficulty before consensus-side assertions start.
+func (t *Testnet) WaitForExecutionChainLength(ctx context.Context, length uint64) error {
+	if length == 0 {
+		return nil
+	}
+	_, err := t.WaitForExecutionBlockNumber(ctx, 0, length-1)
+	return err
+}
+
+// injectedTxTip is the priority fee injected transactions bid, in wei.
+var injectedTxTip = big.NewInt(1_000_000_000)
+
+// GetTestFundedAccount returns the key and address of the pre-funded account
+// of the execution genesis, usable for test transactions through the given
+// node.
+func (t *Testnet) GetTestFundedAccount(nodeIndex int) (*ecdsa.PrivateKey, ethcommon.Address, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.eth1) {
+		return nil, ethcommon.Address{}, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	return eth1TestAccountKey, crypto.PubkeyToAddress(eth1TestAccountKey.PublicKey), nil
+}
+
+// InjectExecutionTransaction signs and submits an EIP-1559 transaction from
+// the pre-funded test account through the execution client of the given
+// node: a plain transfer, or a contract call when data is set. Gas is
+// estimated when gasLimit is zero, and the fee bid is twice the current base
+// fee so ordinary fee drift does not strand the transaction. It returns the
+// transaction hash.
+func (t *Testnet) InjectExecutionTransaction(ctx context.Context, nodeIndex int, to ethcommon.Address, value *big.Int, data []byte, gasLimit uint64) (ethcommon.Hash, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.eth1) {
+		return ethcommon.Hash{}, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	node := t.eth1[nodeIndex]
+	client := node.EthClient()
+	key, sender, err := t.GetTestFundedAccount(nodeIndex)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	nonce, err := client.PendingNonceAt(ctx, sender)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to get nonce of account %s: %w", sender, err)
+	}
+	if gasLimit == 0 {
+		gasLimit, err = client.EstimateGas(ctx, ethereum.CallMsg{From: sender, To: &to, Value: value, Data: data})
+		if err != nil {
+			return ethcommon.Hash{}, fmt.Errorf("failed to estimate gas: %w", err)
+		}
+	}
+	head, err := executionHeadOf(ctx, node)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to get latest block for the base fee: %w", err)
+	}
+	if head.BaseFee == nil {
+		return ethcommon.Hash{}, fmt.Errorf("execution client %d reports no base fee, chain not running EIP-1559", nodeIndex)
+	}
+	maxFee := new(big.Int).Mul((*big.Int)(head.BaseFee), big.NewInt(2))
+	if maxFee.Cmp(injectedTxTip) < 0 {
+		maxFee.Set(injectedTxTip)
+	}
+	raw, hash, err := signedDynamicFeeTransaction(key, nonce, injectedTxTip, maxFee, gasLimit, to, value, data)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	if err := node.RPC().CallContext(ctx, nil, "eth_sendRawTransaction", hexEncode(raw)); err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to send transaction: %w", err)
+	}
+	t.t.Logf("injected transaction %s: %s -> %s, value %s, gas %d", hash, sender, to, value, gasLimit)
+	return hash, nil
+}
+
+// signedDynamicFeeTransaction assembles a signed EIP-1559 transaction: the
+// 0x02 type byte followed by the RLP of the transaction payload. Like the
+// blob transactions of the spammer, the encoding is spelled out here because
+// the dependency set's transaction types predate type-2. It returns the raw
+// bytes and the transaction hash.
+func signedDynamicFeeTransaction(key *ecdsa.PrivateKey, nonce uint64, tip, maxFee *big.Int, gas uint64, to ethcommon.Address, value *big.Int, data []byte) ([]byte, ethcommon.Hash, error) {
+	if value == nil {
+		value = big.NewInt(0)
+	}
+	payload := []interface{}{
+		eth1ChainID,
+		nonce,
+		tip,
+		maxFee,
+		gas,
+		to,
+		value,
+		data,
+		[]interface{}{}, // access list
+	}
+	unsigned, err := rlp.EncodeToBytes(payload)
+	if err != nil {
+		return nil, ethcommon.Hash{}, err
+	}
+	sigHash := crypto.Keccak256(append([]byte{0x02}, unsigned...))
+	sig, err := crypto.Sign(sigHash, key)
+	if err != nil {
+		return nil, ethcommon.Hash{}, err
+	}
+	signed := append(payload,
+		uint64(sig[64]),
+		new(big.Int).SetBytes(sig[:32]),
+		new(big.Int).SetBytes(sig[32:64]),
+	)
+	raw, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		return nil, ethcommon.Hash{}, err
+	}
+	raw = append([]byte{0x02}, raw...)
+	return raw, ethcommon.BytesToHash(crypto.Keccak256(raw)), nil
+}
+
+// sendTestTransaction signs and submits a transaction from the pre-funded
+// test account through the given execution node, returning the transaction
+// hash.
+func sendTestTransaction(ctx context.Context, node *Eth1Node, to *ethcommon.Address, value *big.Int, data []byte) (ethcommon.Hash, error) {
+	return sendTransactionFrom(ctx, node, eth1TestAccountKey, to, value, data)
+}
+
+// sendTransactionFrom signs and submits a transaction from the account of
+// the given key through the given execution node, returning the transaction
+// hash.
+func sendTransactionFrom(ctx context.Context, node *Eth1Node, key *ecdsa.PrivateKey, to *ethcommon.Address, value *big.Int, data []byte) (ethcommon.Hash, error) {
+	client := node.EthClient()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	nonce, err := client.PendingNonceAt(ctx, sender)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to get nonce of account %s: %w", sender, err)
+	}
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to get gas price: %w", err)
+	}
+	gas, err := client.EstimateGas(ctx, ethereum.CallMsg{From: sender, To: to, Value: value, Data: data})
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to estimate gas: %w", err)
+	}
+	var tx *types.Transaction
+	if to != nil {
+		tx = types.NewTransaction(nonce, *to, value, gas, gasPrice, data)
+	} else {
+		tx = types.NewContractCreation(nonce, value, gas, gasPrice, data)
+	}
+	signed, err := types.SignTx(tx, types.NewEIP155Signer(eth1ChainID), key)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	if err := client.SendTransaction(ctx, signed); err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("failed to send transaction: %w", err)
+	}
+	return signed.Hash(), nil
+}
+
+// parentChainBatchSize is how many blocks AssertParentHashChain fetches per
+// concurrent batch request.
+const parentChainBatchSize = 10
+
+// chainLinkHeader is the part of an execution block header the parent hash
+// chain check reads.
+type chainLinkHeader struct {
+	Number     hexutil.Uint64 `json:"number"`
+	Hash       ethcommon.Hash `json:"hash"`
+	ParentHash ethcommon.Hash `json:"parentHash"`
+}
+
+// AssertParentHashChain verifies that the execution blocks in
+// [fromBlock, toBlock] on the given node form an unbroken hash chain: every
+// block's parent hash must be the hash of the block before it. Blocks are
+// fetched in concurrent batches, and all broken links in the range are
+// reported, not just the first — a mis-assembled sequence from a mock or
+// proxy typically breaks several.
+func (t *Testnet) AssertParentHashChain(ctx context.Context, nodeIndex int, fromBlock, toBlock uint64) error {
+	if nodeIndex < 0 || nodeIndex >= len(t.eth1) {
+		return fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	if toBlock < fromBlock {
+		return fmt.Errorf("empty block range %d-%d", fromBlock, toBlock)
+	}
+	count := toBlock - fromBlock + 1
+	headers := make([]chainLinkHeader, count)
+	rpcClient := t.eth1[nodeIndex].RPC()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var fetchIssues []string
+	for start := uint64(0); start < count; start += parentChainBatchSize {
+		end := start + parentChainBatchSize
+		if end > count {
+			end = count
+		}
+		wg.Add(1)
+		go func(start, end uint64) {
+			defer wg.Done()
+			batch := make([]rpc.BatchElem, end-start)
+			for i := range batch {
+				batch[i] = rpc.BatchElem{
+					Method: "eth_getBlockByNumber",
+					Args:   []interface{}{hexutil.EncodeUint64(fromBlock + start + uint64(i)), false},
+					Result: &headers[start+uint64(i)],
+				}
+			}
+			if err := rpcClient.BatchCallContext(ctx, batch); err != nil {
+				mu.Lock()
+				fetchIssues = append(fetchIssues, fmt.Sprintf("blocks %d-%d: %v", fromBlock+start, fromBlock+end-1, err))
+				mu.Unlock()
+				return
+			}
+			for i, elem := range batch {
+				if elem.Error != nil {
+					mu.Lock()
+					fetchIssues = append(fetchIssues, fmt.Sprintf("block %d: %v", fromBlock+start+uint64(i), elem.Error))
+					mu.Unlock()
+				}
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	if len(fetchIssues) > 0 {
+		return fmt.Errorf("failed to fetch blocks of node %d: %s", nodeIndex, strings.Join(fetchIssues, "; "))
+	}
+
+	var broken []string
+	for i := uint64(1); i < count; i++ {
+		if headers[i].ParentHash != headers[i-1].Hash {
+			broken = append(broken, fmt.Sprintf("block %d has parent %s, block %d is %s",
+				fromBlock+i, headers[i].ParentHash, fromBlock+i-1, headers[i-1].Hash))
+		}
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("parent hash chain of node %d is broken: %s", nodeIndex, strings.Join(broken, "; "))
+	}
+	t.t.Logf("parent hash chain of node %d intact over blocks %d-%d", nodeIndex, fromBlock, toBlock)
+	return nil
+}
+
+// AssertParentHashChainSince checks the parent hash chain from the merge
+// block up to the node's current head.
+func (t *Testnet) AssertParentHashChainSince(ctx context.Context, nodeIndex int, mergeBlock uint64) error {
+	if nodeIndex < 0 || nodeIndex >= len(t.eth1) {
+		return fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	head, err := t.eth1[nodeIndex].EthClient().BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get head of node %d: %w", nodeIndex, err)
+	}
+	return t.AssertParentHashChain(ctx, nodeIndex, mergeBlock, head)
+}
@@ -0,0 +1,634 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// ForkchoiceEvent is one forkchoice update a beacon node sent to its
+// execution client, as observed by the execution RPC proxy.
+type ForkchoiceEvent struct {
+	// Time is when the proxy saw the call.
+	Time time.Time
+	// HeadBlockHash, SafeBlockHash and FinalizedBlockHash are the hashes of
+	// the forkchoice state the beacon node instructed the execution client
+	// to adopt.
+	HeadBlockHash      ethcommon.Hash
+	SafeBlockHash      ethcommon.Hash
+	FinalizedBlockHash ethcommon.Hash
+	// HasPayloadAttributes is set when the call also requested payload
+	// building, i.e. the node prepared to propose.
+	HasPayloadAttributes bool
+}
+
+// ExecutionRPCProxy is a reverse proxy between a beacon node and its
+// execution client. It forwards all JSON-RPC traffic unmodified while
+// recording the forkchoice updates passing through, so tests can check what
+// head, safe and finalized hashes the consensus layer drove the execution
+// layer to.
+type ExecutionRPCProxy struct {
+	t         *Testnet
+	nodeIndex int
+	backend   *httputil.ReverseProxy
+	listener  net.Listener
+
+	mu        sync.Mutex
+	fcuEvents []ForkchoiceEvent
+	// engineCalls are the recorded engine API calls, oldest first, capped at
+	// engineCallRetention entries.
+	engineCalls []EngineCallRecord
+	// latencies are the artificial delays applied to matching methods before
+	// forwarding, by method name.
+	latencies map[string]time.Duration
+	// fault is the scripted error response currently applied to matching
+	// calls, with faultCalls counting how many it has answered. lastFault
+	// and faultClearedAt keep the most recently expired fault for recovery
+	// checks.
+	fault          *EngineFault
+	faultCalls     int
+	lastFault      *EngineFault
+	faultClearedAt time.Time
+	// requestMutators and responseMutators rewrite matching engine calls in
+	// flight, by method prefix; mutations logs every applied rewrite.
+	requestMutators  map[string]EngineRequestMutator
+	responseMutators map[string]EngineResponseMutator
+	mutations        []EngineMutationRecord
+	// payloadParents maps the block hash of every payload seen via
+	// newPayload to its parent hash, for ancestry checks over the forkchoice
+	// history.
+	payloadParents map[ethcommon.Hash]ethcommon.Hash
+}
+
+// engineCallRetention bounds how many engine call records a proxy keeps, so
+// long runs do not grow memory without limit. At one forkchoice update and
+// one payload per slot this covers well over a day of 12-second slots.
+const engineCallRetention = 16384
+
+// EngineCallRecord is one engine API call a beacon node made to its
+// execution client, as recorded by the execution RPC proxy.
+type EngineCallRecord struct {
+	// Time is when the proxy received the call; Slot is the wall-clock slot
+	// at that moment.
+	Time time.Time
+	Slot common.Slot
+	// Method is the JSON-RPC method, e.g. engine_newPayloadV3.
+	Method string
+	// ParamsDigest is the SHA-256 of the call's raw params, to correlate
+	// retries of the same payload without retaining full bodies.
+	ParamsDigest [32]byte
+	// HTTPStatus is the status code of the forwarded response.
+	HTTPStatus int
+	// Status is the engine status string of the response — e.g. VALID or
+	// SYNCING — empty when the response carries none or the call was batched.
+	Status string
+	// Latency is how long the call took from the beacon node's point of
+	// view, including any injected delay.
+	Latency time.Duration
+	// InjectedLatency is the artificial delay the proxy added to the call,
+	// so post-analysis can separate injected from genuine slowness.
+	InjectedLatency time.Duration
+	// Faulted is set when the proxy answered the call with a scripted fault
+	// instead of forwarding it.
+	Faulted bool
+}
+
+// EngineCallFilter selects engine call records. The zero value matches
+// everything; Method restricts to one method, and FromSlot/ToSlot bound the
+// slot range with ToSlot of zero meaning no upper bound.
+type EngineCallFilter struct {
+	Method   string
+	FromSlot common.Slot
+	ToSlot   common.Slot
+}
+
+// matches reports whether the record passes the filter.
+func (f EngineCallFilter) matches(rec EngineCallRecord) bool {
+	if f.Method != "" && rec.Method != f.Method {
+		return false
+	}
+	if rec.Slot < f.FromSlot {
+		return false
+	}
+	if f.ToSlot != 0 && rec.Slot > f.ToSlot {
+		return false
+	}
+	return true
+}
+
+// EngineCalls returns the recorded engine API calls of the given node that
+// match the filter, oldest first. It is empty for nodes without
+// TrackForkchoiceUpdated.
+func (t *Testnet) EngineCalls(nodeIndex int, filter EngineCallFilter) []EngineCallRecord {
+	p, ok := t.executionProxies[nodeIndex]
+	if !ok {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var matched []EngineCallRecord
+	for _, rec := range p.engineCalls {
+		if filter.matches(rec) {
+			matched = append(matched, rec)
+		}
+	}
+	return matched
+}
+
+// SetEngineLatency makes the given node's execution proxy delay every call
+// of the given method — e.g. engine_newPayloadV3 — by delay before
+// forwarding it, to reproduce slow-execution-client behavior. The node must
+// have TrackForkchoiceUpdated set so its proxy exists.
+func (t *Testnet) SetEngineLatency(nodeIndex int, method string, delay time.Duration) error {
+	p, ok := t.executionProxies[nodeIndex]
+	if !ok {
+		return fmt.Errorf("node %d has no execution proxy to inject latency into", nodeIndex)
+	}
+	p.mu.Lock()
+	if p.latencies == nil {
+		p.latencies = make(map[string]time.Duration)
+	}
+	p.latencies[method] = delay
+	p.mu.Unlock()
+	t.t.Logf("node %d: %s calls will be delayed by %v", nodeIndex, method, delay)
+	return nil
+}
+
+// ClearEngineLatency removes the injected delay of the given method on the
+// given node.
+func (t *Testnet) ClearEngineLatency(nodeIndex int, method string) error {
+	p, ok := t.executionProxies[nodeIndex]
+	if !ok {
+		return fmt.Errorf("node %d has no execution proxy", nodeIndex)
+	}
+	p.mu.Lock()
+	delete(p.latencies, method)
+	p.mu.Unlock()
+	t.t.Logf("node %d: %s calls are no longer delayed", nodeIndex, method)
+	return nil
+}
+
+// newExecutionRPCProxy starts an execution RPC proxy for the given node,
+// forwarding to the execution client at backendAddr. It is interposed at
+// node startup, before the beacon node connects.
+func newExecutionRPCProxy(t *Testnet, nodeIndex int, backendAddr string) (*ExecutionRPCProxy, error) {
+	backendURL, err := url.Parse(backendAddr)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:0", simulatorIP()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for execution RPC proxy: %w", err)
+	}
+	p := &ExecutionRPCProxy{
+		t:         t,
+		nodeIndex: nodeIndex,
+		backend:   httputil.NewSingleHostReverseProxy(backendURL),
+		listener:  listener,
+	}
+	go http.Serve(listener, p)
+	return p, nil
+}
+
+// Endpoint returns the URL the beacon node uses to reach the proxy.
+func (p *ExecutionRPCProxy) Endpoint() string {
+	return fmt.Sprintf("http://%s", p.listener.Addr())
+}
+
+// ServeHTTP forwards execution RPC calls to the client, recording forkchoice
+// updates and engine call metadata on the way through.
+func (p *ExecutionRPCProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		p.backend.ServeHTTP(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	calls, batched := parseExecutionRPCCalls(body)
+	p.recordForkchoiceUpdates(calls)
+	p.recordNewPayloads(calls)
+	if !batched && len(calls) == 1 {
+		if mutated, ok := p.mutateRequest(&calls[0]); ok {
+			body = mutated
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+		}
+	}
+
+	// A scripted fault answers in place of the execution client; the calls
+	// are still recorded so retries can be verified.
+	if fault := p.activeFault(calls); fault != nil {
+		p.recordFaultedCalls(calls, fault)
+		p.serveFault(w, fault, calls)
+		return
+	}
+
+	// The response is buffered so its engine status can be recorded.
+	buffered := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+	start := time.Now()
+	injected := p.injectedDelay(calls)
+	if injected > 0 {
+		time.Sleep(injected)
+	}
+	p.backend.ServeHTTP(buffered, r)
+	if !batched && len(calls) == 1 {
+		p.mutateResponse(calls[0].Method, buffered)
+	}
+	p.recordEngineCalls(calls, batched, buffered, time.Since(start), injected)
+	buffered.writeTo(w)
+}
+
+// mutateRequest applies a matching request mutator to the call's params,
+// returning the rebuilt request body when a mutation was applied.
+func (p *ExecutionRPCProxy) mutateRequest(call *executionRPCCall) ([]byte, bool) {
+	p.mu.Lock()
+	var mutator EngineRequestMutator
+	for method, m := range p.requestMutators {
+		if strings.HasPrefix(call.Method, method) {
+			mutator = m
+			break
+		}
+	}
+	p.mu.Unlock()
+	if mutator == nil {
+		return nil, false
+	}
+	call.Params = mutator(call.Params)
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      call.ID,
+		"method":  call.Method,
+		"params":  call.Params,
+	})
+	if err != nil {
+		return nil, false
+	}
+	p.recordMutation(call.Method, "request")
+	return body, true
+}
+
+// mutateResponse applies a matching response mutator to the result of a
+// buffered single-call response, rewriting the body in place.
+func (p *ExecutionRPCProxy) mutateResponse(method string, resp *bufferedResponse) {
+	p.mu.Lock()
+	var mutator EngineResponseMutator
+	for prefix, m := range p.responseMutators {
+		if strings.HasPrefix(method, prefix) {
+			mutator = m
+			break
+		}
+	}
+	p.mu.Unlock()
+	if mutator == nil {
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(resp.body.Bytes(), &fields); err != nil {
+		return
+	}
+	result, ok := fields["result"]
+	if !ok {
+		return
+	}
+	mutated := mutator(result)
+	if bytes.Equal(mutated, result) {
+		return
+	}
+	fields["result"] = mutated
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return
+	}
+	resp.body.Reset()
+	resp.body.Write(body)
+	resp.header.Set("Content-Length", strconv.Itoa(len(body)))
+	p.recordMutation(method, "response")
+}
+
+// recordMutation logs an applied mutation with the slot it happened in.
+func (p *ExecutionRPCProxy) recordMutation(method, kind string) {
+	rec := EngineMutationRecord{
+		Time:   time.Now(),
+		Slot:   p.t.CurrentSlot(),
+		Method: method,
+		Kind:   kind,
+	}
+	p.mu.Lock()
+	p.mutations = append(p.mutations, rec)
+	p.mu.Unlock()
+	p.t.t.Logf("node %d: mutated %s of %s at slot %d", p.nodeIndex, kind, method, rec.Slot)
+}
+
+// injectedDelay returns the artificial delay to apply to a request, the
+// largest configured delay among its calls.
+func (p *ExecutionRPCProxy) injectedDelay(calls []executionRPCCall) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var delay time.Duration
+	for _, call := range calls {
+		if d := p.latencies[call.Method]; d > delay {
+			delay = d
+		}
+	}
+	return delay
+}
+
+// bufferedResponse captures a forwarded response so the proxy can inspect it
+// before relaying it to the beacon node.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+
+// writeTo relays the buffered response to the real writer.
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	for key, values := range b.header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
+
+// executionRPCCall is the part of a JSON-RPC request the proxy inspects.
+type executionRPCCall struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// jsonRPCError is the error object of a JSON-RPC response.
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeJSONRPCResponse writes a single JSON-RPC response with either a
+// result or an error.
+func writeJSONRPCResponse(w http.ResponseWriter, id, result interface{}, rpcErr *jsonRPCError) {
+	response := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+	}
+	if rpcErr != nil {
+		response["error"] = rpcErr
+	} else {
+		response["result"] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// recordFaultedCalls stores records for engine calls answered by a scripted
+// fault instead of the execution client.
+func (p *ExecutionRPCProxy) recordFaultedCalls(calls []executionRPCCall, fault *EngineFault) {
+	now := time.Now()
+	slot := p.t.CurrentSlot()
+	status := ""
+	httpStatus := http.StatusOK
+	switch fault.Kind {
+	case EngineFaultInvalid:
+		status = "INVALID"
+	case EngineFaultSyncing:
+		status = "SYNCING"
+	case EngineFaultConnectionReset:
+		httpStatus = 0
+	}
+	for _, call := range calls {
+		if !strings.HasPrefix(call.Method, "engine_") {
+			continue
+		}
+		rec := EngineCallRecord{
+			Time:       now,
+			Slot:       slot,
+			Method:     call.Method,
+			HTTPStatus: httpStatus,
+			Status:     status,
+			Faulted:    true,
+		}
+		digest := sha256.New()
+		for _, param := range call.Params {
+			digest.Write(param)
+		}
+		digest.Sum(rec.ParamsDigest[:0])
+		p.mu.Lock()
+		p.engineCalls = append(p.engineCalls, rec)
+		if len(p.engineCalls) > engineCallRetention {
+			p.engineCalls = append(p.engineCalls[:0:0], p.engineCalls[len(p.engineCalls)-engineCallRetention:]...)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// parseExecutionRPCCalls decodes a JSON-RPC request body, which may carry a
+// single call or a batch.
+func parseExecutionRPCCalls(body []byte) (calls []executionRPCCall, batched bool) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &calls); err != nil {
+			return nil, true
+		}
+		return calls, true
+	}
+	var call executionRPCCall
+	if err := json.Unmarshal(trimmed, &call); err != nil {
+		return nil, false
+	}
+	return []executionRPCCall{call}, false
+}
+
+// recordEngineCalls stores a record for every engine API call of a forwarded
+// request, dropping the oldest entries beyond the retention bound.
+func (p *ExecutionRPCProxy) recordEngineCalls(calls []executionRPCCall, batched bool, resp *bufferedResponse, latency, injected time.Duration) {
+	now := time.Now()
+	slot := p.t.CurrentSlot()
+	for _, call := range calls {
+		if !strings.HasPrefix(call.Method, "engine_") {
+			continue
+		}
+		rec := EngineCallRecord{
+			Time:            now,
+			Slot:            slot,
+			Method:          call.Method,
+			HTTPStatus:      resp.status,
+			Latency:         latency,
+			InjectedLatency: injected,
+		}
+		digest := sha256.New()
+		for _, param := range call.Params {
+			digest.Write(param)
+		}
+		digest.Sum(rec.ParamsDigest[:0])
+		if !batched {
+			rec.Status = engineResponseStatus(resp.body.Bytes())
+		}
+		p.mu.Lock()
+		p.engineCalls = append(p.engineCalls, rec)
+		if len(p.engineCalls) > engineCallRetention {
+			p.engineCalls = append(p.engineCalls[:0:0], p.engineCalls[len(p.engineCalls)-engineCallRetention:]...)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// engineResponseStatus extracts the engine status string of a single-call
+// response body: newPayload answers with a payload status object, forkchoice
+// updates nest it one level deeper.
+func engineResponseStatus(body []byte) string {
+	var response struct {
+		Result struct {
+			Status        string `json:"status"`
+			PayloadStatus struct {
+				Status string `json:"status"`
+			} `json:"payloadStatus"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return ""
+	}
+	if response.Result.Status != "" {
+		return response.Result.Status
+	}
+	return response.Result.PayloadStatus.Status
+}
+
+// recordNewPayloads notes the block and parent hashes of payloads delivered
+// via newPayload, so forkchoice checks can walk ancestry.
+func (p *ExecutionRPCProxy) recordNewPayloads(calls []executionRPCCall) {
+	for _, call := range calls {
+		if !strings.HasPrefix(call.Method, "engine_newPayload") || len(call.Params) == 0 {
+			continue
+		}
+		var payload struct {
+			BlockHash  ethcommon.Hash `json:"blockHash"`
+			ParentHash ethcommon.Hash `json:"parentHash"`
+		}
+		if err := json.Unmarshal(call.Params[0], &payload); err != nil {
+			continue
+		}
+		p.mu.Lock()
+		if p.payloadParents == nil {
+			p.payloadParents = make(map[ethcommon.Hash]ethcommon.Hash)
+		}
+		p.payloadParents[payload.BlockHash] = payload.ParentHash
+		p.mu.Unlock()
+	}
+}
+
+// recordForkchoiceUpdates extracts forkchoice updates from the calls of a
+// forwarded request.
+func (p *ExecutionRPCProxy) recordForkchoiceUpdates(calls []executionRPCCall) {
+	for _, call := range calls {
+		if !strings.HasPrefix(call.Method, "engine_forkchoiceUpdated") || len(call.Params) == 0 {
+			continue
+		}
+		var state struct {
+			HeadBlockHash      ethcommon.Hash `json:"headBlockHash"`
+			SafeBlockHash      ethcommon.Hash `json:"safeBlockHash"`
+			FinalizedBlockHash ethcommon.Hash `json:"finalizedBlockHash"`
+		}
+		if err := json.Unmarshal(call.Params[0], &state); err != nil {
+			continue
+		}
+		event := ForkchoiceEvent{
+			Time:                 time.Now(),
+			HeadBlockHash:        state.HeadBlockHash,
+			SafeBlockHash:        state.SafeBlockHash,
+			FinalizedBlockHash:   state.FinalizedBlockHash,
+			HasPayloadAttributes: len(call.Params) > 1 && !bytes.Equal(bytes.TrimSpace(call.Params[1]), []byte("null")),
+		}
+		p.mu.Lock()
+		p.fcuEvents = append(p.fcuEvents, event)
+		p.mu.Unlock()
+	}
+}
+
+// ForkchoiceUpdatedHistory returns the forkchoice updates the given node has
+// sent to its execution client so far, in order. It is empty for nodes
+// without TrackForkchoiceUpdated.
+func (t *Testnet) ForkchoiceUpdatedHistory(nodeIndex int) []ForkchoiceEvent {
+	p, ok := t.executionProxies[nodeIndex]
+	if !ok {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ForkchoiceEvent(nil), p.fcuEvents...)
+}
+
+// VerifyForkchoiceFinalization waits until the given node has told its
+// execution client to finalize the payload of the beacon chain's finalized
+// checkpoint, proving the finality signal crossed the engine API. Requires
+// the node to have TrackForkchoiceUpdated set and the finalized block to
+// carry an execution payload.
+func (t *Testnet) VerifyForkchoiceFinalization(ctx context.Context, nodeIndex int) error {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	if _, ok := t.executionProxies[nodeIndex]; !ok {
+		return fmt.Errorf("node %d does not track forkchoice updates", nodeIndex)
+	}
+	bn := t.beacons[nodeIndex]
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			var checkpoints eth2api.FinalityCheckpoints
+			if exists, err := beaconapi.FinalityCheckpoints(ctx, bn.API, eth2api.StateHead, &checkpoints); err != nil || !exists {
+				return fmt.Errorf("failed to get finality checkpoints of node %d: %v", nodeIndex, err)
+			}
+			if checkpoints.Finalized.Epoch == 0 {
+				t.t.Logf("node %d has not finalized yet", nodeIndex)
+				continue
+			}
+			block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdRoot(checkpoints.Finalized.Root))
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("node %d does not have its finalized block %s", nodeIndex, checkpoints.Finalized.Root)
+			}
+			payload := block.executionPayloadEnvelope()
+			if payload == nil {
+				return fmt.Errorf("finalized block of node %d carries no execution payload", nodeIndex)
+			}
+			finalizedHash := payload.BlockHash()
+			for _, event := range t.ForkchoiceUpdatedHistory(nodeIndex) {
+				if event.FinalizedBlockHash == finalizedHash {
+					t.t.Logf("node %d finalized payload %s on its execution client", nodeIndex, finalizedHash)
+					return nil
+				}
+			}
+			t.t.Logf("node %d has not yet sent finalized hash %s to its execution client", nodeIndex, finalizedHash)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	blsu "github.com/protolambda/bls12-381-util"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// PoolRejectedError is returned when a beacon node actively rejects a pool
+// submission, e.g. because of a bad signature or a premature exit. Transport
+// failures are reported as plain errors instead.
+type PoolRejectedError struct {
+	Op     string
+	Code   uint
+	Reason string
+}
+
+func (e *PoolRejectedError) Error() string {
+	return fmt.Sprintf("%s rejected by beacon node (status %d): %s", e.Op, e.Code, e.Reason)
+}
+
+// asPoolRejection converts beacon node rejections of a pool submission into
+// a *PoolRejectedError, and passes any other (transport) error through.
+func asPoolRejection(op string, err error) error {
+	var msg *eth2api.ErrorMessage
+	if errors.As(err, &msg) {
+		return &PoolRejectedError{Op: op, Code: msg.Code(), Reason: msg.Error()}
+	}
+	return err
+}
+
+// SubmitVoluntaryExit signs a voluntary exit for the given validator and
+// submits it to the exit pool of a round-robin chosen beacon node. The exit
+// is signed with the validator's known secret key, using the voluntary-exit
+// domain at atEpoch.
+//
+// If the node rejects the exit, a *PoolRejectedError is returned; any other
+// error indicates a transport failure.
+func (t *Testnet) SubmitVoluntaryExit(ctx context.Context, validatorIndex common.ValidatorIndex, atEpoch common.Epoch) error {
+	v := t.Validators.ByIndex(validatorIndex)
+	if v == nil {
+		return fmt.Errorf("no known key material for validator %d", validatorIndex)
+	}
+	exit := phase0.VoluntaryExit{
+		Epoch:          atEpoch,
+		ValidatorIndex: validatorIndex,
+	}
+	slot, err := t.spec.EpochStartSlot(atEpoch)
+	if err != nil {
+		return err
+	}
+	domain := common.ComputeDomain(common.DOMAIN_VOLUNTARY_EXIT, t.spec.ForkVersion(slot), t.genesisValidatorsRoot)
+	signingRoot := common.ComputeSigningRoot(exit.HashTreeRoot(tree.GetHashFn()), domain)
+	signed := phase0.SignedVoluntaryExit{
+		Message:   exit,
+		Signature: common.BLSSignature(blsu.Sign(v.SecretKey, signingRoot[:]).Serialize()),
+	}
+	bn := t.roundRobinBeacon()
+	if err := beaconapi.SubmitVoluntaryExit(ctx, bn.API, &signed); err != nil {
+		return asPoolRejection("voluntary exit", err)
+	}
+	t.markExpectedExit(validatorIndex)
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+)
+
+// ForkChoiceNode is one block in a node's fork choice tree.
+type ForkChoiceNode struct {
+	Slot                common.Slot
+	Root                tree.Root
+	Parent              tree.Root
+	Weight              uint64
+	ExecutionOptimistic bool
+	Validity            string
+}
+
+// ForkChoiceTree is the full tree of blocks a beacon node considers in fork
+// choice, with their attestation weights.
+type ForkChoiceTree struct {
+	Nodes []ForkChoiceNode
+}
+
+// FindNode returns the tree node with the given block root, or nil.
+func (t *ForkChoiceTree) FindNode(root tree.Root) *ForkChoiceNode {
+	for i := range t.Nodes {
+		if t.Nodes[i].Root == root {
+			return &t.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// Subtree returns the node with the given root and all its descendants, or
+// nil when the root is not in the tree.
+func (t *ForkChoiceTree) Subtree(root tree.Root) []*ForkChoiceNode {
+	start := t.FindNode(root)
+	if start == nil {
+		return nil
+	}
+	inSubtree := map[tree.Root]bool{root: true}
+	subtree := []*ForkChoiceNode{start}
+	// Nodes are reported parent-before-child by all clients, but don't rely
+	// on it: keep sweeping until no new descendants are found.
+	for {
+		grew := false
+		for i := range t.Nodes {
+			if inSubtree[t.Nodes[i].Root] || !inSubtree[t.Nodes[i].Parent] {
+				continue
+			}
+			inSubtree[t.Nodes[i].Root] = true
+			subtree = append(subtree, &t.Nodes[i])
+			grew = true
+		}
+		if !grew {
+			return subtree
+		}
+	}
+}
+
+// GetForkChoiceTree retrieves the fork choice tree of the given node through
+// the debug fork choice endpoint. Primarily a debugging aid, but it also
+// backs assertions about non-canonical branches, e.g. bounding how many
+// blocks a rejected chain accumulated.
+func (t *Testnet) GetForkChoiceTree(ctx context.Context, nodeIndex int) (*ForkChoiceTree, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return nil, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	var payload struct {
+		ForkChoiceNodes []struct {
+			Slot                common.Slot     `json:"slot"`
+			BlockRoot           common.Root     `json:"block_root"`
+			ParentRoot          common.Root     `json:"parent_root"`
+			Weight              view.Uint64View `json:"weight"`
+			ExecutionOptimistic bool            `json:"execution_optimistic"`
+			Validity            string          `json:"validity"`
+		} `json:"fork_choice_nodes"`
+	}
+	resp := t.beacons[nodeIndex].API.Request(ctx, eth2api.PlainGET("/eth/v1/debug/fork_choice"))
+	if _, err := resp.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to get fork choice tree of node %d: %w", nodeIndex, err)
+	}
+	fcTree := &ForkChoiceTree{Nodes: make([]ForkChoiceNode, len(payload.ForkChoiceNodes))}
+	for i, n := range payload.ForkChoiceNodes {
+		fcTree.Nodes[i] = ForkChoiceNode{
+			Slot:                n.Slot,
+			Root:                tree.Root(n.BlockRoot),
+			Parent:              tree.Root(n.ParentRoot),
+			Weight:              uint64(n.Weight),
+			ExecutionOptimistic: n.ExecutionOptimistic,
+			Validity:            n.Validity,
+		}
+	}
+	return fcTree, nil
+}
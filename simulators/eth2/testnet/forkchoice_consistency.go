@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+// fcuEventString renders a forkchoice event for violation messages.
+func fcuEventString(e ForkchoiceEvent) string {
+	return fmt.Sprintf("[%s head=%s safe=%s finalized=%s attrs=%v]",
+		e.Time.Format("15:04:05.000"), e.HeadBlockHash, e.SafeBlockHash, e.FinalizedBlockHash, e.HasPayloadAttributes)
+}
+
+// VerifyForkchoiceConsistency checks invariants over the forkchoice updates
+// the given node sent to its execution client: the head hash must be a
+// payload the proxy saw delivered via newPayload, the safe hash must be an
+// ancestor of the head, and the finalized hash must only move forward.
+// Violations name the pair of consecutive calls where the invariant broke.
+// Finally it waits until the finalized hash sent to the execution layer
+// matches the beacon chain's finalized payload, cross-checked through the
+// beacon API. Requires TrackForkchoiceUpdated on the node.
+func (t *Testnet) VerifyForkchoiceConsistency(ctx context.Context, nodeIndex int) error {
+	p, ok := t.executionProxies[nodeIndex]
+	if !ok {
+		return fmt.Errorf("node %d does not track forkchoice updates", nodeIndex)
+	}
+	p.mu.Lock()
+	events := append([]ForkchoiceEvent(nil), p.fcuEvents...)
+	parents := make(map[ethcommon.Hash]ethcommon.Hash, len(p.payloadParents))
+	for hash, parent := range p.payloadParents {
+		parents[hash] = parent
+	}
+	p.mu.Unlock()
+
+	var issues []string
+	pair := func(i int) string {
+		if i == 0 {
+			return fcuEventString(events[i])
+		}
+		return fcuEventString(events[i-1]) + " -> " + fcuEventString(events[i])
+	}
+	finalizedSuperseded := make(map[ethcommon.Hash]bool)
+	var lastFinalized ethcommon.Hash
+	for i, event := range events {
+		// The head must have been delivered through newPayload before being
+		// adopted; a zero head only occurs before the merge.
+		if event.HeadBlockHash != (ethcommon.Hash{}) {
+			if _, seen := parents[event.HeadBlockHash]; !seen {
+				issues = append(issues, fmt.Sprintf("head %s was never delivered via newPayload: %s", event.HeadBlockHash, pair(i)))
+				continue
+			}
+		}
+		// The safe hash must sit on the head's ancestry. Hashes the proxy
+		// never saw predate tracking and cannot be walked, so only tracked
+		// safe hashes are judged.
+		if safe := event.SafeBlockHash; safe != (ethcommon.Hash{}) && safe != event.HeadBlockHash {
+			if _, tracked := parents[safe]; tracked {
+				onChain := false
+				for hash := event.HeadBlockHash; ; {
+					parent, seen := parents[hash]
+					if !seen {
+						break
+					}
+					if parent == safe {
+						onChain = true
+						break
+					}
+					hash = parent
+				}
+				if !onChain {
+					issues = append(issues, fmt.Sprintf("safe %s is not an ancestor of head %s: %s", safe, event.HeadBlockHash, pair(i)))
+				}
+			}
+		}
+		// Finality must not regress to a hash it already moved past.
+		if finalized := event.FinalizedBlockHash; finalized != lastFinalized {
+			if finalizedSuperseded[finalized] {
+				issues = append(issues, fmt.Sprintf("finalized hash moved back to %s: %s", finalized, pair(i)))
+			}
+			if lastFinalized != (ethcommon.Hash{}) {
+				finalizedSuperseded[lastFinalized] = true
+			}
+			lastFinalized = finalized
+		}
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("forkchoice history of node %d is inconsistent: %s", nodeIndex, strings.Join(issues, "; "))
+	}
+	t.t.Logf("forkchoice history of node %d consistent over %d updates and %d payloads", nodeIndex, len(events), len(parents))
+
+	// The finalized hash the execution layer holds must converge on the
+	// beacon chain's finalized payload.
+	return t.VerifyForkchoiceFinalization(ctx, nodeIndex)
+}
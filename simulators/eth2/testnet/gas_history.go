@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// maxBlobGasPerBlock is the EIP-4844 per-block blob gas ceiling.
+const maxBlobGasPerBlock = maxBlobsPerBlock * gasPerBlob
+
+// GasUsageSample is the gas accounting of one execution block. The blob gas
+// fields are nil for pre-Deneb blocks, and BaseFee is nil before EIP-1559.
+type GasUsageSample struct {
+	BlockNumber   uint64
+	GasUsed       uint64
+	GasLimit      uint64
+	BlobGasUsed   *uint64
+	ExcessBlobGas *uint64
+	BaseFee       *big.Int
+}
+
+// gasUsageFields is the part of an eth_getBlockByNumber response the gas
+// history reads. The blob gas fields postdate the dependency set's header
+// type, so they are parsed from the raw response.
+type gasUsageFields struct {
+	Number        hexutil.Uint64  `json:"number"`
+	GasUsed       hexutil.Uint64  `json:"gasUsed"`
+	GasLimit      hexutil.Uint64  `json:"gasLimit"`
+	BlobGasUsed   *hexutil.Uint64 `json:"blobGasUsed"`
+	ExcessBlobGas *hexutil.Uint64 `json:"excessBlobGas"`
+	BaseFee       *hexutil.Big    `json:"baseFeePerGas"`
+}
+
+// GetGasUsageHistory returns the gas consumption of the execution blocks in
+// [fromBlock, toBlock], read from the given node in one batched
+// eth_getBlockByNumber call. Load tests and fee market tests use it to judge
+// consumption patterns over a whole run instead of single blocks.
+func (t *Testnet) GetGasUsageHistory(ctx context.Context, nodeIndex int, fromBlock, toBlock uint64) ([]GasUsageSample, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.eth1) {
+		return nil, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	if toBlock < fromBlock {
+		return nil, fmt.Errorf("empty block range %d-%d", fromBlock, toBlock)
+	}
+	results := make([]gasUsageFields, toBlock-fromBlock+1)
+	batch := make([]rpc.BatchElem, toBlock-fromBlock+1)
+	for i := range batch {
+		batch[i] = rpc.BatchElem{
+			Method: "eth_getBlockByNumber",
+			Args:   []interface{}{hexutil.EncodeUint64(fromBlock + uint64(i)), false},
+			Result: &results[i],
+		}
+	}
+	if err := t.eth1[nodeIndex].RPC().BatchCallContext(ctx, batch); err != nil {
+		return nil, fmt.Errorf("failed to batch-get blocks %d-%d: %w", fromBlock, toBlock, err)
+	}
+	samples := make([]GasUsageSample, len(batch))
+	for i, elem := range batch {
+		if elem.Error != nil {
+			return nil, fmt.Errorf("failed to get block %d: %w", fromBlock+uint64(i), elem.Error)
+		}
+		sample := GasUsageSample{
+			BlockNumber: uint64(results[i].Number),
+			GasUsed:     uint64(results[i].GasUsed),
+			GasLimit:    uint64(results[i].GasLimit),
+		}
+		if results[i].BlobGasUsed != nil {
+			used := uint64(*results[i].BlobGasUsed)
+			sample.BlobGasUsed = &used
+		}
+		if results[i].ExcessBlobGas != nil {
+			excess := uint64(*results[i].ExcessBlobGas)
+			sample.ExcessBlobGas = &excess
+		}
+		if results[i].BaseFee != nil {
+			sample.BaseFee = (*big.Int)(results[i].BaseFee)
+		}
+		samples[i] = sample
+	}
+	return samples, nil
+}
+
+// AssertBlobGasNeverExceedsTarget checks, on the execution layer, that no
+// block in the range used more than the per-block blob gas ceiling. It is
+// the execution-side complement of the beacon-side blob count checks: the
+// two layers account blob gas independently and must agree on the limit.
+func (t *Testnet) AssertBlobGasNeverExceedsTarget(ctx context.Context, nodeIndex int, fromBlock, toBlock uint64) error {
+	samples, err := t.GetGasUsageHistory(ctx, nodeIndex, fromBlock, toBlock)
+	if err != nil {
+		return err
+	}
+	checked := 0
+	for _, sample := range samples {
+		if sample.BlobGasUsed == nil {
+			continue
+		}
+		checked++
+		if *sample.BlobGasUsed > maxBlobGasPerBlock {
+			return fmt.Errorf("block %d used %d blob gas, above the per-block limit of %d",
+				sample.BlockNumber, *sample.BlobGasUsed, maxBlobGasPerBlock)
+		}
+	}
+	t.t.Logf("blob gas usage of %d blocks in %d-%d within the %d limit", checked, fromBlock, toBlock, maxBlobGasPerBlock)
+	return nil
+}
@@ -0,0 +1,32 @@
+module github.com/ethereum/hive/simulators/eth2/testnet
+
+go 1.21
+
+require (
+	github.com/ethereum/go-ethereum v1.9.23
+	github.com/ethereum/hive v0.0.0-20201104200225-0ce0954f3916
+	github.com/protolambda/bls12-381-util v0.1.0
+	github.com/protolambda/eth2api v0.0.0-20230316214135-5f8afbd6d05d
+	github.com/protolambda/zrnt v0.34.1
+	github.com/protolambda/ztyp v0.2.2
+)
+
+require (
+	github.com/aristanetworks/goarista v0.0.0-20201012165903-2cb20defcd66 // indirect
+	github.com/btcsuite/btcd v0.21.0-beta // indirect
+	github.com/deckarep/golang-set v1.7.1 // indirect
+	github.com/go-stack/stack v1.8.0 // indirect
+	github.com/golang/snappy v0.0.3 // indirect
+	github.com/gorilla/websocket v1.4.2 // indirect
+	github.com/holiman/uint256 v1.2.1 // indirect
+	github.com/julienschmidt/httprouter v1.3.0 // indirect
+	github.com/kilic/bls12-381 v0.1.0 // indirect
+	github.com/klauspost/cpuid/v2 v2.2.2 // indirect
+	github.com/minio/sha256-simd v1.0.0 // indirect
+	github.com/rs/cors v1.7.0 // indirect
+	github.com/shirou/gopsutil v2.20.5+incompatible // indirect
+	github.com/syndtr/goleveldb v1.0.1-0.20200815110645-5c35d600f0ca // indirect
+	golang.org/x/crypto v0.0.0-20201016220609-9e8e0b390897 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// AssertGraffitiInBlocks waits the given number of slots and then verifies
+// that every block proposed by the validators of the given node during that
+// window carries the expected graffiti. It fails if none of the node's
+// validators proposed a block in the window, since that leaves nothing to
+// verify.
+func (t *Testnet) AssertGraffitiInBlocks(ctx context.Context, graffiti string, nodeIndex int, slots common.Slot) error {
+	if nodeIndex < 0 || nodeIndex >= len(t.validators) {
+		return fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	var expected common.Root
+	if len(graffiti) > len(expected) {
+		return fmt.Errorf("graffiti %q longer than %d bytes", graffiti, len(expected))
+	}
+	copy(expected[:], graffiti)
+
+	nodeValidators := make(map[common.ValidatorIndex]bool)
+	for _, v := range t.validators[nodeIndex].Keys {
+		nodeValidators[v.Index] = true
+	}
+
+	startSlot := t.CurrentSlot()
+	endSlot := startSlot + slots
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for t.CurrentSlot() <= endSlot {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	bn := t.roundRobinBeacon()
+	checked := 0
+	for slot := startSlot; slot <= endSlot; slot++ {
+		block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+		if err != nil {
+			return err
+		}
+		if !exists || !nodeValidators[block.ProposerIndex()] {
+			continue
+		}
+		checked++
+		if got := block.Graffiti(); got != expected {
+			return fmt.Errorf("block at slot %d by validator %d of node %d has graffiti %q, want %q",
+				slot, block.ProposerIndex(), nodeIndex, string(bytes.TrimRight(got[:], "\x00")), graffiti)
+		}
+	}
+	if checked == 0 {
+		return fmt.Errorf("no blocks by node %d in slots %d-%d to check graffiti on", nodeIndex, startSlot, endSlot)
+	}
+	t.t.Logf("graffiti %q present in all %d blocks of node %d", graffiti, checked, nodeIndex)
+	return nil
+}
+
+// graffitiRoot pads a graffiti string into its on-chain 32-byte form.
+func graffitiRoot(graffiti string) (common.Root, error) {
+	var root common.Root
+	if len(graffiti) > len(root) {
+		return root, fmt.Errorf("graffiti %q longer than %d bytes", graffiti, len(root))
+	}
+	copy(root[:], graffiti)
+	return root, nil
+}
+
+// AttributeBlocksByGraffiti assigns the blocks in a slot range to their origin
+// nodes by matching block graffiti against each node's configured graffiti
+// string. It works without duty lookups, so it still attributes blocks when a
+// beacon node is down. Blocks whose graffiti matches no configured node are
+// returned under index -1.
+func (t *Testnet) AttributeBlocksByGraffiti(ctx context.Context, fromSlot, toSlot common.Slot) (map[int][]common.Slot, error) {
+	byGraffiti := make(map[common.Root]int)
+	for i, vc := range t.validators {
+		if vc.graffiti == "" {
+			continue
+		}
+		root, err := graffitiRoot(vc.graffiti)
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %w", i, err)
+		}
+		if other, dup := byGraffiti[root]; dup {
+			return nil, fmt.Errorf("nodes %d and %d share graffiti %q, attribution is ambiguous", other, i, vc.graffiti)
+		}
+		byGraffiti[root] = i
+	}
+	if len(byGraffiti) == 0 {
+		return nil, fmt.Errorf("no node has a configured graffiti to attribute by")
+	}
+
+	bn := t.roundRobinBeacon()
+	attribution := make(map[int][]common.Slot)
+	for slot := fromSlot; slot <= toSlot; slot++ {
+		block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		node, known := byGraffiti[block.Graffiti()]
+		if !known {
+			node = -1
+		}
+		attribution[node] = append(attribution[node], slot)
+	}
+	return attribution, nil
+}
+
+// VerifyGraffitiConfiguration waits the given number of slots and checks that
+// every block proposed in the window by a node with configured graffiti
+// carries exactly that graffiti, catching clients that truncate or override
+// the configured value. Nodes without configured graffiti are skipped.
+func (t *Testnet) VerifyGraffitiConfiguration(ctx context.Context, slots common.Slot) error {
+	startSlot := t.CurrentSlot()
+	endSlot := startSlot + slots
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for t.CurrentSlot() <= endSlot {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	bn := t.roundRobinBeacon()
+	checked := 0
+	for slot := startSlot; slot <= endSlot; slot++ {
+		block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		owner, err := t.OwnerOfValidator(block.ProposerIndex())
+		if err != nil || t.validators[owner].graffiti == "" {
+			continue
+		}
+		expected, err := graffitiRoot(t.validators[owner].graffiti)
+		if err != nil {
+			return fmt.Errorf("node %d: %w", owner, err)
+		}
+		checked++
+		if got := block.Graffiti(); got != expected {
+			return fmt.Errorf("block at slot %d of node %d has graffiti %q, configured %q",
+				slot, owner, string(bytes.TrimRight(got[:], "\x00")), t.validators[owner].graffiti)
+		}
+	}
+	if checked == 0 {
+		return fmt.Errorf("no blocks by graffiti-configured nodes in slots %d-%d", startSlot, endSlot)
+	}
+	t.t.Logf("configured graffiti verified across %d blocks", checked)
+	return nil
+}
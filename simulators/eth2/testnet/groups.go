@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+
+	"github.com/ethereum/hive/simulators/eth2/testnet/utils"
+)
+
+// GroupSelector picks the members of a validator group out of the running
+// testnet. Selectors are evaluated against the actual key partition, so
+// groups always match what the validator clients really run.
+type GroupSelector func(t *Testnet) utils.Validators
+
+// SelectIndexRange selects the validators with index in [start, end).
+func SelectIndexRange(start, end common.ValidatorIndex) GroupSelector {
+	return func(t *Testnet) utils.Validators {
+		var out utils.Validators
+		for _, v := range t.Validators {
+			if v.Index >= start && v.Index < end {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+}
+
+// SelectPercentage selects the slice of the validator set between the two
+// percentages, e.g. SelectPercentage(50, 100) is the upper half.
+func SelectPercentage(fromPercent, toPercent float64) GroupSelector {
+	return func(t *Testnet) utils.Validators {
+		n := float64(len(t.Validators))
+		lo, hi := int(fromPercent/100*n), int(toPercent/100*n)
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(t.Validators) {
+			hi = len(t.Validators)
+		}
+		if lo >= hi {
+			return nil
+		}
+		return t.Validators[lo:hi]
+	}
+}
+
+// SelectEveryNth selects every n-th validator, starting at offset.
+func SelectEveryNth(n, offset uint64) GroupSelector {
+	return func(t *Testnet) utils.Validators {
+		var out utils.Validators
+		for i := offset; i < uint64(len(t.Validators)); i += n {
+			out = append(out, t.Validators[i])
+		}
+		return out
+	}
+}
+
+// SelectNode selects all validators currently run by the validator client of
+// the given node.
+func SelectNode(nodeIndex int) GroupSelector {
+	return func(t *Testnet) utils.Validators {
+		if nodeIndex < 0 || nodeIndex >= len(t.validators) {
+			return nil
+		}
+		return t.validators[nodeIndex].Keys
+	}
+}
+
+// ValidatorGroup is a named group of validators defined on a testnet,
+// offering group-level operations for declarative scenario code.
+type ValidatorGroup struct {
+	t    *Testnet
+	name string
+	keys utils.Validators
+}
+
+// DefineValidatorGroup evaluates the selector against the testnet and
+// registers the result as a named group in ValidatorGroups, replacing any
+// previous group of the same name.
+func (t *Testnet) DefineValidatorGroup(name string, selector GroupSelector) (*ValidatorGroup, error) {
+	keys := selector(t)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("selector of group %q matches no validators", name)
+	}
+	if t.ValidatorGroups == nil {
+		t.ValidatorGroups = make(map[string]*utils.Validators)
+	}
+	t.ValidatorGroups[name] = &keys
+	t.t.Logf("defined validator group %q with %d members", name, len(keys))
+	return &ValidatorGroup{t: t, name: name, keys: keys}, nil
+}
+
+// Name returns the name the group was registered under.
+func (g *ValidatorGroup) Name() string {
+	return g.name
+}
+
+// Indices returns the validator indices of the group members.
+func (g *ValidatorGroup) Indices() []common.ValidatorIndex {
+	return g.keys.Indices()
+}
+
+// PublicKeys returns the public keys of the group members.
+func (g *ValidatorGroup) PublicKeys() []common.BLSPubkey {
+	out := make([]common.BLSPubkey, len(g.keys))
+	for i, v := range g.keys {
+		out[i] = v.PubKey
+	}
+	return out
+}
+
+// Exit submits a voluntary exit for every member of the group, at the
+// current epoch.
+func (g *ValidatorGroup) Exit(ctx context.Context) error {
+	epoch := g.t.CurrentEpoch()
+	for _, v := range g.keys {
+		if err := g.t.SubmitVoluntaryExit(ctx, v.Index, epoch); err != nil {
+			return fmt.Errorf("group %q: %w", g.name, err)
+		}
+	}
+	g.t.t.Logf("submitted voluntary exits for all %d members of group %q", len(g.keys), g.name)
+	return nil
+}
+
+// ParticipationDuringEpoch returns the fraction of group members that were
+// credited an attestation target reward in the given epoch, i.e. that
+// attested correctly.
+func (g *ValidatorGroup) ParticipationDuringEpoch(ctx context.Context, epoch common.Epoch) (float64, error) {
+	rewards, err := attestationRewards(ctx, g.t.roundRobinBeacon(), epoch, g.keys.Indices())
+	if err != nil {
+		return 0, fmt.Errorf("group %q: %w", g.name, err)
+	}
+	participating := 0
+	for _, r := range rewards {
+		if r.Target > 0 {
+			participating++
+		}
+	}
+	return float64(participating) / float64(len(g.keys)), nil
+}
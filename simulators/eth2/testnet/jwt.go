@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// AssertJWTSecretUniform checks that every execution client was started with
+// the testnet-wide engine API secret. Nodes started with a diverging or
+// missing secret fail engine authentication silently, so this is meant as a
+// quick assertion right after startup.
+func (t *Testnet) AssertJWTSecretUniform() error {
+	expected, err := hex.DecodeString(strings.TrimPrefix(defaultJWTSecret, "0x"))
+	if err != nil {
+		return fmt.Errorf("undecodable default JWT secret: %w", err)
+	}
+	var issues []string
+	for i, node := range t.eth1 {
+		secret, err := node.GetConfiguredJWTSecret()
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("node %d (%s): %v", i, node.Type, err))
+			continue
+		}
+		if !bytes.Equal(secret, expected) {
+			issues = append(issues, fmt.Sprintf("node %d (%s) uses a diverging JWT secret", i, node.Type))
+		}
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("JWT secrets are not uniform: %s", strings.Join(issues, "; "))
+	}
+	t.t.Logf("all %d execution clients share the engine API secret", len(t.eth1))
+	return nil
+}
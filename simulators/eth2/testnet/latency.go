@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// BlockLatencySample records how long after its slot started a block became
+// visible to the network.
+type BlockLatencySample struct {
+	Slot          common.Slot
+	ProposerIndex uint64
+	LatencyMs     int64
+	// IsBuilderBlock marks blocks that came from an external builder
+	// rather than local production.
+	IsBuilderBlock bool
+}
+
+// LatencyStats aggregates block production latency samples.
+type LatencyStats struct {
+	P50 int64
+	P95 int64
+	Max int64
+	// BuilderVsLocalDelta is the mean builder-block latency minus the mean
+	// locally built block latency, in milliseconds. Zero when either kind
+	// is missing from the samples.
+	BuilderVsLocalDelta float64
+}
+
+// slotStartTime returns the wall-clock start of a slot.
+func (t *Testnet) slotStartTime(slot common.Slot) time.Time {
+	return time.Unix(int64(t.genesisTime), 0).Add(time.Duration(slot) * t.slotDuration())
+}
+
+// MeasureBlockProductionLatency samples the production latency of the next
+// given number of slots: the time between a slot's start and its block being
+// announced as head. It prefers the SSE head stream for precise arrival
+// times and falls back to fast polling when the stream is unavailable.
+// Missed slots yield no sample.
+func (t *Testnet) MeasureBlockProductionLatency(ctx context.Context, slots common.Slot) ([]BlockLatencySample, error) {
+	endSlot := t.CurrentSlot() + slots
+	var samples []BlockLatencySample
+	record := func(slot common.Slot, proposer common.ValidatorIndex, seen time.Time) bool {
+		samples = append(samples, BlockLatencySample{
+			Slot:           slot,
+			ProposerIndex:  uint64(proposer),
+			LatencyMs:      seen.Sub(t.slotStartTime(slot)).Milliseconds(),
+			IsBuilderBlock: t.isBuilderBlock(slot),
+		})
+		return slot >= endSlot
+	}
+
+	bn := t.roundRobinBeacon()
+	_, err := streamBeaconEvents(ctx, bn, []string{"head"}, func(event BeaconEvent) bool {
+		var payload struct {
+			Slot          common.Slot           `json:"slot"`
+			ProposerIndex common.ValidatorIndex `json:"proposer_index"`
+		}
+		if err := json.Unmarshal(event.Data, &payload); err != nil {
+			return false
+		}
+		return record(payload.Slot, payload.ProposerIndex, time.Now())
+	})
+	if err == nil {
+		return samples, nil
+	}
+	t.t.Logf("SSE head stream unavailable (%v), measuring latency via polling", err)
+
+	// Polling fallback: sample well below slot granularity so arrival
+	// times stay meaningful.
+	timer := time.NewTicker(t.slotDuration() / 10)
+	defer timer.Stop()
+	var lastSeen common.Slot
+	for {
+		select {
+		case <-ctx.Done():
+			return samples, ctx.Err()
+		case <-timer.C:
+			block, exists, err := fetchBlock(ctx, bn, eth2api.BlockHead)
+			if err != nil {
+				return samples, err
+			}
+			if !exists || block.Slot() == lastSeen {
+				continue
+			}
+			lastSeen = block.Slot()
+			if record(block.Slot(), block.ProposerIndex(), time.Now()) {
+				return samples, nil
+			}
+		}
+	}
+}
+
+// BlockProductionLatencyStats aggregates latency samples into percentiles
+// and the builder-vs-local latency delta.
+func (t *Testnet) BlockProductionLatencyStats(samples []BlockLatencySample) LatencyStats {
+	var stats LatencyStats
+	if len(samples) == 0 {
+		return stats
+	}
+	latencies := make([]int64, len(samples))
+	var builderSum, localSum float64
+	builderCount, localCount := 0, 0
+	for i, s := range samples {
+		latencies[i] = s.LatencyMs
+		if s.IsBuilderBlock {
+			builderSum += float64(s.LatencyMs)
+			builderCount++
+		} else {
+			localSum += float64(s.LatencyMs)
+			localCount++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	stats.P50 = latencies[len(latencies)/2]
+	stats.P95 = latencies[len(latencies)*95/100]
+	stats.Max = latencies[len(latencies)-1]
+	if builderCount > 0 && localCount > 0 {
+		stats.BuilderVsLocalDelta = builderSum/float64(builderCount) - localSum/float64(localCount)
+	}
+	return stats
+}
+
+// isBuilderBlock reports whether the block of the given slot was produced by
+// an external builder. Without a builder in the testnet, no block is.
+func (t *Testnet) isBuilderBlock(slot common.Slot) bool {
+	t.builderSlotsMu.Lock()
+	defer t.builderSlotsMu.Unlock()
+	return t.builderSlots[slot]
+}
+
+// markBuilderSlot records that the block of the given slot came from an
+// external builder.
+func (t *Testnet) markBuilderSlot(slot common.Slot) {
+	t.builderSlotsMu.Lock()
+	defer t.builderSlotsMu.Unlock()
+	if t.builderSlots == nil {
+		t.builderSlots = make(map[common.Slot]bool)
+	}
+	t.builderSlots[slot] = true
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// lightClientUpdateEnvelope is the minimal shape of one entry of the light
+// client updates endpoint; only the parts needed to attribute an update to
+// its sync committee period are decoded.
+type lightClientUpdateEnvelope struct {
+	Version string `json:"version"`
+	Data    struct {
+		AttestedHeader struct {
+			Beacon struct {
+				Slot common.Slot `json:"slot"`
+			} `json:"beacon"`
+		} `json:"attested_header"`
+	} `json:"data"`
+}
+
+// lightClientUpdates queries a beacon node for the light client updates of
+// the given range of sync committee periods.
+func lightClientUpdates(ctx context.Context, bn *BeaconNode, startPeriod, count uint64) ([]lightClientUpdateEnvelope, error) {
+	var updates []lightClientUpdateEnvelope
+	req := eth2api.QueryGET(eth2api.Query{
+		"start_period": fmt.Sprintf("%d", startPeriod),
+		"count":        fmt.Sprintf("%d", count),
+	}, "/eth/v1/beacon/light_client/updates")
+	resp := bn.API.Request(ctx, req)
+	if _, err := resp.Decode(&updates); err != nil {
+		return nil, fmt.Errorf("failed to get light client updates [%d, %d): %w", startPeriod, startPeriod+count, err)
+	}
+	return updates, nil
+}
+
+// AssertLightClientUpdatesAvailable verifies that every beacon node serves a
+// light client update for each sync committee period in [startPeriod,
+// endPeriod], so light client sync protocol tests can check availability
+// continuously instead of at a single point.
+func (t *Testnet) AssertLightClientUpdatesAvailable(ctx context.Context, startPeriod, endPeriod uint64) error {
+	if endPeriod < startPeriod {
+		return fmt.Errorf("invalid period range [%d, %d]", startPeriod, endPeriod)
+	}
+	count := endPeriod - startPeriod + 1
+	slotsPerPeriod := uint64(t.spec.SLOTS_PER_EPOCH) * uint64(t.spec.EPOCHS_PER_SYNC_COMMITTEE_PERIOD)
+	for i, bn := range t.beacons {
+		updates, err := lightClientUpdates(ctx, bn, startPeriod, count)
+		if err != nil {
+			return fmt.Errorf("node %d: %w", i, err)
+		}
+		covered := make(map[uint64]bool, len(updates))
+		for _, update := range updates {
+			covered[uint64(update.Data.AttestedHeader.Beacon.Slot)/slotsPerPeriod] = true
+		}
+		for period := startPeriod; period <= endPeriod; period++ {
+			if !covered[period] {
+				return fmt.Errorf("node %d is missing the light client update of period %d", i, period)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ethereum/hive/hivesim"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+
+	"github.com/ethereum/hive/simulators/eth2/testnet/utils"
+)
+
+// validatorCount is the size of the genesis validator set used by the suite.
+const validatorCount = 64
+
+func main() {
+	var suite = hivesim.Suite{
+		Name: "eth2-testnet",
+		Description: `This suite runs a small eth2 testnet and verifies
+consensus-layer behavior: finality, validator lifecycle operations and
+cross-client consistency.`,
+	}
+	suite.Add(hivesim.TestSpec{
+		Name:        "testnet-finality",
+		Description: "Runs a two-node testnet and waits for finality.",
+		Run:         runFinalityTest,
+	})
+	hivesim.MustRunSuite(hivesim.New(), suite)
+}
+
+// runFinalityTest starts a testnet from the available client types and waits
+// for the network to finalize.
+func runFinalityTest(t *hivesim.T) {
+	node, err := findNodeDefinition(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validators, err := utils.GenerateInteropValidators(validatorCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &Environment{Validators: validators}
+	config := &Config{
+		ValidatorCount:     validatorCount,
+		SlotTime:           6,
+		AltairForkEpoch:    common.FAR_FUTURE_EPOCH,
+		BellatrixForkEpoch: common.FAR_FUTURE_EPOCH,
+		CapellaForkEpoch:   common.FAR_FUTURE_EPOCH,
+		DenebForkEpoch:     common.FAR_FUTURE_EPOCH,
+		ElectraForkEpoch:   common.FAR_FUTURE_EPOCH,
+		Nodes:              []NodeDefinition{*node, *node},
+	}
+	prep, err := prepareTestnet(t, env, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testnet := prep.startTestnet(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	finalized, err := testnet.WaitForFinality(ctx)
+	if err != nil {
+		t.Fatalf("failed to reach finality: %v", err)
+	}
+	t.Logf("reached finality at checkpoint %s", &finalized)
+}
+
+// findNodeDefinition assembles a node definition from the available client
+// types, based on the -bn/-vc naming convention of eth2 client images.
+func findNodeDefinition(t *hivesim.T) (*NodeDefinition, error) {
+	clients, err := t.Sim.ClientTypes()
+	if err != nil {
+		return nil, err
+	}
+	var def NodeDefinition
+	for _, client := range clients {
+		switch {
+		case strings.HasSuffix(client, "-bn"):
+			if def.BeaconNode == "" {
+				def.BeaconNode = client
+			}
+		case strings.HasSuffix(client, "-vc"):
+			if def.ValidatorClient == "" {
+				def.ValidatorClient = client
+			}
+		default:
+			if def.ExecutionClient == "" {
+				def.ExecutionClient = client
+			}
+		}
+	}
+	if def.BeaconNode == "" || def.ValidatorClient == "" || def.ExecutionClient == "" {
+		return nil, errIncompleteClientSet
+	}
+	return &def, nil
+}
+
+var errIncompleteClientSet = errors.New("no beacon node, validator client and execution client available")
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+
+	"github.com/ethereum/hive/simulators/eth2/testnet/utils"
+)
+
+// ValidatorClientIndex returns the index of the validator client currently
+// operating the given validator key, or -1 if no client runs it.
+func (t *Testnet) ValidatorClientIndex(pubkey common.BLSPubkey) int {
+	for i, vc := range t.validators {
+		if vc.Keys.ByPubKey(pubkey) != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidatorsOfNode returns the validator indices currently controlled by the
+// validator client of the given node.
+func (t *Testnet) ValidatorsOfNode(index int) ([]common.ValidatorIndex, error) {
+	if index < 0 || index >= len(t.validators) {
+		return nil, fmt.Errorf("node index %d out of range", index)
+	}
+	return t.validators[index].Keys.Indices(), nil
+}
+
+// OwnerOfValidator returns the index of the node whose validator client
+// controls the given validator. The mapping is cached; helpers that change
+// the key partition (migrations, deposits) invalidate the cache.
+func (t *Testnet) OwnerOfValidator(index common.ValidatorIndex) (int, error) {
+	t.ownerCacheMu.Lock()
+	if t.ownerCache == nil {
+		t.ownerCache = make(map[common.ValidatorIndex]int)
+		for i, vc := range t.validators {
+			for _, v := range vc.Keys {
+				t.ownerCache[v.Index] = i
+			}
+		}
+	}
+	owner, ok := t.ownerCache[index]
+	t.ownerCacheMu.Unlock()
+	if !ok {
+		return -1, fmt.Errorf("validator %d is not controlled by any validator client", index)
+	}
+	return owner, nil
+}
+
+// invalidateOwnerCache drops the validator-to-node mapping, to be called by
+// any helper that changes the key partition.
+func (t *Testnet) invalidateOwnerCache() {
+	t.ownerCacheMu.Lock()
+	t.ownerCache = nil
+	t.ownerCacheMu.Unlock()
+}
+
+// MigrateValidators moves the given validator keys from one validator client
+// to another, by restarting both with updated key bundles. The target client
+// picks up the keys first; the source keeps running with the old set for
+// overlapSlots slots before it is restarted without them, so a non-zero
+// overlap deliberately has both clients operating the keys at once, to
+// provoke the doppelganger and slashing-protection behavior of the clients.
+// The key-partition bookkeeping is updated so that ValidatorClientIndex and
+// proposer attribution stay correct afterwards.
+func (t *Testnet) MigrateValidators(ctx context.Context, keys [][48]byte, fromNode, toNode int, overlapSlots common.Slot) error {
+	if fromNode < 0 || fromNode >= len(t.validators) || toNode < 0 || toNode >= len(t.validators) {
+		return fmt.Errorf("node indices %d -> %d out of range", fromNode, toNode)
+	}
+	if fromNode == toNode {
+		return fmt.Errorf("source and target validator client are the same")
+	}
+	source, target := t.validators[fromNode], t.validators[toNode]
+
+	// Partition the source keys into the ones staying and the ones moving.
+	moving := make(map[common.BLSPubkey]bool, len(keys))
+	for _, key := range keys {
+		pubkey := common.BLSPubkey(key)
+		v := source.Keys.ByPubKey(pubkey)
+		if v == nil {
+			return fmt.Errorf("validator client %d does not run key %s", fromNode, pubkey.String())
+		}
+		moving[pubkey] = true
+	}
+	var kept, moved utils.Validators
+	for _, v := range source.Keys {
+		if moving[v.PubKey] {
+			moved = append(moved, v)
+		} else {
+			kept = append(kept, v)
+		}
+	}
+
+	workDir, err := os.MkdirTemp("", "eth2-testnet-migration")
+	if err != nil {
+		return err
+	}
+
+	// Restart the target with the extended key set; the source still runs
+	// the moved keys at this point.
+	newTargetKeys := append(append(utils.Validators{}, target.Keys...), moved...)
+	newTarget, err := t.restartValidatorClient(target, filepath.Join(workDir, "target-keys.json"), newTargetKeys)
+	if err != nil {
+		return fmt.Errorf("failed to restart target validator client %d: %w", toNode, err)
+	}
+	t.validators[toNode] = newTarget
+
+	if overlapSlots > 0 {
+		t.t.Logf("migration overlap: clients %d and %d both run %d keys for %d slots",
+			fromNode, toNode, len(moved), overlapSlots)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(overlapSlots) * t.slotDuration()):
+		}
+	}
+
+	// Restart the source with the reduced key set, ending the overlap.
+	newSource, err := t.restartValidatorClient(source, filepath.Join(workDir, "source-keys.json"), kept)
+	if err != nil {
+		return fmt.Errorf("failed to restart source validator client %d: %w", fromNode, err)
+	}
+	t.validators[fromNode] = newSource
+	t.invalidateOwnerCache()
+
+	t.t.Logf("migrated %d validator keys from client %d to client %d", len(moved), fromNode, toNode)
+	return nil
+}
+
+// restartValidatorClient stops the given validator client and starts a
+// replacement of the same type with the given key set.
+func (t *Testnet) restartValidatorClient(vc *ValidatorClient, keyPath string, keys utils.Validators) (*ValidatorClient, error) {
+	if err := exportKeyBundle(keyPath, keys); err != nil {
+		return nil, err
+	}
+	if err := t.t.Sim.StopClient(t.t.SuiteID, t.t.TestID, vc.Container); err != nil {
+		return nil, fmt.Errorf("failed to stop client %s: %w", vc.Container, err)
+	}
+	cl := t.t.StartClient(vc.Type, vc.params, map[string]string{
+		"/hive/input/keys.json": keyPath,
+	})
+	return &ValidatorClient{Client: cl, Keys: keys, params: vc.params, graffiti: vc.graffiti}, nil
+}
@@ -0,0 +1,517 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/validatorapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// BuilderOptions configures the behavior of one node's mock builder. The
+// payload-producing side is pluggable, like the KZG backends: without a
+// BidSource the builder answers getHeader with 204 No Content, which still
+// exercises and records the consensus client's builder-path plumbing.
+type BuilderOptions struct {
+	// BidSource produces the SignedBuilderBid JSON for a getHeader request,
+	// along with the execution block hash the bid commits to. Nil means no
+	// bids are returned.
+	BidSource func(slot common.Slot, parentHash ethcommon.Hash, pubkey string) (bid json.RawMessage, blockHash ethcommon.Hash, err error)
+
+	// PayloadSource produces the full payload response for a blinded block
+	// submission that committed to the given block hash. Nil makes
+	// submitBlindedBlock fail, which models a withholding builder.
+	PayloadSource func(slot common.Slot, blockHash ethcommon.Hash) (json.RawMessage, error)
+
+	// BidValue overrides the value of every bid, in wei. Nil keeps the value
+	// the bid source produced.
+	BidValue *big.Int
+
+	// FeeRecipient overrides the fee recipient in the execution header of
+	// every bid, for tests that the proposer's registered fee recipient is
+	// actually honored rather than silently substituted.
+	FeeRecipient *ethcommon.Address
+
+	// CorruptBlobCommitments makes Deneb reveals carry a blobs bundle whose
+	// commitments do not match the blinded block's, which every client must
+	// reject before publishing.
+	CorruptBlobCommitments bool
+}
+
+// MockBuilder is an in-process implementation of the builder API for one
+// beacon node: the node's HIVE_ETH2_BUILDER_ENDPOINT points at it, and every
+// getHeader, blinded block submission and reveal passes through it and is
+// recorded. What it bids is controlled by BuilderOptions.
+type MockBuilder struct {
+	t         *Testnet
+	nodeIndex int
+	listener  net.Listener
+	options   BuilderOptions
+	// scenario is the configured builder disruption, nil for honest serving.
+	scenario *BuilderScenario
+
+	mu sync.Mutex
+	// request and delivery records, exposed through the builder stats.
+	headerRequests     int
+	headerRequestSlots map[common.Slot]int
+	bidsReturned       int
+	bidValues          []string
+	blindedSubmissions int
+	reveals            int
+	// deliveredHashes maps slots to the execution block hash the builder bid
+	// for them, for cross-referencing with the canonical chain.
+	deliveredHashes map[common.Slot]ethcommon.Hash
+	// requests captures every builder API call the consensus client made, for
+	// conformance checking.
+	requests []builderRequestRecord
+	// closed marks a builder whose endpoint was shut down mid-test.
+	closed bool
+}
+
+// builderRequestRecord is one captured builder API call.
+type builderRequestRecord struct {
+	Method      string
+	Path        string
+	ContentType string
+	Accept      string
+	Body        []byte
+}
+
+// recordRequest captures one builder API call made by the consensus client.
+func (b *MockBuilder) recordRequest(r *http.Request, body []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests = append(b.requests, builderRequestRecord{
+		Method:      r.Method,
+		Path:        r.URL.Path,
+		ContentType: r.Header.Get("Content-Type"),
+		Accept:      r.Header.Get("Accept"),
+		Body:        body,
+	})
+}
+
+// capturedRequests returns a snapshot of the builder API calls made so far.
+func (b *MockBuilder) capturedRequests() []builderRequestRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]builderRequestRecord(nil), b.requests...)
+}
+
+// newMockBuilder starts a mock builder for the given node, listening on the
+// simulator network.
+func newMockBuilder(t *Testnet, nodeIndex int, options BuilderOptions) (*MockBuilder, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:0", simulatorIP()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for mock builder: %w", err)
+	}
+	b := &MockBuilder{
+		t:                  t,
+		nodeIndex:          nodeIndex,
+		listener:           listener,
+		options:            options,
+		headerRequestSlots: make(map[common.Slot]int),
+		deliveredHashes:    make(map[common.Slot]ethcommon.Hash),
+	}
+	go http.Serve(listener, b)
+	return b, nil
+}
+
+// Close shuts the builder's endpoint down: further builder API calls from
+// the consensus client fail at the connection level, as with a crashed relay.
+// The recorded stats stay readable. Closing twice is an error.
+func (b *MockBuilder) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return fmt.Errorf("builder of node %d already closed", b.nodeIndex)
+	}
+	b.closed = true
+	return b.listener.Close()
+}
+
+// Endpoint returns the builder API address of the mock builder.
+func (b *MockBuilder) Endpoint() string {
+	return fmt.Sprintf("http://%s", b.listener.Addr())
+}
+
+// ServeHTTP dispatches builder API calls.
+func (b *MockBuilder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/eth/v1/builder/status":
+		b.recordRequest(r, nil)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == "POST" && r.URL.Path == "/eth/v1/builder/validators":
+		// Validator registrations are accepted and dropped; the mock bids
+		// for any pubkey.
+		body, _ := io.ReadAll(r.Body)
+		b.recordRequest(r, body)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/eth/v1/builder/header/"):
+		b.serveHeader(w, r)
+	case r.Method == "POST" && r.URL.Path == "/eth/v1/builder/blinded_blocks":
+		b.serveBlindedBlock(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveHeader answers a getHeader request: it records the request and returns
+// a bid from the configured source, or 204 without one.
+func (b *MockBuilder) serveHeader(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/eth/v1/builder/header/"), "/")
+	if len(parts) != 3 {
+		http.Error(w, "bad header request path", http.StatusBadRequest)
+		return
+	}
+	slotNum, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		http.Error(w, "bad slot", http.StatusBadRequest)
+		return
+	}
+	slot := common.Slot(slotNum)
+	parentHash := ethcommon.HexToHash(parts[1])
+	b.recordRequest(r, nil)
+
+	b.mu.Lock()
+	b.headerRequests++
+	b.headerRequestSlots[slot]++
+	b.mu.Unlock()
+
+	if b.options.BidSource == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	bid, blockHash, err := b.options.BidSource(slot, parentHash, parts[2])
+	if err != nil {
+		b.t.t.Logf("mock builder of node %d: bid source failed at slot %d: %v", b.nodeIndex, slot, err)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	bid = applyBidOverrides(bid, b.options)
+	b.mu.Lock()
+	b.bidsReturned++
+	b.bidValues = append(b.bidValues, bidValue(bid))
+	b.deliveredHashes[slot] = blockHash
+	b.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"version":"%s","data":%s}`, versionAtSlot(b.t.spec, slot), bid)
+}
+
+// serveBlindedBlock answers a submitBlindedBlock request: it records the
+// submission and reveals the full payload from the configured source.
+func (b *MockBuilder) serveBlindedBlock(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slot := publishedBlockSlot(body)
+	b.recordRequest(r, body)
+
+	b.mu.Lock()
+	b.blindedSubmissions++
+	blockHash, haveBid := b.deliveredHashes[slot]
+	scenario := b.scenario
+	b.mu.Unlock()
+
+	if scenario != nil && scenario.Withhold != nil && scenario.Withhold(slot) {
+		b.t.markScenarioSlot(slot, scenarioSlotWithheld)
+		b.t.t.Logf("mock builder of node %d: withholding payload of slot %d per scenario", b.nodeIndex, slot)
+		http.Error(w, "payload withheld", http.StatusInternalServerError)
+		return
+	}
+	if !haveBid || b.options.PayloadSource == nil {
+		http.Error(w, "no payload for this submission", http.StatusBadRequest)
+		return
+	}
+	payload, err := b.options.PayloadSource(slot, blockHash)
+	if err != nil {
+		b.t.t.Logf("mock builder of node %d: payload source failed at slot %d: %v", b.nodeIndex, slot, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if scenario != nil && scenario.MutatePayload != nil {
+		if mutated, changed := scenario.MutatePayload(b.t.spec.SlotToEpoch(slot), slot, payload); changed {
+			b.t.markScenarioSlot(slot, scenarioSlotCorrupted)
+			b.t.t.Logf("mock builder of node %d: corrupting payload of slot %d per scenario", b.nodeIndex, slot)
+			payload = mutated
+		}
+	}
+	if b.options.CorruptBlobCommitments {
+		if mutated, changed := corruptBlobsBundle(payload); changed {
+			b.t.markScenarioSlot(slot, scenarioSlotBadBlobs)
+			b.t.t.Logf("mock builder of node %d: serving mismatched blob commitments for slot %d", b.nodeIndex, slot)
+			payload = mutated
+		}
+	}
+	b.mu.Lock()
+	b.reveals++
+	b.mu.Unlock()
+	b.t.markBuilderSlot(slot)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"version":"%s","data":%s}`, versionAtSlot(b.t.spec, slot), payload)
+}
+
+// applyBidOverrides rewrites the value and fee recipient of a bid per the
+// builder options, leaving the bid untouched when neither override is set or
+// its JSON does not parse.
+func applyBidOverrides(bid json.RawMessage, options BuilderOptions) json.RawMessage {
+	if options.BidValue == nil && options.FeeRecipient == nil {
+		return bid
+	}
+	var signed map[string]json.RawMessage
+	if err := json.Unmarshal(bid, &signed); err != nil {
+		return bid
+	}
+	var message map[string]json.RawMessage
+	if err := json.Unmarshal(signed["message"], &message); err != nil {
+		return bid
+	}
+	if options.BidValue != nil {
+		message["value"] = json.RawMessage(fmt.Sprintf("%q", options.BidValue.String()))
+	}
+	if options.FeeRecipient != nil {
+		var header map[string]json.RawMessage
+		if err := json.Unmarshal(message["header"], &header); err != nil {
+			return bid
+		}
+		header["fee_recipient"] = json.RawMessage(fmt.Sprintf("%q", strings.ToLower(options.FeeRecipient.Hex())))
+		rewritten, err := json.Marshal(header)
+		if err != nil {
+			return bid
+		}
+		message["header"] = rewritten
+	}
+	rewritten, err := json.Marshal(message)
+	if err != nil {
+		return bid
+	}
+	signed["message"] = rewritten
+	out, err := json.Marshal(signed)
+	if err != nil {
+		return bid
+	}
+	return out
+}
+
+// setScenario swaps the builder's disruption scenario at runtime; nil
+// restores honest serving.
+func (b *MockBuilder) setScenario(scenario *BuilderScenario) {
+	b.mu.Lock()
+	b.scenario = scenario
+	b.mu.Unlock()
+}
+
+// bidValue extracts the value field of a SignedBuilderBid for the records.
+func bidValue(bid json.RawMessage) string {
+	var parsed struct {
+		Message struct {
+			Value string `json:"value"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(bid, &parsed); err != nil {
+		return ""
+	}
+	return parsed.Message.Value
+}
+
+// versionAtSlot names the fork a slot falls in, for versioned builder
+// responses.
+func versionAtSlot(spec *common.Spec, slot common.Slot) string {
+	epoch := spec.SlotToEpoch(slot)
+	switch {
+	case epoch >= spec.DENEB_FORK_EPOCH:
+		return "deneb"
+	case epoch >= spec.CAPELLA_FORK_EPOCH:
+		return "capella"
+	case epoch >= spec.BELLATRIX_FORK_EPOCH:
+		return "bellatrix"
+	case epoch >= spec.ALTAIR_FORK_EPOCH:
+		return "altair"
+	}
+	return "phase0"
+}
+
+// BuilderStats is a point-in-time snapshot of what one node's mock builder
+// has seen: how often the consensus client asked for headers and for which
+// slots, how many bids came back and at what values, and how many blinded
+// blocks were submitted and revealed.
+type BuilderStats struct {
+	// HeaderRequests counts getHeader calls; HeaderRequestSlots breaks them
+	// down by slot, so cadence checks can tell a silent stop from a restart.
+	HeaderRequests     int
+	HeaderRequestSlots map[common.Slot]int
+
+	// BidsReturned counts the getHeader calls answered with a bid, and
+	// BidValues records the value of each bid in order.
+	BidsReturned int
+	BidValues    []string
+
+	// BlindedSubmissions counts submitBlindedBlock calls; Reveals counts the
+	// ones answered with a full payload.
+	BlindedSubmissions int
+	Reveals            int
+}
+
+// Stats returns a snapshot of the builder's request and delivery records.
+func (b *MockBuilder) Stats() BuilderStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := BuilderStats{
+		HeaderRequests:     b.headerRequests,
+		HeaderRequestSlots: make(map[common.Slot]int, len(b.headerRequestSlots)),
+		BidsReturned:       b.bidsReturned,
+		BidValues:          append([]string(nil), b.bidValues...),
+		BlindedSubmissions: b.blindedSubmissions,
+		Reveals:            b.reveals,
+	}
+	for slot, count := range b.headerRequestSlots {
+		stats.HeaderRequestSlots[slot] = count
+	}
+	return stats
+}
+
+// BuilderStats returns the builder metrics of the given node's mock builder.
+func (t *Testnet) BuilderStats(nodeIndex int) (BuilderStats, error) {
+	builder, err := t.MockBuilderAt(nodeIndex)
+	if err != nil {
+		return BuilderStats{}, err
+	}
+	return builder.Stats(), nil
+}
+
+// RequireBidsEverySlot asserts that the given node's consensus client asked
+// its builder for a header in every slot of [fromSlot, toSlot]. A client that
+// silently stops calling getHeader after an error passes chain health checks
+// but fails this one.
+func (t *Testnet) RequireBidsEverySlot(ctx context.Context, fromSlot, toSlot common.Slot, nodeIndex int) error {
+	stats, err := t.BuilderStats(nodeIndex)
+	if err != nil {
+		return err
+	}
+	var missed []string
+	for slot := fromSlot; slot <= toSlot; slot++ {
+		if stats.HeaderRequestSlots[slot] == 0 {
+			missed = append(missed, fmt.Sprintf("%d", slot))
+		}
+	}
+	if len(missed) > 0 {
+		return fmt.Errorf("node %d did not query its builder at slots %s", nodeIndex, strings.Join(missed, ", "))
+	}
+	t.t.Logf("node %d queried its builder in all %d slots of [%d, %d]",
+		nodeIndex, toSlot-fromSlot+1, fromSlot, toSlot)
+	return nil
+}
+
+// MockBuilderAt returns the mock builder attached to the given node, or an
+// error when the node runs without one.
+func (t *Testnet) MockBuilderAt(nodeIndex int) (*MockBuilder, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return nil, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	builder, ok := t.mockBuilders[nodeIndex]
+	if !ok {
+		return nil, fmt.Errorf("node %d has no builder attached", nodeIndex)
+	}
+	return builder, nil
+}
+
+// CancelBuilder shuts down just the given node's mock builder mid-test,
+// modeling a relay that dies while the testnet keeps running. The consensus
+// client is expected to fall back to local block production; pair with
+// AssertNextProposalWithoutBuilder to check it does so without stalling.
+func (t *Testnet) CancelBuilder(ctx context.Context, nodeIndex int) error {
+	builder, err := t.MockBuilderAt(nodeIndex)
+	if err != nil {
+		return err
+	}
+	if err := builder.Close(); err != nil {
+		return err
+	}
+	t.t.Logf("builder of node %d canceled at slot %d", nodeIndex, t.CurrentSlot())
+	return nil
+}
+
+// AssertNextProposalWithoutBuilder waits for the next proposal of one of the
+// given node's validators and checks it arrived in its slot with a locally
+// built payload. Clients that keep waiting on a dead builder endpoint stall
+// here for several slots; run it right after CancelBuilder.
+func (t *Testnet) AssertNextProposalWithoutBuilder(ctx context.Context, nodeIndex int) error {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	fromSlot := t.CurrentSlot() + 1
+	currentEpoch := t.CurrentEpoch()
+	var dutySlot common.Slot
+	found := false
+	for _, epoch := range []common.Epoch{currentEpoch, currentEpoch + 1} {
+		var duties eth2api.DependentProposerDuty
+		if _, err := validatorapi.ProposerDuties(ctx, t.roundRobinBeacon().API, epoch, &duties); err != nil {
+			return fmt.Errorf("failed to fetch proposer duties of epoch %d: %w", epoch, err)
+		}
+		for _, duty := range duties.Data {
+			owner, err := t.OwnerOfValidator(duty.ValidatorIndex)
+			if err != nil || owner != nodeIndex || duty.Slot < fromSlot {
+				continue
+			}
+			if !found || duty.Slot < dutySlot {
+				dutySlot, found = duty.Slot, true
+			}
+		}
+		if found {
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("node %d has no upcoming proposal in the current or next epoch", nodeIndex)
+	}
+	t.t.Logf("node %d proposes next at slot %d, watching for a local fallback block", nodeIndex, dutySlot)
+
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for t.CurrentSlot() <= dutySlot+1 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	block, exists, err := fetchBlock(ctx, t.roundRobinBeacon(), eth2api.BlockIdSlot(dutySlot))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("node %d missed its proposal at slot %d after its builder was canceled", nodeIndex, dutySlot)
+	}
+	if hash := t.builderDeliveredHash(dutySlot); hash != (ethcommon.Hash{}) {
+		if payload := block.executionPayloadEnvelope(); payload != nil && payload.BlockHash() == hash {
+			return fmt.Errorf("block at slot %d carries a builder payload, expected a local block after cancellation", dutySlot)
+		}
+	}
+	t.t.Logf("node %d proposed a locally built block at slot %d despite the dead builder", nodeIndex, dutySlot)
+	return nil
+}
+
+// builderEnabledNodes returns the indices of the nodes running with a mock
+// builder, in order. Builder activity helpers iterate these instead of all
+// nodes.
+func (t *Testnet) builderEnabledNodes() []int {
+	var nodes []int
+	for i := range t.beacons {
+		if _, ok := t.mockBuilders[i]; ok {
+			nodes = append(nodes, i)
+		}
+	}
+	return nodes
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/hive/hivesim"
+	"github.com/protolambda/eth2api"
+
+	"github.com/ethereum/hive/simulators/eth2/testnet/utils"
+)
+
+const (
+	// PortEth1RPC is the JSON-RPC port of execution clients.
+	PortEth1RPC = 8545
+	// PortBeaconAPI is the standard Beacon API port of beacon nodes.
+	PortBeaconAPI = 4000
+	// PortRemoteSigner is the signing API port of remote signer clients.
+	PortRemoteSigner = 9000
+	// PortKeymanagerAPI is the keymanager API port of validator clients.
+	PortKeymanagerAPI = 7500
+)
+
+// defaultRemoteSignerClient is the hive role of remote signer containers,
+// when a node definition does not name one explicitly.
+const defaultRemoteSignerClient = "eth2-remote-signer"
+
+// RemoteSigner wraps a running remote signer holding a node's keys.
+type RemoteSigner struct {
+	*hivesim.Client
+}
+
+// SigningURL returns the signing API endpoint of the signer.
+func (s *RemoteSigner) SigningURL() string {
+	return fmt.Sprintf("http://%v:%d", s.IP, PortRemoteSigner)
+}
+
+// defaultJWTSecret is the engine API authentication secret handed to every
+// execution client, unless a node definition overrides it. All nodes must
+// agree on it or engine authentication fails in hard-to-diagnose ways.
+const defaultJWTSecret = "0x7365637265747365637265747365637265747365637265747365637265747365"
+
+// Eth1Node wraps a running execution-layer client of the testnet.
+type Eth1Node struct {
+	*hivesim.Client
+
+	// params are the startup parameters of the client, kept so configuration
+	// checks can read back what the node was actually started with.
+	params hivesim.Params
+}
+
+// GetConfiguredJWTSecret returns the engine API secret the node was started
+// with, decoded from its HIVE_JWTSECRET parameter.
+func (n *Eth1Node) GetConfiguredJWTSecret() ([]byte, error) {
+	value, ok := n.params["HIVE_JWTSECRET"]
+	if !ok || value == "" {
+		return nil, fmt.Errorf("node was started without a JWT secret")
+	}
+	secret, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("undecodable JWT secret %q: %w", value, err)
+	}
+	if len(secret) != 32 {
+		return nil, fmt.Errorf("JWT secret is %d bytes, want 32", len(secret))
+	}
+	return secret, nil
+}
+
+// BeaconNode wraps a running beacon node and its Beacon API client.
+type BeaconNode struct {
+	*hivesim.Client
+	API *eth2api.Eth2HttpClient
+
+	// params and files are the startup inputs of the node, kept so it can be
+	// restarted with adjusted parameters.
+	params hivesim.Params
+	files  map[string]string
+}
+
+// NewBeaconNode wraps the given hive client as a beacon node.
+func NewBeaconNode(cl *hivesim.Client) *BeaconNode {
+	return &BeaconNode{
+		Client: cl,
+		API: &eth2api.Eth2HttpClient{
+			Addr:  fmt.Sprintf("http://%v:%d", cl.IP, PortBeaconAPI),
+			Cli:   &http.Client{Timeout: 10 * time.Second},
+			Codec: eth2api.JSONCodec{},
+		},
+	}
+}
+
+// ValidatorClient wraps a running validator client and the keys it operates.
+type ValidatorClient struct {
+	*hivesim.Client
+	Keys utils.Validators
+
+	// params are the startup parameters of the client, kept so the client
+	// can be restarted with a different key set.
+	params hivesim.Params
+
+	// graffiti is the graffiti string the client was configured with, if any.
+	graffiti string
+}
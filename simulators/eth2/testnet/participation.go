@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// participationWorstOffenderMax caps how many of the worst-performing
+// validators a participation report lists individually.
+const participationWorstOffenderMax = 10
+
+// ClientParticipation summarizes the attestation performance of the
+// validators owned by one validator client during one epoch.
+type ClientParticipation struct {
+	NodeIndex  int
+	Validators int
+
+	// Missed vote counts, out of Validators.
+	MissedSource int
+	MissedTarget int
+	MissedHead   int
+}
+
+// ParticipationReport breaks the attestation participation of one epoch down
+// by the validator client owning each validator, for debugging failed health
+// assertions.
+type ParticipationReport struct {
+	Epoch   common.Epoch
+	Clients []ClientParticipation
+
+	// WorstOffenders are the individual validators with the lowest total
+	// attestation reward in the epoch, worst first.
+	WorstOffenders []AttestationReward
+}
+
+// ParticipationReport reads the attestation rewards of the given epoch from
+// one verification node and groups the misses by the validator client owning
+// each validator, per the key partition.
+func (t *Testnet) ParticipationReport(ctx context.Context, epoch common.Epoch) (*ParticipationReport, error) {
+	rewards, err := attestationRewards(ctx, t.roundRobinBeacon(), epoch, t.Validators.Indices())
+	if err != nil {
+		return nil, err
+	}
+	byIndex := make(map[common.ValidatorIndex]*AttestationReward, len(rewards))
+	for i := range rewards {
+		byIndex[rewards[i].ValidatorIndex] = &rewards[i]
+	}
+
+	report := &ParticipationReport{Epoch: epoch}
+	for i, vc := range t.validators {
+		p := ClientParticipation{NodeIndex: i, Validators: len(vc.Keys)}
+		for _, v := range vc.Keys {
+			r := byIndex[v.Index]
+			if r == nil {
+				p.MissedSource++
+				p.MissedTarget++
+				p.MissedHead++
+				continue
+			}
+			if r.Source <= 0 {
+				p.MissedSource++
+			}
+			if r.Target <= 0 {
+				p.MissedTarget++
+			}
+			if r.Head <= 0 {
+				p.MissedHead++
+			}
+		}
+		report.Clients = append(report.Clients, p)
+	}
+
+	sort.Slice(rewards, func(i, j int) bool { return rewards[i].Total() < rewards[j].Total() })
+	for _, r := range rewards {
+		if len(report.WorstOffenders) == participationWorstOffenderMax || r.Total() > 0 {
+			break
+		}
+		report.WorstOffenders = append(report.WorstOffenders, r)
+	}
+	return report, nil
+}
+
+func (r *ParticipationReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "participation in epoch %d:\n", r.Epoch)
+	for _, c := range r.Clients {
+		fmt.Fprintf(&b, "  client %d (%d validators): missed source %d, target %d, head %d\n",
+			c.NodeIndex, c.Validators, c.MissedSource, c.MissedTarget, c.MissedHead)
+	}
+	for _, o := range r.WorstOffenders {
+		fmt.Fprintf(&b, "  validator %d: source %d, target %d, head %d gwei\n",
+			o.ValidatorIndex, o.Source, o.Target, o.Head)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/hive/simulators/eth2/testnet/utils"
+)
+
+// KeyPartition returns the validator keys operated by each node, by node
+// index. The slices alias the testnet's key material and must not be
+// modified.
+func (t *Testnet) KeyPartition() map[int]utils.Validators {
+	partition := make(map[int]utils.Validators, len(t.validators))
+	for i, vc := range t.validators {
+		partition[i] = vc.Keys
+	}
+	return partition
+}
+
+// ValidatePartition checks the validator key partition for the mistakes that
+// otherwise surface hours later as missed attestations or slashings: a key
+// handed to two validator clients without a declared duplicate, keys left
+// unassigned, or a partition that does not cover the configured validator
+// count. It returns an error naming the offending pubkeys and node indices.
+func (t *Testnet) ValidatePartition() error {
+	var issues []string
+	owners := make(map[string]int)
+	assigned := 0
+	for i, vc := range t.validators {
+		declared, isDuplicate := t.duplicateNodes[i]
+		for _, v := range vc.Keys {
+			pubkey := v.PubKey.String()
+			if prev, seen := owners[pubkey]; seen {
+				// An overlap is only fine when this node is declared as a
+				// duplicate of exactly the node that owns the key.
+				if !isDuplicate || declared != prev {
+					issues = append(issues, fmt.Sprintf("key %s assigned to both node %d and node %d", pubkey, prev, i))
+				}
+				continue
+			}
+			owners[pubkey] = i
+			assigned++
+		}
+	}
+	for _, v := range t.Validators {
+		if _, seen := owners[v.PubKey.String()]; !seen {
+			issues = append(issues, fmt.Sprintf("key %s is not assigned to any node", v.PubKey.String()))
+		}
+	}
+	if assigned != len(t.Validators) {
+		issues = append(issues, fmt.Sprintf("partition covers %d distinct keys, want %d", assigned, len(t.Validators)))
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("bad key partition: %s", strings.Join(issues, "; "))
+	}
+	return nil
+}
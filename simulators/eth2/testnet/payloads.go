@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/bellatrix"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/deneb"
+)
+
+// Blob base fee parameters of EIP-4844.
+const (
+	minBlobBaseFee            = 1
+	blobBaseFeeUpdateFraction = 3338477
+)
+
+// ExecutionPayloadEnvelope wraps the versioned execution payload of a beacon
+// block, together with the beacon-side context needed by payload attribute
+// checks. Exactly one of the per-fork payload fields is set.
+type ExecutionPayloadEnvelope struct {
+	Version   string
+	Slot      common.Slot
+	Bellatrix *bellatrix.ExecutionPayload
+	Capella   *capella.ExecutionPayload
+	Deneb     *deneb.ExecutionPayload
+
+	// ParentBeaconBlockRoot is the beacon block root the EIP-4788 oracle
+	// stores for this payload's block, i.e. the beacon block's parent root.
+	// Zero before Deneb.
+	ParentBeaconBlockRoot common.Root
+}
+
+// BlockHash returns the execution block hash of the payload.
+func (e *ExecutionPayloadEnvelope) BlockHash() ethcommon.Hash {
+	switch {
+	case e.Bellatrix != nil:
+		return ethcommon.Hash(e.Bellatrix.BlockHash)
+	case e.Capella != nil:
+		return ethcommon.Hash(e.Capella.BlockHash)
+	case e.Deneb != nil:
+		return ethcommon.Hash(e.Deneb.BlockHash)
+	}
+	return ethcommon.Hash{}
+}
+
+// BlockNumber returns the execution block number of the payload.
+func (e *ExecutionPayloadEnvelope) BlockNumber() uint64 {
+	switch {
+	case e.Bellatrix != nil:
+		return uint64(e.Bellatrix.BlockNumber)
+	case e.Capella != nil:
+		return uint64(e.Capella.BlockNumber)
+	case e.Deneb != nil:
+		return uint64(e.Deneb.BlockNumber)
+	}
+	return 0
+}
+
+// FeeRecipient returns the fee recipient of the payload.
+func (e *ExecutionPayloadEnvelope) FeeRecipient() ethcommon.Address {
+	switch {
+	case e.Bellatrix != nil:
+		return ethcommon.Address(e.Bellatrix.FeeRecipient)
+	case e.Capella != nil:
+		return ethcommon.Address(e.Capella.FeeRecipient)
+	case e.Deneb != nil:
+		return ethcommon.Address(e.Deneb.FeeRecipient)
+	}
+	return ethcommon.Address{}
+}
+
+// Withdrawals returns the withdrawals of the payload, empty before Capella.
+func (e *ExecutionPayloadEnvelope) Withdrawals() common.Withdrawals {
+	switch {
+	case e.Capella != nil:
+		return e.Capella.Withdrawals
+	case e.Deneb != nil:
+		return e.Deneb.Withdrawals
+	}
+	return nil
+}
+
+// ExcessBlobGas returns the excess blob gas of the payload, with ok=false
+// before Deneb.
+func (e *ExecutionPayloadEnvelope) ExcessBlobGas() (uint64, bool) {
+	if e.Deneb != nil {
+		return uint64(e.Deneb.ExcessBlobGas), true
+	}
+	return 0, false
+}
+
+// executionPayloadEnvelope extracts the execution payload of the block, nil
+// for pre-Bellatrix blocks without one.
+func (b *SignedBlock) executionPayloadEnvelope() *ExecutionPayloadEnvelope {
+	switch {
+	case b.Bellatrix != nil:
+		return &ExecutionPayloadEnvelope{
+			Version:   b.Version,
+			Slot:      b.Slot(),
+			Bellatrix: &b.Bellatrix.Message.Body.ExecutionPayload,
+		}
+	case b.Capella != nil:
+		return &ExecutionPayloadEnvelope{
+			Version: b.Version,
+			Slot:    b.Slot(),
+			Capella: &b.Capella.Message.Body.ExecutionPayload,
+		}
+	case b.Deneb != nil:
+		return &ExecutionPayloadEnvelope{
+			Version:               b.Version,
+			Slot:                  b.Slot(),
+			Deneb:                 &b.Deneb.Message.Body.ExecutionPayload,
+			ParentBeaconBlockRoot: b.ParentRoot(),
+		}
+	}
+	return nil
+}
+
+// WaitForExecutionPayloadWithAttribute polls head blocks until one carries an
+// execution payload for which the check returns true, and returns that
+// payload's execution block hash. The check receives the full payload, so a
+// single wait function covers arbitrary payload-attribute conditions; see
+// HasWithdrawals, HasNonZeroBeaconRoot and HasBlobBaseFeeAbove for built-in
+// checks.
+func (t *Testnet) WaitForExecutionPayloadWithAttribute(ctx context.Context, check func(*ExecutionPayloadEnvelope) bool) (ethcommon.Hash, error) {
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	var lastSeen common.Slot
+	for {
+		select {
+		case <-ctx.Done():
+			return ethcommon.Hash{}, ctx.Err()
+		case <-timer.C:
+			block, exists, err := fetchBlock(ctx, t.roundRobinBeacon(), eth2api.BlockHead)
+			if err != nil {
+				return ethcommon.Hash{}, err
+			}
+			if !exists || block.Slot() == lastSeen {
+				continue
+			}
+			lastSeen = block.Slot()
+			envelope := block.executionPayloadEnvelope()
+			if envelope == nil {
+				continue
+			}
+			if check(envelope) {
+				t.t.Logf("payload %v at slot %d matches the attribute check", envelope.BlockHash(), envelope.Slot)
+				return envelope.BlockHash(), nil
+			}
+			t.t.Logf("payload at slot %d does not match the attribute check%s", lastSeen, t.engineLatencyStatus())
+		}
+	}
+}
+
+// HasWithdrawals matches payloads carrying at least n withdrawals.
+func HasWithdrawals(n int) func(*ExecutionPayloadEnvelope) bool {
+	return func(e *ExecutionPayloadEnvelope) bool {
+		return len(e.Withdrawals()) >= n
+	}
+}
+
+// HasNonZeroBeaconRoot matches payloads whose EIP-4788 parent beacon block
+// root is set.
+func HasNonZeroBeaconRoot() func(*ExecutionPayloadEnvelope) bool {
+	return func(e *ExecutionPayloadEnvelope) bool {
+		return e.ParentBeaconBlockRoot != (common.Root{})
+	}
+}
+
+// HasBlobBaseFeeAbove matches payloads whose blob base fee, derived from the
+// excess blob gas per EIP-4844, exceeds the threshold.
+func HasBlobBaseFeeAbove(threshold *big.Int) func(*ExecutionPayloadEnvelope) bool {
+	return func(e *ExecutionPayloadEnvelope) bool {
+		excess, ok := e.ExcessBlobGas()
+		if !ok {
+			return false
+		}
+		return blobBaseFee(excess).Cmp(threshold) > 0
+	}
+}
+
+// blobBaseFee computes the blob base fee from the excess blob gas, using the
+// fake-exponential approximation of EIP-4844.
+func blobBaseFee(excessBlobGas uint64) *big.Int {
+	factor := big.NewInt(minBlobBaseFee)
+	numerator := new(big.Int).SetUint64(excessBlobGas)
+	denominator := big.NewInt(blobBaseFeeUpdateFraction)
+	output := new(big.Int)
+	accum := new(big.Int).Mul(factor, denominator)
+	for i := int64(1); accum.Sign() > 0; i++ {
+		output.Add(output, accum)
+		accum.Mul(accum, numerator)
+		accum.Div(accum, denominator)
+		accum.Div(accum, big.NewInt(i))
+	}
+	return output.Div(output, denominator)
+}
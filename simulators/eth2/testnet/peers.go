@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/nodeapi"
+)
+
+// GetPeerList returns the currently connected peers of a beacon node.
+func (t *Testnet) GetPeerList(ctx context.Context, nodeIndex int) ([]eth2api.Peer, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return nil, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	var peers []eth2api.Peer
+	if err := nodeapi.Peers(ctx, t.beacons[nodeIndex].API, []eth2api.ConnectionState{eth2api.ConnectionStateConnected}, nil, &peers); err != nil {
+		return nil, fmt.Errorf("failed to get peers of node %d: %w", nodeIndex, err)
+	}
+	return peers, nil
+}
+
+// peerID returns the libp2p peer identity a beacon node reports for itself.
+func (t *Testnet) peerID(ctx context.Context, nodeIndex int) (eth2api.ApiPeerId, error) {
+	var identity eth2api.NetworkIdentity
+	if err := nodeapi.Identity(ctx, t.beacons[nodeIndex].API, &identity); err != nil {
+		return "", fmt.Errorf("failed to get identity of node %d: %w", nodeIndex, err)
+	}
+	return identity.PeerID, nil
+}
+
+// nodesConnected reports, for each direction, whether one node lists the
+// other as a connected peer.
+func (t *Testnet) nodesConnected(ctx context.Context, nodeA, nodeB int) (aSeesB, bSeesA bool, err error) {
+	idA, err := t.peerID(ctx, nodeA)
+	if err != nil {
+		return false, false, err
+	}
+	idB, err := t.peerID(ctx, nodeB)
+	if err != nil {
+		return false, false, err
+	}
+	peersA, err := t.GetPeerList(ctx, nodeA)
+	if err != nil {
+		return false, false, err
+	}
+	peersB, err := t.GetPeerList(ctx, nodeB)
+	if err != nil {
+		return false, false, err
+	}
+	for _, peer := range peersA {
+		if peer.PeerID == idB {
+			aSeesB = true
+		}
+	}
+	for _, peer := range peersB {
+		if peer.PeerID == idA {
+			bSeesA = true
+		}
+	}
+	return aSeesB, bSeesA, nil
+}
+
+// meshWaitDefaultTimeout bounds WaitForNetworkMeshFormed when the caller's
+// context has no deadline of its own.
+const meshWaitDefaultTimeout = 2 * time.Minute
+
+// WaitForNetworkMeshFormed blocks until every beacon node is directly
+// connected to every other one, polling the peer lists every five seconds and
+// logging the connectivity matrix of each poll so a stuck bootstrap is
+// immediately visible. Without a deadline on the context, the wait gives up
+// after two minutes. Small testnets rely on a full mesh; anything less means
+// discovery failed, not that the topology is merely still settling.
+func (t *Testnet) WaitForNetworkMeshFormed(ctx context.Context) error {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, meshWaitDefaultTimeout)
+		defer cancel()
+	}
+	timer := time.NewTicker(5 * time.Second)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("network mesh never formed: %w", ctx.Err())
+		case <-timer.C:
+			// Build the connectivity matrix: row i marks which peers node i
+			// currently sees.
+			ids := make([]eth2api.ApiPeerId, len(t.beacons))
+			matrix := make([][]bool, len(t.beacons))
+			ok := true
+			for i := range t.beacons {
+				id, err := t.peerID(ctx, i)
+				if err != nil {
+					t.t.Logf("mesh wait: %v", err)
+					ok = false
+					break
+				}
+				ids[i] = id
+			}
+			if !ok {
+				continue
+			}
+			complete := true
+			for i := range t.beacons {
+				matrix[i] = make([]bool, len(t.beacons))
+				peers, err := t.GetPeerList(ctx, i)
+				if err != nil {
+					t.t.Logf("mesh wait: %v", err)
+					complete = false
+					break
+				}
+				connected := make(map[eth2api.ApiPeerId]bool, len(peers))
+				for _, peer := range peers {
+					connected[peer.PeerID] = true
+				}
+				for j := range t.beacons {
+					matrix[i][j] = i == j || connected[ids[j]]
+					if !matrix[i][j] {
+						complete = false
+					}
+				}
+			}
+			if matrix[len(matrix)-1] != nil {
+				t.t.Logf("connectivity matrix:\n%s", formatConnectivityMatrix(matrix))
+			}
+			if complete {
+				t.t.Logf("network mesh formed, all %d nodes mutually peered", len(t.beacons))
+				return nil
+			}
+		}
+	}
+}
+
+// formatConnectivityMatrix renders the N×N peering table for the mesh wait
+// log: row i column j is + when node i sees node j.
+func formatConnectivityMatrix(matrix [][]bool) string {
+	var b strings.Builder
+	for i, row := range matrix {
+		fmt.Fprintf(&b, "node %d:", i)
+		for _, connected := range row {
+			if connected {
+				b.WriteString(" +")
+			} else {
+				b.WriteString(" -")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// AssertNodesConnected checks that the two beacon nodes are connected to each
+// other in both directions, i.e. each lists the other's peer identity among
+// its connected peers.
+func (t *Testnet) AssertNodesConnected(ctx context.Context, nodeA, nodeB int) error {
+	if nodeA < 0 || nodeA >= len(t.beacons) || nodeB < 0 || nodeB >= len(t.beacons) {
+		return fmt.Errorf("node index out of range: %d, %d", nodeA, nodeB)
+	}
+	aSeesB, bSeesA, err := t.nodesConnected(ctx, nodeA, nodeB)
+	if err != nil {
+		return err
+	}
+	if !aSeesB || !bSeesA {
+		return fmt.Errorf("nodes %d and %d are not bidirectionally connected: %d sees %d: %v, %d sees %d: %v",
+			nodeA, nodeB, nodeA, nodeB, aSeesB, nodeB, nodeA, bSeesA)
+	}
+	t.t.Logf("nodes %d and %d are bidirectionally connected", nodeA, nodeB)
+	return nil
+}
+
+// AssertNodesNotConnected checks that neither of the two beacon nodes lists
+// the other as a connected peer, for partition verification.
+func (t *Testnet) AssertNodesNotConnected(ctx context.Context, nodeA, nodeB int) error {
+	if nodeA < 0 || nodeA >= len(t.beacons) || nodeB < 0 || nodeB >= len(t.beacons) {
+		return fmt.Errorf("node index out of range: %d, %d", nodeA, nodeB)
+	}
+	aSeesB, bSeesA, err := t.nodesConnected(ctx, nodeA, nodeB)
+	if err != nil {
+		return err
+	}
+	if aSeesB || bSeesA {
+		return fmt.Errorf("nodes %d and %d are still connected: %d sees %d: %v, %d sees %d: %v",
+			nodeA, nodeB, nodeA, nodeB, aSeesB, nodeB, nodeA, bSeesA)
+	}
+	t.t.Logf("nodes %d and %d are not connected", nodeA, nodeB)
+	return nil
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/hive/hivesim"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/view"
+
+	"github.com/ethereum/hive/simulators/eth2/testnet/utils"
+)
+
+// genesisDelay is how far in the future the genesis of a prepared testnet is
+// placed, to leave the clients time to start up.
+const genesisDelay = 30 * time.Second
+
+// Environment holds resources shared by every testnet built during one
+// simulation run, most importantly the validator key material, which is
+// expensive to derive.
+type Environment struct {
+	Validators utils.Validators
+}
+
+// PreparedTestnet is a testnet that has been configured but not started yet:
+// the genesis state is built and the validator keys are partitioned over the
+// nodes, but no clients are running.
+type PreparedTestnet struct {
+	spec *common.Spec
+
+	genesisTime           common.Timestamp
+	genesisValidatorsRoot common.Root
+
+	// genesisStatePath is the genesis state SSZ on the simulator filesystem,
+	// shared with every beacon node at startup.
+	genesisStatePath string
+
+	config *Config
+
+	// keyTranches maps each node to the validators it runs.
+	keyTranches []utils.Validators
+	// keyPaths are the exported key bundles, one per tranche.
+	keyPaths []string
+}
+
+// prepareTestnet builds the genesis state for the given config and exports
+// the artifacts that the clients consume at startup.
+func prepareTestnet(t *hivesim.T, env *Environment, config *Config) (*PreparedTestnet, error) {
+	if config.ValidatorCount == 0 || len(config.Nodes) == 0 {
+		return nil, fmt.Errorf("testnet config must have validators and nodes")
+	}
+	if uint64(len(env.Validators)) < config.ValidatorCount {
+		return nil, fmt.Errorf("environment has %d validators, config needs %d", len(env.Validators), config.ValidatorCount)
+	}
+
+	spec := *configs.Mainnet
+	if config.SlotTime != 0 {
+		spec.SECONDS_PER_SLOT = common.Timestamp(config.SlotTime)
+	}
+	spec.MIN_GENESIS_ACTIVE_VALIDATOR_COUNT = view.Uint64View(config.ValidatorCount)
+	spec.ALTAIR_FORK_EPOCH = config.AltairForkEpoch
+	spec.BELLATRIX_FORK_EPOCH = config.BellatrixForkEpoch
+	spec.CAPELLA_FORK_EPOCH = config.CapellaForkEpoch
+	spec.DENEB_FORK_EPOCH = config.DenebForkEpoch
+	spec.ELECTRA_FORK_EPOCH = config.ElectraForkEpoch
+
+	genesisTime := common.Timestamp(time.Now().Add(genesisDelay).Unix())
+
+	validators := env.Validators[:config.ValidatorCount]
+	kickstart := make([]phase0.KickstartValidatorData, len(validators))
+	for i, v := range validators {
+		kickstart[i] = phase0.KickstartValidatorData{
+			Pubkey:                v.PubKey,
+			WithdrawalCredentials: v.WithdrawalCredentials,
+			Balance:               spec.MAX_EFFECTIVE_BALANCE,
+		}
+	}
+	state, _, err := phase0.KickStartState(&spec, common.Root{}, genesisTime, kickstart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build genesis state: %v", err)
+	}
+	genesisValidatorsRoot, err := state.GenesisValidatorsRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	workDir, err := os.MkdirTemp("", "eth2-testnet")
+	if err != nil {
+		return nil, err
+	}
+	statePath := filepath.Join(workDir, "genesis.ssz")
+	var stateBytes bytes.Buffer
+	if err := state.Serialize(codec.NewEncodingWriter(&stateBytes)); err != nil {
+		return nil, fmt.Errorf("failed to serialize genesis state: %v", err)
+	}
+	if err := os.WriteFile(statePath, stateBytes.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+
+	// Split the validators evenly over the nodes running their own keys;
+	// nodes duplicating another node get a copy of that node's tranche.
+	primaries := 0
+	primaryIndex := make([]int, len(config.Nodes))
+	for i, node := range config.Nodes {
+		if node.DuplicateKeysOfNode == nil {
+			primaryIndex[i] = primaries
+			primaries++
+		}
+	}
+	if primaries == 0 {
+		return nil, fmt.Errorf("every node duplicates another node's keys, no primary key owners")
+	}
+	tranches := make([]utils.Validators, len(config.Nodes))
+	for i, node := range config.Nodes {
+		if node.DuplicateKeysOfNode == nil {
+			tranches[i] = keyTranche(validators, primaryIndex[i], primaries)
+		}
+	}
+	for i, node := range config.Nodes {
+		if node.DuplicateKeysOfNode == nil {
+			continue
+		}
+		src := *node.DuplicateKeysOfNode
+		if src < 0 || src >= len(config.Nodes) || src == i {
+			return nil, fmt.Errorf("node %d duplicates keys of invalid node %d", i, src)
+		}
+		if config.Nodes[src].DuplicateKeysOfNode != nil {
+			return nil, fmt.Errorf("node %d duplicates keys of node %d, which is itself a duplicate", i, src)
+		}
+		tranches[i] = tranches[src]
+	}
+	keyPaths := make([]string, len(config.Nodes))
+	for i := range config.Nodes {
+		path := filepath.Join(workDir, fmt.Sprintf("keys-%d.json", i))
+		if err := exportKeyBundle(path, tranches[i]); err != nil {
+			return nil, err
+		}
+		keyPaths[i] = path
+	}
+
+	t.Logf("prepared testnet: %d validators, %d nodes, genesis at %v",
+		config.ValidatorCount, len(config.Nodes), genesisTime)
+
+	return &PreparedTestnet{
+		spec:                  &spec,
+		genesisTime:           genesisTime,
+		genesisValidatorsRoot: genesisValidatorsRoot,
+		genesisStatePath:      statePath,
+		config:                config,
+		keyTranches:           tranches,
+		keyPaths:              keyPaths,
+	}, nil
+}
+
+// keyTranche returns the i-th of n even slices of the validator set.
+func keyTranche(vs utils.Validators, i, n int) utils.Validators {
+	return vs[uint64(i)*uint64(len(vs))/uint64(n) : uint64(i+1)*uint64(len(vs))/uint64(n)]
+}
+
+// exportKeyBundle writes the secret keys of a tranche as a JSON object of
+// pubkey -> secret key hex, the format consumed by the client wrappers.
+func exportKeyBundle(path string, tranche utils.Validators) error {
+	bundle := make(map[string]string, len(tranche))
+	for _, v := range tranche {
+		bundle[v.PubKey.String()] = "0x" + hex.EncodeToString(v.SecretKeyBytes[:])
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// exportPubkeyList writes the public keys of a tranche as a JSON array, for
+// validator clients that operate against a remote signer and must not see
+// secret key material.
+func exportPubkeyList(path string, tranche utils.Validators) error {
+	pubkeys := make([]string, len(tranche))
+	for i, v := range tranche {
+		pubkeys[i] = v.PubKey.String()
+	}
+	data, err := json.MarshalIndent(pubkeys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// startTestnet launches the clients of every node and assembles the running
+// Testnet.
+func (p *PreparedTestnet) startTestnet(t *hivesim.T) *Testnet {
+	testnet := &Testnet{
+		t:                     t,
+		genesisTime:           p.genesisTime,
+		genesisValidatorsRoot: p.genesisValidatorsRoot,
+		spec:                  p.spec,
+		ValidatorGroups:       make(map[string]*utils.Validators),
+		duplicateNodes:        make(map[int]int),
+		lazyBeaconState:       p.config.LazyBeaconState,
+		logEngineLatency:      p.config.LogEngineLatency,
+		blobberErrorsFatal:    p.config.BlobberErrorsFatal,
+	}
+	for i, tranche := range p.keyTranches {
+		if p.config.Nodes[i].DuplicateKeysOfNode != nil {
+			// Duplicated tranches are already part of the set; just record
+			// the intentional overlap.
+			testnet.duplicateNodes[i] = *p.config.Nodes[i].DuplicateKeysOfNode
+			continue
+		}
+		testnet.Validators = append(testnet.Validators, tranche...)
+	}
+
+	// Each node trusts its own blobber peer identity, so cycling one node's
+	// peer does not disturb the others. The count is demand-driven: one per
+	// targeted node unless the config asks for more.
+	nodeTrustedPeers := make(map[int][]string)
+	if p.config.EnableBlobber {
+		// Hand the blobber only the configured key range, so tests can model
+		// a partially malicious proposer set instead of an all-bad one.
+		blobberKeys := testnet.Validators
+		if r := p.config.BlobberValidatorRange; r != nil {
+			blobberKeys = nil
+			for _, v := range testnet.Validators {
+				if v.Index >= r.Start && v.Index < r.End {
+					blobberKeys = append(blobberKeys, v)
+				}
+			}
+			if len(blobberKeys) == 0 {
+				t.Fatalf("blobber validator range [%d, %d) matches no validators", r.Start, r.End)
+			}
+		}
+		blobber := NewBlobber(blobberKeys)
+		count := p.config.BlobberTrustedPeerCount
+		if count == 0 {
+			count = len(p.config.Nodes)
+		}
+		if count < len(p.config.Nodes) {
+			t.Fatalf("blobber trusted peer count %d is less than the %d nodes", count, len(p.config.Nodes))
+		}
+		peerIDs := blobber.GetNextPeerIDs(count)
+		if len(peerIDs) != count {
+			t.Fatalf("blobber produced %d peer IDs, requested %d", len(peerIDs), count)
+		}
+		for i := range p.config.Nodes {
+			nodeTrustedPeers[i] = append(nodeTrustedPeers[i], peerIDs[i])
+		}
+		testnet.blobbers = append(testnet.blobbers, blobber)
+		testnet.markExpectedBadProposers(blobberKeys)
+		if len(p.config.BlobberActionSchedule) > 0 {
+			go testnet.runBlobberSchedule(context.Background(), p.config.BlobberActionSchedule)
+		}
+	} else if len(p.config.BlobberActionSchedule) > 0 {
+		t.Fatalf("blobber action schedule configured without EnableBlobber")
+	}
+	for b, def := range p.config.Blobbers {
+		if len(def.Nodes) == 0 {
+			t.Fatalf("blobber %d targets no nodes", b)
+		}
+		// Each extra blobber operates only the keys of the nodes it targets.
+		var keys utils.Validators
+		for _, nodeIndex := range def.Nodes {
+			if nodeIndex < 0 || nodeIndex >= len(p.config.Nodes) {
+				t.Fatalf("blobber %d targets invalid node %d", b, nodeIndex)
+			}
+			keys = append(keys, p.keyTranches[nodeIndex]...)
+		}
+		blobber := NewBlobber(keys)
+		count := def.TrustedPeerCount
+		if count == 0 {
+			count = len(def.Nodes)
+		}
+		if count < len(def.Nodes) {
+			t.Fatalf("blobber %d trusted peer count %d is less than its %d nodes", b, count, len(def.Nodes))
+		}
+		peerIDs := blobber.GetNextPeerIDs(count)
+		if len(peerIDs) != count {
+			t.Fatalf("blobber %d produced %d peer IDs, requested %d", b, len(peerIDs), count)
+		}
+		for j, nodeIndex := range def.Nodes {
+			nodeTrustedPeers[nodeIndex] = append(nodeTrustedPeers[nodeIndex], peerIDs[j])
+		}
+		testnet.blobbers = append(testnet.blobbers, blobber)
+		testnet.markExpectedBadProposers(keys)
+	}
+
+	beaconParams := hivesim.Params{
+		"HIVE_ETH2_BN_API_PORT":  fmt.Sprintf("%d", PortBeaconAPI),
+		"HIVE_ETH2_GENESIS_TIME": fmt.Sprintf("%d", p.genesisTime),
+	}
+	testnet.eth1 = make([]*Eth1Node, len(p.config.Nodes))
+	testnet.beacons = make([]*BeaconNode, len(p.config.Nodes))
+	testnet.validators = make([]*ValidatorClient, len(p.config.Nodes))
+	signers := make([]*RemoteSigner, len(p.config.Nodes))
+	builders := make([]*MockBuilder, len(p.config.Nodes))
+	elProxies := make([]*ExecutionRPCProxy, len(p.config.Nodes))
+
+	// startNode brings up the full client bundle of one node. Clients within
+	// a bundle start in order, since each needs the address of the previous
+	// one, but bundles are independent of each other.
+	startNode := func(i int, node NodeDefinition) {
+		jwtSecret := node.JWTSecret
+		if jwtSecret == "" {
+			jwtSecret = defaultJWTSecret
+		}
+		eth1Params := hivesim.Params{"HIVE_JWTSECRET": jwtSecret}
+		eth1 := &Eth1Node{Client: t.StartClient(node.ExecutionClient, eth1Params, nil), params: eth1Params}
+		testnet.eth1[i] = eth1
+
+		eth1Addr := fmt.Sprintf("http://%v:%d", eth1.IP, PortEth1RPC)
+		// A tracking or mutating node talks to its execution client through
+		// a recording proxy; the global flags enable it for every node.
+		if node.TrackForkchoiceUpdated || p.config.TrackForkchoiceUpdated ||
+			len(node.EngineResponseMutators) > 0 || len(p.config.EngineResponseMutators) > 0 {
+			elProxy, err := newExecutionRPCProxy(testnet, i, eth1Addr)
+			if err != nil {
+				t.Fatalf("failed to start execution RPC proxy of node %d: %v", i, err)
+			}
+			for method, mutator := range p.config.EngineResponseMutators {
+				elProxy.WithResponseMutator(method, mutator)
+			}
+			for method, mutator := range node.EngineResponseMutators {
+				elProxy.WithResponseMutator(method, mutator)
+			}
+			elProxies[i] = elProxy
+			eth1Addr = elProxy.Endpoint()
+		}
+		bnParams := beaconParams.Set("HIVE_ETH2_ETH1_RPC_ADDRS", eth1Addr)
+		if peers := nodeTrustedPeers[i]; len(peers) > 0 {
+			bnParams = bnParams.Set("HIVE_ETH2_TRUSTED_PEER_IDS", strings.Join(peers, ","))
+		}
+		// A node gets a builder when it asks for one; the global flag is the
+		// default for nodes that leave it unset.
+		if node.EnableBuilder || p.config.EnableBuilders {
+			var options BuilderOptions
+			if node.BuilderOptions != nil {
+				options = *node.BuilderOptions
+			}
+			builder, err := newMockBuilder(testnet, i, options)
+			if err != nil {
+				t.Fatalf("failed to start mock builder of node %d: %v", i, err)
+			}
+			builder.scenario = p.config.BuilderScenario
+			builders[i] = builder
+			bnParams = bnParams.Set("HIVE_ETH2_BUILDER_ENDPOINT", builder.Endpoint())
+		}
+		bnFiles := map[string]string{
+			"/hive/input/genesis.ssz": p.genesisStatePath,
+		}
+		beacon := NewBeaconNode(t.StartClient(node.BeaconNode, bnParams, bnFiles))
+		beacon.params = bnParams
+		beacon.files = bnFiles
+		testnet.beacons[i] = beacon
+
+		vcParams := hivesim.Params{
+			"HIVE_ETH2_BN_API_ADDRS": fmt.Sprintf("http://%v:%d", beacon.IP, PortBeaconAPI),
+		}
+		if node.Graffiti != "" {
+			vcParams = vcParams.Set("HIVE_ETH2_GRAFFITI", node.Graffiti)
+		}
+		vcFiles := map[string]string{
+			"/hive/input/keys.json": p.keyPaths[i],
+		}
+		if node.UseRemoteSigner {
+			signerType := node.RemoteSigner
+			if signerType == "" {
+				signerType = defaultRemoteSignerClient
+			}
+			signerParams := hivesim.Params{
+				"HIVE_ETH2_SIGNER_API_PORT": fmt.Sprintf("%d", PortRemoteSigner),
+			}
+			signer := &RemoteSigner{t.StartClient(signerType, signerParams, map[string]string{
+				"/hive/input/keys.json": p.keyPaths[i],
+			})}
+			signers[i] = signer
+			// The validator client gets the signer URL and the public keys
+			// to operate, but no secret key material.
+			vcParams = vcParams.Set("HIVE_ETH2_REMOTE_SIGNER_URL", signer.SigningURL())
+			delete(vcFiles, "/hive/input/keys.json")
+			pubkeyPath := p.keyPaths[i] + ".pub"
+			if err := exportPubkeyList(pubkeyPath, p.keyTranches[i]); err != nil {
+				t.Fatalf("failed to export pubkeys of node %d: %v", i, err)
+			}
+			vcFiles["/hive/input/pubkeys.json"] = pubkeyPath
+		}
+		vc := t.StartClient(node.ValidatorClient, vcParams, vcFiles)
+		testnet.validators[i] = &ValidatorClient{
+			Client:   vc,
+			Keys:     p.keyTranches[i],
+			params:   vcParams,
+			graffiti: node.Graffiti,
+		}
+	}
+
+	// Bundles start concurrently up to the configured limit; a limit of one
+	// gives the old sequential behavior.
+	startBundles(len(p.config.Nodes), p.config.MaxConcurrentStartups, func(i int) {
+		startNode(i, p.config.Nodes[i])
+	})
+	for i := range p.config.Nodes {
+		if testnet.eth1[i] == nil || testnet.beacons[i] == nil || testnet.validators[i] == nil {
+			t.Fatalf("node %d did not start", i)
+		}
+	}
+	for i, signer := range signers {
+		if signer == nil {
+			continue
+		}
+		if testnet.signers == nil {
+			testnet.signers = make(map[int]*RemoteSigner)
+		}
+		testnet.signers[i] = signer
+	}
+	for i, builder := range builders {
+		if builder == nil {
+			continue
+		}
+		if testnet.mockBuilders == nil {
+			testnet.mockBuilders = make(map[int]*MockBuilder)
+		}
+		testnet.mockBuilders[i] = builder
+	}
+	for i, elProxy := range elProxies {
+		if elProxy == nil {
+			continue
+		}
+		if testnet.executionProxies == nil {
+			testnet.executionProxies = make(map[int]*ExecutionRPCProxy)
+		}
+		testnet.executionProxies[i] = elProxy
+	}
+	if err := testnet.ValidatePartition(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(p.config.RequiredEngineCapabilities) > 0 {
+		if err := testnet.VerifyEngineAPIVersions(context.Background(), p.config.RequiredEngineCapabilities); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	return testnet
+}
+
+// startBundles runs start for each of n node bundles, at most concurrency at
+// a time. Zero or negative concurrency means no limit; one runs the bundles
+// strictly sequentially.
+func startBundles(n, concurrency int, start func(int)) {
+	if concurrency <= 0 || concurrency > n {
+		concurrency = n
+	}
+	if concurrency == 1 {
+		for i := 0; i < n; i++ {
+			start(i)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			start(i)
+		}()
+	}
+	wg.Wait()
+}
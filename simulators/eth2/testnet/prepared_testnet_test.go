@@ -0,0 +1,67 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestConcurrentStartupTime starts 8 fake node bundles with a fixed per-node
+// startup delay and checks that unlimited concurrency finishes in well under
+// twice the single-node startup time, i.e. the bundles genuinely overlap.
+func TestConcurrentStartupTime(t *testing.T) {
+	const nodes = 8
+	const singleNodeStartup = 50 * time.Millisecond
+
+	var started int32
+	begin := time.Now()
+	startBundles(nodes, 0, func(int) {
+		atomic.AddInt32(&started, 1)
+		time.Sleep(singleNodeStartup)
+	})
+	elapsed := time.Since(begin)
+
+	if started != nodes {
+		t.Fatalf("started %d bundles, want %d", started, nodes)
+	}
+	if elapsed >= 2*singleNodeStartup {
+		t.Fatalf("starting %d bundles took %v, want under %v", nodes, elapsed, 2*singleNodeStartup)
+	}
+}
+
+// TestStartupConcurrencyLimit checks that the configured limit bounds how
+// many bundles are in flight at once, and that a limit of one runs them
+// strictly sequentially in order.
+func TestStartupConcurrencyLimit(t *testing.T) {
+	const nodes = 8
+	const limit = 3
+
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	startBundles(nodes, limit, func(int) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	})
+	if maxInFlight > limit {
+		t.Fatalf("%d bundles in flight at once, limit is %d", maxInFlight, limit)
+	}
+
+	var order []int
+	startBundles(nodes, 1, func(i int) {
+		order = append(order, i)
+	})
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("sequential startup ran bundle %d at position %d", got, i)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/eth2api/client/validatorapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// ErrNotScheduledToPropose is returned by WaitForBlockProductionByValidator
+// when the validator has no proposer duty in the current or next epoch, so a
+// caller can extend its search window instead of treating it as a failure.
+var ErrNotScheduledToPropose = errors.New("validator not scheduled to propose in the current or next epoch")
+
+// proposerSlotsOf returns the slots assigned to one validator by the
+// proposer duties of the given epoch.
+func (t *Testnet) proposerSlotsOf(ctx context.Context, epoch common.Epoch, validatorIndex common.ValidatorIndex) ([]common.Slot, error) {
+	var duties eth2api.DependentProposerDuty
+	if _, err := validatorapi.ProposerDuties(ctx, t.roundRobinBeacon().API, epoch, &duties); err != nil {
+		return nil, fmt.Errorf("failed to fetch proposer duties of epoch %d: %w", epoch, err)
+	}
+	var slots []common.Slot
+	for _, duty := range duties.Data {
+		if duty.ValidatorIndex == validatorIndex {
+			slots = append(slots, duty.Slot)
+		}
+	}
+	return slots, nil
+}
+
+// WaitForBlockProductionByValidator waits until the given validator proposes
+// a block: it looks up the validator's proposer duties in the current and
+// next epoch and polls the assigned slots until one of them carries a block
+// with the right proposer index. It returns the slot and root of the
+// proposed block. When the validator has no duty in either epoch it fails
+// immediately with ErrNotScheduledToPropose, so a test can decide to wait an
+// epoch and retry.
+func (t *Testnet) WaitForBlockProductionByValidator(ctx context.Context, validatorIndex uint64) (common.Slot, tree.Root, error) {
+	index := common.ValidatorIndex(validatorIndex)
+	currentEpoch := t.CurrentEpoch()
+	var slots []common.Slot
+	for _, epoch := range []common.Epoch{currentEpoch, currentEpoch + 1} {
+		epochSlots, err := t.proposerSlotsOf(ctx, epoch, index)
+		if err != nil {
+			return 0, tree.Root{}, err
+		}
+		for _, slot := range epochSlots {
+			// Past slots cannot produce a block anymore; the including block
+			// would already exist, so they still get one check below.
+			slots = append(slots, slot)
+		}
+	}
+	if len(slots) == 0 {
+		return 0, tree.Root{}, fmt.Errorf("validator %d: %w", validatorIndex, ErrNotScheduledToPropose)
+	}
+	t.t.Logf("validator %d is scheduled to propose at slots %v", validatorIndex, slots)
+
+	bn := t.roundRobinBeacon()
+	lastSlot := slots[len(slots)-1]
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, tree.Root{}, ctx.Err()
+		case <-timer.C:
+			currentSlot := t.CurrentSlot()
+			for _, slot := range slots {
+				if slot > currentSlot {
+					break
+				}
+				block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+				if err != nil {
+					t.t.Logf("failed to poll block of slot %d: %v", slot, err)
+					continue
+				}
+				if !exists || block.ProposerIndex() != index {
+					continue
+				}
+				root, _, err := beaconapi.BlockRoot(ctx, bn.API, eth2api.BlockIdSlot(slot))
+				if err != nil {
+					return 0, tree.Root{}, fmt.Errorf("failed to get root of block at slot %d: %w", slot, err)
+				}
+				t.t.Logf("validator %d proposed block %s at slot %d", validatorIndex, root, slot)
+				return slot, tree.Root(root), nil
+			}
+			if currentSlot > lastSlot {
+				return 0, tree.Root{}, fmt.Errorf("validator %d proposed no block in its scheduled slots %v", validatorIndex, slots)
+			}
+		}
+	}
+}
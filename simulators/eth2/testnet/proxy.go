@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// GossipTopicBlock is the gossip topic of beacon block propagation. The
+// proxy intercepts it at the validator client's block publish call, which is
+// where a proposed block enters the network.
+const GossipTopicBlock = "beacon_block"
+
+// blockPublishPath is the Beacon API endpoint that carries the
+// GossipTopicBlock topic through the proxy.
+const blockPublishPath = "/eth/v1/beacon/blocks"
+
+// BeaconAPIProxy is a reverse proxy between a validator client and its
+// beacon node. It forwards all Beacon API traffic unmodified, except where
+// interception rules are installed, and is the hook point for delaying or
+// mutating what a node publishes to the network.
+type BeaconAPIProxy struct {
+	t         *Testnet
+	nodeIndex int
+	backend   *httputil.ReverseProxy
+	listener  net.Listener
+
+	mu sync.Mutex
+	// blockDelays holds the per-slot delivery delays of intercepted block
+	// publications.
+	blockDelays map[common.Slot]time.Duration
+}
+
+// blockProxy returns the beacon API proxy of the given node, creating it and
+// re-pointing the node's validator client at it on first use.
+func (t *Testnet) blockProxy(nodeIndex int) (*BeaconAPIProxy, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.validators) {
+		return nil, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	if t.proxies == nil {
+		t.proxies = make(map[int]*BeaconAPIProxy)
+	}
+	if p, ok := t.proxies[nodeIndex]; ok {
+		return p, nil
+	}
+
+	backendURL, err := url.Parse(t.beacons[nodeIndex].API.Addr)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:0", simulatorIP()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for proxy: %w", err)
+	}
+	p := &BeaconAPIProxy{
+		t:           t,
+		nodeIndex:   nodeIndex,
+		backend:     httputil.NewSingleHostReverseProxy(backendURL),
+		listener:    listener,
+		blockDelays: make(map[common.Slot]time.Duration),
+	}
+	go http.Serve(listener, p)
+
+	// Restart the validator client so it publishes through the proxy.
+	workDir, err := os.MkdirTemp("", "eth2-testnet-proxy")
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	vc := t.validators[nodeIndex]
+	params := vc.params.Set("HIVE_ETH2_BN_API_ADDRS", fmt.Sprintf("http://%s", listener.Addr()))
+	newVC, err := t.restartValidatorClient(&ValidatorClient{Client: vc.Client, Keys: vc.Keys, params: params, graffiti: vc.graffiti},
+		filepath.Join(workDir, "keys.json"), vc.Keys)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to route validator client %d through proxy: %w", nodeIndex, err)
+	}
+	t.validators[nodeIndex] = newVC
+	t.proxies[nodeIndex] = p
+	t.t.Logf("proxying beacon API of node %d via %s", nodeIndex, listener.Addr())
+	return p, nil
+}
+
+// simulatorIP returns the address of the simulator container on the network
+// shared with the clients, which is where proxies must listen to be
+// reachable.
+func simulatorIP() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "0.0.0.0"
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return ipNet.IP.String()
+		}
+	}
+	return "0.0.0.0"
+}
+
+// InjectDelayedBlock installs a proxy rule on the given node that holds back
+// the block of the given slot for delay before letting it reach the network,
+// to test proposer boost and late-block fork-choice behavior. The block is
+// acknowledged to the proposer immediately and redelivered unmodified after
+// the delay; the content hash is checked across the delay to rule out
+// accidental mutation.
+func (t *Testnet) InjectDelayedBlock(nodeIndex int, slot common.Slot, delay time.Duration) error {
+	p, err := t.blockProxy(nodeIndex)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.blockDelays[slot] = delay
+	p.mu.Unlock()
+	t.t.Logf("node %d will delay its block at slot %d by %v", nodeIndex, slot, delay)
+	return nil
+}
+
+// ServeHTTP forwards beacon API calls to the node, intercepting block
+// publications that have a delay rule installed.
+func (p *BeaconAPIProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" && r.URL.Path == blockPublishPath {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		slot := publishedBlockSlot(body)
+		p.mu.Lock()
+		delay, delayed := p.blockDelays[slot]
+		if delayed {
+			delete(p.blockDelays, slot)
+		}
+		p.mu.Unlock()
+		if delayed {
+			p.deliverDelayed(r, body, slot, delay)
+			// The proposer sees a successful publish right away.
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+	p.backend.ServeHTTP(w, r)
+}
+
+// deliverDelayed redelivers a captured block publication to the beacon node
+// after the delay, verifying that the held-back content did not change.
+func (p *BeaconAPIProxy) deliverDelayed(r *http.Request, body []byte, slot common.Slot, delay time.Duration) {
+	checksum := sha256.Sum256(body)
+	header := r.Header.Clone()
+	go func() {
+		time.Sleep(delay)
+		if sha256.Sum256(body) != checksum {
+			p.t.t.Errorf("delayed block at slot %d mutated while held back", slot)
+			return
+		}
+		req, err := http.NewRequest("POST", p.t.beacons[p.nodeIndex].API.Addr+blockPublishPath, bytes.NewReader(body))
+		if err != nil {
+			p.t.t.Errorf("failed to redeliver delayed block at slot %d: %v", slot, err)
+			return
+		}
+		req.Header = header
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			p.t.t.Errorf("failed to redeliver delayed block at slot %d: %v", slot, err)
+			return
+		}
+		resp.Body.Close()
+		p.t.t.Logf("redelivered block at slot %d after %v delay (status %d)", slot, delay, resp.StatusCode)
+	}()
+}
+
+// publishedBlockSlot extracts the slot of a published signed block body.
+func publishedBlockSlot(body []byte) common.Slot {
+	var block struct {
+		Message struct {
+			Slot common.Slot `json:"slot"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(body, &block); err != nil {
+		return 0
+	}
+	return block.Message.Slot
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/nodeapi"
+	"github.com/protolambda/eth2api/client/validatorapi"
+)
+
+// WaitForNodeReady blocks until the full client stack of a node answers its
+// API: the execution client serves eth_blockNumber, the beacon node reports
+// healthy, and the proposer duties of the current epoch resolve, which is the
+// first call a validator client depends on. It polls once per second, since
+// startup readiness is finer-grained than slots, and returns once all three
+// checks pass or the context expires.
+func (t *Testnet) WaitForNodeReady(ctx context.Context, nodeIndex int) error {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	eth1 := t.eth1[nodeIndex]
+	bn := t.beacons[nodeIndex]
+
+	eth1Ready, beaconReady, dutiesReady := false, false, false
+	timer := time.NewTicker(time.Second)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("node %d not ready: eth1 %v, beacon %v, duties %v: %w",
+				nodeIndex, eth1Ready, beaconReady, dutiesReady, ctx.Err())
+		case <-timer.C:
+			if !eth1Ready {
+				var blockNumber string
+				if err := eth1.RPC().CallContext(ctx, &blockNumber, "eth_blockNumber"); err == nil {
+					eth1Ready = true
+				}
+			}
+			if !beaconReady {
+				if _, err := nodeapi.Health(ctx, bn.API); err == nil {
+					beaconReady = true
+				}
+			}
+			if !dutiesReady {
+				var duties eth2api.DependentProposerDuty
+				if _, err := validatorapi.ProposerDuties(ctx, bn.API, t.CurrentEpoch(), &duties); err == nil {
+					dutiesReady = true
+				}
+			}
+			if eth1Ready && beaconReady && dutiesReady {
+				return nil
+			}
+		}
+	}
+}
+
+// WaitForAllNodesReady runs WaitForNodeReady for every node of the testnet.
+func (t *Testnet) WaitForAllNodesReady(ctx context.Context) error {
+	for i := range t.beacons {
+		if err := t.WaitForNodeReady(ctx, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/eth2api/client/nodeapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// StopRemoteSigner stops the remote signer of the given node mid-test, as a
+// fault-injection hook: the validator client loses its signing backend while
+// everything else keeps running. The node must have been configured with
+// UseRemoteSigner.
+func (t *Testnet) StopRemoteSigner(nodeIndex int) error {
+	signer, ok := t.signers[nodeIndex]
+	if !ok {
+		return fmt.Errorf("node %d has no remote signer", nodeIndex)
+	}
+	if err := t.t.Sim.StopClient(t.t.SuiteID, t.t.TestID, signer.Container); err != nil {
+		return fmt.Errorf("failed to stop remote signer of node %d: %w", nodeIndex, err)
+	}
+	t.t.Logf("stopped remote signer of node %d", nodeIndex)
+	return nil
+}
+
+// AssertSafeWithSignerDown observes the given number of slots after the
+// node's remote signer was stopped and checks that the validator client
+// degrades safely: its beacon node must stay reachable (the client stack did
+// not crash), its validators must not produce any block in the window (there
+// is nothing left to sign with), and none of them may end up slashed, which
+// would indicate the client signed something slashable from a stale cache.
+func (t *Testnet) AssertSafeWithSignerDown(ctx context.Context, nodeIndex int, slots common.Slot) error {
+	if _, ok := t.signers[nodeIndex]; !ok {
+		return fmt.Errorf("node %d has no remote signer", nodeIndex)
+	}
+	nodeValidators := make(map[common.ValidatorIndex]bool)
+	for _, v := range t.validators[nodeIndex].Keys {
+		nodeValidators[v.Index] = true
+	}
+
+	startSlot := t.CurrentSlot()
+	endSlot := startSlot + slots
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for t.CurrentSlot() <= endSlot {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		// The node's own beacon node staying responsive shows the client
+		// stack survived losing its signer.
+		var status eth2api.SyncingStatus
+		if err := nodeapi.SyncingStatus(ctx, t.beacons[nodeIndex].API, &status); err != nil {
+			return fmt.Errorf("node %d became unresponsive with its signer down: %w", nodeIndex, err)
+		}
+	}
+
+	bn := t.roundRobinBeacon()
+	for slot := startSlot; slot <= endSlot; slot++ {
+		block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+		if err != nil {
+			return err
+		}
+		if exists && nodeValidators[block.ProposerIndex()] {
+			return fmt.Errorf("node %d proposed a block at slot %d with its signer down", nodeIndex, slot)
+		}
+	}
+
+	var all []eth2api.ValidatorResponse
+	if exists, err := beaconapi.StateValidators(ctx, bn.API, eth2api.StateHead, nil, nil, &all); err != nil {
+		return fmt.Errorf("failed to check for slashings: %w", err)
+	} else if !exists {
+		return fmt.Errorf("no head state to check for slashings")
+	}
+	for _, v := range all {
+		if nodeValidators[v.Index] && v.Validator.Slashed {
+			return fmt.Errorf("validator %d of node %d was slashed with its signer down", v.Index, nodeIndex)
+		}
+	}
+	t.t.Logf("node %d degraded safely over %d slots with its signer down", nodeIndex, slots)
+	return nil
+}
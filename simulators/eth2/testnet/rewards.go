@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// SignedGwei is a reward or penalty amount in Gwei. Like the unsigned
+// quantities of the Beacon API, it is JSON-encoded as a decimal string.
+type SignedGwei int64
+
+func (g *SignedGwei) UnmarshalJSON(b []byte) error {
+	v, err := strconv.ParseInt(strings.Trim(string(b), `"`), 10, 64)
+	if err != nil {
+		return err
+	}
+	*g = SignedGwei(v)
+	return nil
+}
+
+func (g SignedGwei) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + strconv.FormatInt(int64(g), 10) + `"`), nil
+}
+
+// AttestationReward is the per-validator entry of the attestation rewards
+// endpoint.
+type AttestationReward struct {
+	ValidatorIndex common.ValidatorIndex `json:"validator_index"`
+	Head           SignedGwei            `json:"head"`
+	Target         SignedGwei            `json:"target"`
+	Source         SignedGwei            `json:"source"`
+	InclusionDelay SignedGwei            `json:"inclusion_delay,omitempty"`
+	Inactivity     SignedGwei            `json:"inactivity,omitempty"`
+}
+
+// Total returns the sum of all reward components.
+func (r *AttestationReward) Total() SignedGwei {
+	return r.Head + r.Target + r.Source + r.InclusionDelay + r.Inactivity
+}
+
+// attestationRewardsResponse is the data of the attestation rewards endpoint.
+type attestationRewardsResponse struct {
+	TotalRewards []AttestationReward `json:"total_rewards"`
+}
+
+// attestationRewards queries a beacon node for the attestation rewards that
+// the given validators earned in the given epoch. The rewards of an epoch
+// only become available once it is completed.
+func attestationRewards(ctx context.Context, bn *BeaconNode, epoch common.Epoch, indices []common.ValidatorIndex) ([]AttestationReward, error) {
+	ids := make([]string, len(indices))
+	for i, index := range indices {
+		ids[i] = index.String()
+	}
+	var resp attestationRewardsResponse
+	req := eth2api.BodyPOST(fmt.Sprintf("/eth/v1/beacon/rewards/attestations/%d", epoch), ids)
+	if err := eth2api.MinimalRequest(ctx, bn.API, req, eth2api.Wrap(&resp)); err != nil {
+		return nil, fmt.Errorf("failed to get attestation rewards of epoch %d: %w", epoch, err)
+	}
+	return resp.TotalRewards, nil
+}
+
+// blockRewards queries a beacon node for the total proposer reward of one
+// block. It returns exists=false when the slot has no block.
+func blockRewards(ctx context.Context, bn *BeaconNode, blockId eth2api.BlockId) (SignedGwei, bool, error) {
+	var resp struct {
+		ProposerIndex common.ValidatorIndex `json:"proposer_index"`
+		Total         SignedGwei            `json:"total"`
+	}
+	req := eth2api.FmtGET("/eth/v1/beacon/rewards/blocks/%s", blockId.BlockId())
+	code, err := bn.API.Request(ctx, req).Decode(eth2api.Wrap(&resp))
+	if code == 404 {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get block rewards of %s: %w", blockId.BlockId(), err)
+	}
+	return resp.Total, true, nil
+}
+
+// syncCommitteeReward is the per-validator entry of the sync committee
+// rewards endpoint.
+type syncCommitteeReward struct {
+	ValidatorIndex common.ValidatorIndex `json:"validator_index"`
+	Reward         SignedGwei            `json:"reward"`
+}
+
+// syncCommitteeRewards queries a beacon node for the sync committee rewards
+// paid out by one block. It returns exists=false when the slot has no block.
+func syncCommitteeRewards(ctx context.Context, bn *BeaconNode, blockId eth2api.BlockId) ([]syncCommitteeReward, bool, error) {
+	var rewards []syncCommitteeReward
+	req := eth2api.BodyPOST(fmt.Sprintf("/eth/v1/beacon/rewards/sync_committee/%s", blockId.BlockId()), []string{})
+	code, err := bn.API.Request(ctx, req).Decode(eth2api.Wrap(&rewards))
+	if code == 404 {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get sync committee rewards of %s: %w", blockId.BlockId(), err)
+	}
+	return rewards, true, nil
+}
+
+// RewardsSummary aggregates the rewards a completed epoch issued, in Gwei.
+type RewardsSummary struct {
+	AttestationRewards   SignedGwei
+	ProposalRewards      SignedGwei
+	SyncCommitteeRewards SignedGwei
+	TotalRewards         SignedGwei
+}
+
+// GetEpochRewardsSummary sums the rewards the given completed epoch issued,
+// as seen by one node: the attestation rewards of the full validator set plus
+// the proposer and sync committee rewards of every block in the epoch. Missed
+// slots simply contribute nothing.
+func (t *Testnet) GetEpochRewardsSummary(ctx context.Context, nodeIndex int, epoch common.Epoch) (*RewardsSummary, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return nil, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	bn := t.beacons[nodeIndex]
+
+	var summary RewardsSummary
+	attRewards, err := attestationRewards(ctx, bn, epoch, t.Validators.Indices())
+	if err != nil {
+		return nil, err
+	}
+	for i := range attRewards {
+		summary.AttestationRewards += attRewards[i].Total()
+	}
+
+	start, err := t.spec.EpochStartSlot(epoch)
+	if err != nil {
+		return nil, err
+	}
+	for slot := start; slot < start+common.Slot(t.spec.SLOTS_PER_EPOCH); slot++ {
+		blockId := eth2api.BlockIdSlot(slot)
+		proposal, exists, err := blockRewards(ctx, bn, blockId)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		summary.ProposalRewards += proposal
+		syncRewards, exists, err := syncCommitteeRewards(ctx, bn, blockId)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			for i := range syncRewards {
+				summary.SyncCommitteeRewards += syncRewards[i].Reward
+			}
+		}
+	}
+	summary.TotalRewards = summary.AttestationRewards + summary.ProposalRewards + summary.SyncCommitteeRewards
+	return &summary, nil
+}
+
+// AssertEpochRewardsWithinBounds checks that the total issuance of the given
+// completed epoch falls within [minGwei, maxGwei].
+func (t *Testnet) AssertEpochRewardsWithinBounds(ctx context.Context, epoch common.Epoch, minGwei, maxGwei uint64) error {
+	summary, err := t.GetEpochRewardsSummary(ctx, 0, epoch)
+	if err != nil {
+		return err
+	}
+	if summary.TotalRewards < SignedGwei(minGwei) || summary.TotalRewards > SignedGwei(maxGwei) {
+		return fmt.Errorf("epoch %d issued %d Gwei (%d attestation, %d proposal, %d sync committee), want within [%d, %d]",
+			epoch, summary.TotalRewards, summary.AttestationRewards, summary.ProposalRewards,
+			summary.SyncCommitteeRewards, minGwei, maxGwei)
+	}
+	t.t.Logf("epoch %d issued %d Gwei, within bounds [%d, %d]", epoch, summary.TotalRewards, minGwei, maxGwei)
+	return nil
+}
+
+// rewardsWaitEpochLimit is how many completed epochs WaitForEpochRewardsAbove
+// inspects before giving up.
+const rewardsWaitEpochLimit = 8
+
+// WaitForEpochRewardsAbove polls the attestation rewards after each epoch
+// boundary and returns the first completed epoch in which the average head
+// reward of the validator set exceeds minRewardGwei. Epochs whose rewards are
+// not yet available are retried at the next boundary. If the threshold is not
+// met within rewardsWaitEpochLimit completed epochs, the returned error
+// includes the per-validator rewards of the last inspected epoch.
+func (t *Testnet) WaitForEpochRewardsAbove(ctx context.Context, minRewardGwei uint64) (common.Epoch, error) {
+	indices := t.Validators.Indices()
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+
+	var (
+		nextEpoch   = t.CurrentEpoch()
+		inspected   = 0
+		lastRewards []AttestationReward
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-timer.C:
+			if t.CurrentEpoch() <= nextEpoch {
+				continue
+			}
+			epoch := nextEpoch
+			nextEpoch += 1
+			rewards, err := attestationRewards(ctx, t.roundRobinBeacon(), epoch, indices)
+			if err != nil {
+				// The epoch may not be fully processed yet, retry with the
+				// next one.
+				t.t.Logf("rewards of epoch %d not available yet: %v", epoch, err)
+				continue
+			}
+			var sum SignedGwei
+			for i := range rewards {
+				sum += rewards[i].Head
+			}
+			if len(rewards) > 0 && sum/SignedGwei(len(rewards)) >= SignedGwei(minRewardGwei) {
+				return epoch, nil
+			}
+			lastRewards = rewards
+			if inspected += 1; inspected >= rewardsWaitEpochLimit {
+				return 0, fmt.Errorf("average head reward stayed below %d Gwei for %d epochs, last epoch: %s",
+					minRewardGwei, inspected, formatRewards(lastRewards))
+			}
+		}
+	}
+}
+
+// formatRewards renders per-validator head rewards for error messages.
+func formatRewards(rewards []AttestationReward) string {
+	var b strings.Builder
+	for i := range rewards {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "validator %d: %d", rewards[i].ValidatorIndex, rewards[i].Head)
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// Interchange is the EIP-3076 slashing protection interchange format, as
+// exported by the validator client's keymanager API.
+type Interchange struct {
+	Metadata struct {
+		InterchangeFormatVersion string      `json:"interchange_format_version"`
+		GenesisValidatorsRoot    common.Root `json:"genesis_validators_root"`
+	} `json:"metadata"`
+	Data []InterchangeEntry `json:"data"`
+}
+
+// InterchangeEntry is the per-validator history of an interchange file.
+type InterchangeEntry struct {
+	Pubkey       common.BLSPubkey `json:"pubkey"`
+	SignedBlocks []struct {
+		Slot common.Slot `json:"slot"`
+	} `json:"signed_blocks"`
+	SignedAttestations []struct {
+		SourceEpoch common.Epoch `json:"source_epoch"`
+		TargetEpoch common.Epoch `json:"target_epoch"`
+	} `json:"signed_attestations"`
+}
+
+// slashingProtectionExport downloads the EIP-3076 interchange of a validator
+// client through its keymanager API.
+func slashingProtectionExport(ctx context.Context, vc *ValidatorClient) (*Interchange, error) {
+	url := fmt.Sprintf("http://%v:%d/eth/v1/validator/slashing_protection", vc.IP, PortKeymanagerAPI)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach keymanager API: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("keymanager API returned status %d", resp.StatusCode)
+	}
+	var interchange Interchange
+	if err := json.Unmarshal(body, &interchange); err != nil {
+		return nil, fmt.Errorf("failed to parse interchange JSON: %w", err)
+	}
+	return &interchange, nil
+}
+
+// VerifySlashingProtectionExport downloads the EIP-3076 slashing protection
+// interchange of a node's validator client and cross-validates it against the
+// testnet: every key the client operates must have an entry, no recorded
+// block may lie in the future, and the metadata must carry this testnet's
+// genesis validators root. All violations are collected into one error, since
+// a malformed export usually has several at once.
+func (t *Testnet) VerifySlashingProtectionExport(ctx context.Context, nodeIndex int) error {
+	if nodeIndex < 0 || nodeIndex >= len(t.validators) {
+		return fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	vc := t.validators[nodeIndex]
+	interchange, err := slashingProtectionExport(ctx, vc)
+	if err != nil {
+		return fmt.Errorf("node %d: %w", nodeIndex, err)
+	}
+
+	var issues []string
+	if interchange.Metadata.GenesisValidatorsRoot != t.genesisValidatorsRoot {
+		issues = append(issues, fmt.Sprintf("genesis validators root is %s, want %s",
+			interchange.Metadata.GenesisValidatorsRoot, t.genesisValidatorsRoot))
+	}
+	entries := make(map[common.BLSPubkey]*InterchangeEntry, len(interchange.Data))
+	for i := range interchange.Data {
+		entries[interchange.Data[i].Pubkey] = &interchange.Data[i]
+	}
+	for _, v := range vc.Keys {
+		entry, ok := entries[v.PubKey]
+		if !ok {
+			issues = append(issues, fmt.Sprintf("key %s has no interchange entry", v.PubKey))
+			continue
+		}
+		currentSlot := t.CurrentSlot()
+		for _, block := range entry.SignedBlocks {
+			if block.Slot > currentSlot {
+				issues = append(issues, fmt.Sprintf("key %s records a signed block at future slot %d, current slot is %d",
+					v.PubKey, block.Slot, currentSlot))
+			}
+		}
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("bad slashing protection export of node %d: %s", nodeIndex, strings.Join(issues, "; "))
+	}
+	t.t.Logf("slashing protection export of node %d covers all %d keys and is well-formed", nodeIndex, len(vc.Keys))
+	return nil
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	blsu "github.com/protolambda/bls12-381-util"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/eth2api/client/validatorapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/ztyp/tree"
+
+	"github.com/ethereum/hive/simulators/eth2/testnet/utils"
+)
+
+// SlashingKind selects the kind of conflict built into injected attester
+// slashings.
+type SlashingKind int
+
+const (
+	// DoubleVote crafts two attestations with the same target but different
+	// head roots.
+	DoubleVote SlashingKind = iota
+	// SurroundVote crafts an attestation whose source/target span surrounds
+	// the other's.
+	SurroundVote
+)
+
+func (k SlashingKind) String() string {
+	switch k {
+	case DoubleVote:
+		return "double vote"
+	case SurroundVote:
+		return "surround vote"
+	default:
+		return fmt.Sprintf("slashing kind %d", int(k))
+	}
+}
+
+// signedIndexedAttestation signs attestation data with the given validator's
+// key as a single-member indexed attestation.
+func (t *Testnet) signedIndexedAttestation(v *utils.Validator, data phase0.AttestationData) (phase0.IndexedAttestation, error) {
+	slot, err := t.spec.EpochStartSlot(data.Target.Epoch)
+	if err != nil {
+		return phase0.IndexedAttestation{}, err
+	}
+	domain := common.ComputeDomain(common.DOMAIN_BEACON_ATTESTER, t.spec.ForkVersion(slot), t.genesisValidatorsRoot)
+	signingRoot := common.ComputeSigningRoot(data.HashTreeRoot(tree.GetHashFn()), domain)
+	return phase0.IndexedAttestation{
+		AttestingIndices: common.CommitteeIndices{v.Index},
+		Data:             data,
+		Signature:        common.BLSSignature(blsu.Sign(v.SecretKey, signingRoot[:]).Serialize()),
+	}, nil
+}
+
+// InjectAttesterSlashing crafts two conflicting attestations signed by the
+// given validator — a double vote or a surround vote — assembles them into an
+// AttesterSlashing and submits it to the pool of every beacon node. The
+// conflict is validated locally before submission, so construction bugs fail
+// fast instead of surfacing as silent pool rejections.
+func (t *Testnet) InjectAttesterSlashing(ctx context.Context, validatorIndex common.ValidatorIndex, kind SlashingKind) error {
+	v := t.Validators.ByIndex(validatorIndex)
+	if v == nil {
+		return fmt.Errorf("no known key material for validator %d", validatorIndex)
+	}
+	currentEpoch := t.CurrentEpoch()
+	if currentEpoch < 2 {
+		return fmt.Errorf("need at least two epochs to build a slashable attestation pair")
+	}
+
+	base := phase0.AttestationData{
+		Slot:   t.CurrentSlot(),
+		Index:  0,
+		Source: common.Checkpoint{Epoch: currentEpoch - 1},
+		Target: common.Checkpoint{Epoch: currentEpoch},
+	}
+	data1, data2 := base, base
+	switch kind {
+	case DoubleVote:
+		// Same target epoch, different head vote.
+		data1.BeaconBlockRoot = common.Root{0x01}
+		data2.BeaconBlockRoot = common.Root{0x02}
+	case SurroundVote:
+		// data1 surrounds data2: earlier source, later target.
+		data1.Source.Epoch = currentEpoch - 2
+		data2.Source.Epoch = currentEpoch - 1
+		data2.Target.Epoch = currentEpoch - 1
+	default:
+		return fmt.Errorf("unknown slashing kind %d", int(kind))
+	}
+	if !phase0.IsSlashableAttestationData(&data1, &data2) {
+		return fmt.Errorf("constructed %s attestation pair is not slashable", kind)
+	}
+
+	att1, err := t.signedIndexedAttestation(v, data1)
+	if err != nil {
+		return err
+	}
+	att2, err := t.signedIndexedAttestation(v, data2)
+	if err != nil {
+		return err
+	}
+	slashing := phase0.AttesterSlashing{Attestation1: att1, Attestation2: att2}
+
+	for i, bn := range t.beacons {
+		if err := beaconapi.SubmitAttesterSlashing(ctx, bn.API, &slashing); err != nil {
+			return fmt.Errorf("node %d: %w", i, asPoolRejection("attester slashing", err))
+		}
+	}
+	t.t.Logf("injected %s attester slashing of validator %d on all nodes", kind, validatorIndex)
+	return nil
+}
+
+// singleAttesterBits builds an SSZ aggregation bitlist of the given committee
+// size with only the bit at position set.
+func singleAttesterBits(committeeSize, position uint64) phase0.AttestationBits {
+	bits := make(phase0.AttestationBits, committeeSize/8+1)
+	bits[committeeSize/8] |= 1 << (committeeSize % 8) // delimiter bit
+	bits[position/8] |= 1 << (position % 8)
+	return bits
+}
+
+// committeeAssignment finds the attestation committee assignment of a
+// validator within an epoch's committees.
+func committeeAssignment(committees map[committeeKey][]common.ValidatorIndex, index common.ValidatorIndex) (key committeeKey, position uint64, size uint64, err error) {
+	for k, members := range committees {
+		for pos, member := range members {
+			if member == index {
+				return k, uint64(pos), uint64(len(members)), nil
+			}
+		}
+	}
+	return committeeKey{}, 0, 0, fmt.Errorf("validator %d not found in any committee", index)
+}
+
+// signedPoolAttestation signs attestation data with a single validator's key
+// as a pool attestation, with only the validator's committee bit set.
+func (t *Testnet) signedPoolAttestation(v *utils.Validator, data phase0.AttestationData, position, committeeSize uint64) (phase0.Attestation, error) {
+	slot, err := t.spec.EpochStartSlot(data.Target.Epoch)
+	if err != nil {
+		return phase0.Attestation{}, err
+	}
+	domain := common.ComputeDomain(common.DOMAIN_BEACON_ATTESTER, t.spec.ForkVersion(slot), t.genesisValidatorsRoot)
+	signingRoot := common.ComputeSigningRoot(data.HashTreeRoot(tree.GetHashFn()), domain)
+	return phase0.Attestation{
+		AggregationBits: singleAttesterBits(committeeSize, position),
+		Data:            data,
+		Signature:       common.BLSSignature(blsu.Sign(v.SecretKey, signingRoot[:]).Serialize()),
+	}, nil
+}
+
+// InjectSurroundVoteAttestation submits two pool attestations by the given
+// validator where the second surrounds the first in source/target epochs, to
+// exercise surround-vote slashing detection through the attestation pool
+// instead of a pre-assembled AttesterSlashing. The attestations are built
+// from live attestation data of the given node and carry the validator's real
+// committee positions of the current and previous epoch, so only the
+// conflicting epoch span sets them apart from honest votes. The expected
+// slashing is recorded so safety checks do not flag it.
+func (t *Testnet) InjectSurroundVoteAttestation(ctx context.Context, nodeIndex int, validatorIndex uint64) error {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	bn := t.beacons[nodeIndex]
+	v := t.Validators.ByIndex(common.ValidatorIndex(validatorIndex))
+	if v == nil {
+		return fmt.Errorf("no known key material for validator %d", validatorIndex)
+	}
+	currentEpoch := t.CurrentEpoch()
+	if currentEpoch < 2 {
+		return fmt.Errorf("need at least two epochs to build a surrounding attestation pair")
+	}
+
+	// The validator's real committee assignments in the two epochs spanned by
+	// the pair.
+	currentCommittees, err := epochCommittees(ctx, bn, currentEpoch, t.CurrentSlot())
+	if err != nil {
+		return err
+	}
+	currentKey, currentPos, currentSize, err := committeeAssignment(currentCommittees, v.Index)
+	if err != nil {
+		return fmt.Errorf("epoch %d: %w", currentEpoch, err)
+	}
+	previousCommittees, err := epochCommittees(ctx, bn, currentEpoch-1, t.CurrentSlot())
+	if err != nil {
+		return err
+	}
+	previousKey, previousPos, previousSize, err := committeeAssignment(previousCommittees, v.Index)
+	if err != nil {
+		return fmt.Errorf("epoch %d: %w", currentEpoch-1, err)
+	}
+
+	// Live attestation data supplies plausible checkpoints and head root; the
+	// slashing protection of the validator client is never involved, since the
+	// pair is signed and submitted directly.
+	var base phase0.AttestationData
+	if err := validatorapi.AttestationData(ctx, bn.API, t.CurrentSlot(), 0, &base); err != nil {
+		return fmt.Errorf("failed to get attestation data: %w", err)
+	}
+
+	// The first attestation votes within the previous epoch; the second spans
+	// it with an earlier source and later target.
+	inner := phase0.AttestationData{
+		Slot:            previousKey.slot,
+		Index:           previousKey.index,
+		BeaconBlockRoot: base.Source.Root,
+		Source:          base.Source,
+		Target:          common.Checkpoint{Epoch: currentEpoch - 1, Root: base.Source.Root},
+	}
+	surrounding := phase0.AttestationData{
+		Slot:            currentKey.slot,
+		Index:           currentKey.index,
+		BeaconBlockRoot: base.BeaconBlockRoot,
+		Source:          common.Checkpoint{Epoch: currentEpoch - 2},
+		Target:          base.Target,
+	}
+	if !phase0.IsSlashableAttestationData(&surrounding, &inner) {
+		return fmt.Errorf("constructed surround vote pair is not slashable")
+	}
+
+	att1, err := t.signedPoolAttestation(v, inner, previousPos, previousSize)
+	if err != nil {
+		return err
+	}
+	att2, err := t.signedPoolAttestation(v, surrounding, currentPos, currentSize)
+	if err != nil {
+		return err
+	}
+	for i, att := range []phase0.Attestation{att1, att2} {
+		if failures, err := beaconapi.SubmitAttestations(ctx, bn.API, []phase0.Attestation{att}); err != nil {
+			return fmt.Errorf("attestation %d: %w", i+1, asPoolRejection("attestation", err))
+		} else if len(failures) > 0 {
+			return fmt.Errorf("attestation %d rejected by pool: %s", i+1, failures[0].Message)
+		}
+	}
+	t.markExpectedSlashing(v.Index)
+	t.t.Logf("injected surround vote attestation pair of validator %d on node %d", validatorIndex, nodeIndex)
+	return nil
+}
+
+// signedBlockHeader signs a beacon block header with the proposer's key.
+func (t *Testnet) signedBlockHeader(v *utils.Validator, header common.BeaconBlockHeader) common.SignedBeaconBlockHeader {
+	domain := common.ComputeDomain(common.DOMAIN_BEACON_PROPOSER, t.spec.ForkVersion(header.Slot), t.genesisValidatorsRoot)
+	signingRoot := common.ComputeSigningRoot(header.HashTreeRoot(tree.GetHashFn()), domain)
+	return common.SignedBeaconBlockHeader{
+		Message:   header,
+		Signature: common.BLSSignature(blsu.Sign(v.SecretKey, signingRoot[:]).Serialize()),
+	}
+}
+
+// proposerDutySlot looks up the slot in which the given validator has
+// proposer duty in the current epoch. It falls back to the current slot when
+// the validator has no duty, which still yields a plausible slashing.
+func (t *Testnet) proposerDutySlot(ctx context.Context, validatorIndex common.ValidatorIndex) common.Slot {
+	var duties eth2api.DependentProposerDuty
+	if _, err := validatorapi.ProposerDuties(ctx, t.roundRobinBeacon().API, t.CurrentEpoch(), &duties); err == nil {
+		for _, duty := range duties.Data {
+			if duty.ValidatorIndex == validatorIndex {
+				return duty.Slot
+			}
+		}
+	}
+	return t.CurrentSlot()
+}
+
+// InjectProposerSlashing builds two conflicting signed beacon block headers
+// signed with the given validator's key, assembles them into a
+// ProposerSlashing and submits it to the pool of every beacon node. The
+// headers are placed on the validator's proposer duty slot of the current
+// epoch if it has one. Unlike producing full competing blocks, this tests
+// the slashing pool directly.
+func (t *Testnet) InjectProposerSlashing(ctx context.Context, validatorIndex common.ValidatorIndex) error {
+	return t.InjectProposerSlashingAtSlot(ctx, validatorIndex, t.proposerDutySlot(ctx, validatorIndex), false)
+}
+
+// InjectProposerSlashingAtSlot is like InjectProposerSlashing, but forces
+// the conflicting headers onto the given slot, so pool validation of
+// implausible slashings can be tested too. With waitForInclusion set, it
+// blocks until a block includes the slashing.
+func (t *Testnet) InjectProposerSlashingAtSlot(ctx context.Context, validatorIndex common.ValidatorIndex, slot common.Slot, waitForInclusion bool) error {
+	v := t.Validators.ByIndex(validatorIndex)
+	if v == nil {
+		return fmt.Errorf("no known key material for validator %d", validatorIndex)
+	}
+	base := common.BeaconBlockHeader{
+		Slot:          slot,
+		ProposerIndex: v.Index,
+	}
+	header1, header2 := base, base
+	header1.BodyRoot = common.Root{0x01}
+	header2.BodyRoot = common.Root{0x02}
+	slashing := phase0.ProposerSlashing{
+		SignedHeader1: t.signedBlockHeader(v, header1),
+		SignedHeader2: t.signedBlockHeader(v, header2),
+	}
+	for i, bn := range t.beacons {
+		if err := beaconapi.SubmitProposerSlashing(ctx, bn.API, &slashing); err != nil {
+			return fmt.Errorf("node %d: %w", i, asPoolRejection("proposer slashing", err))
+		}
+	}
+	t.t.Logf("injected proposer slashing of validator %d at slot %d on all nodes", validatorIndex, slot)
+	if waitForInclusion {
+		return t.waitForProposerSlashingInclusion(ctx, validatorIndex)
+	}
+	return nil
+}
+
+// waitForProposerSlashingInclusion polls head blocks until one includes a
+// proposer slashing of the given validator.
+func (t *Testnet) waitForProposerSlashingInclusion(ctx context.Context, validatorIndex common.ValidatorIndex) error {
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	var lastSeen common.Slot
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			block, exists, err := fetchBlock(ctx, t.roundRobinBeacon(), eth2api.BlockHead)
+			if err != nil {
+				return err
+			}
+			if !exists || block.Slot() == lastSeen {
+				continue
+			}
+			lastSeen = block.Slot()
+			for _, slashing := range block.ProposerSlashings() {
+				if slashing.SignedHeader1.Message.ProposerIndex == validatorIndex {
+					t.t.Logf("proposer slashing of validator %d included at slot %d", validatorIndex, block.Slot())
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// QueryExpectedSlashingPenalty computes the exact penalty in Gwei that the
+// initial slashing of the given validator applies: its current effective
+// balance divided by the minimum slashing penalty quotient of the active
+// fork. Tests can compare this against the observed balance change for
+// precision verification.
+func (t *Testnet) QueryExpectedSlashingPenalty(ctx context.Context, slashedValidatorIndex uint64) (uint64, error) {
+	var v eth2api.ValidatorResponse
+	if exists, err := beaconapi.StateValidator(ctx, t.roundRobinBeacon().API, eth2api.StateHead,
+		eth2api.ValidatorIdIndex(common.ValidatorIndex(slashedValidatorIndex)), &v); err != nil {
+		return 0, fmt.Errorf("failed to look up validator %d: %w", slashedValidatorIndex, err)
+	} else if !exists {
+		return 0, fmt.Errorf("validator %d not known by the beacon node", slashedValidatorIndex)
+	}
+	epoch := t.CurrentEpoch()
+	var quotient uint64
+	switch {
+	case epoch >= t.spec.ELECTRA_FORK_EPOCH:
+		quotient = uint64(t.spec.MIN_SLASHING_PENALTY_QUOTIENT_ELECTRA)
+	case epoch >= t.spec.BELLATRIX_FORK_EPOCH:
+		quotient = uint64(t.spec.MIN_SLASHING_PENALTY_QUOTIENT_BELLATRIX)
+	case epoch >= t.spec.ALTAIR_FORK_EPOCH:
+		quotient = uint64(t.spec.MIN_SLASHING_PENALTY_QUOTIENT_ALTAIR)
+	default:
+		quotient = uint64(t.spec.MIN_SLASHING_PENALTY_QUOTIENT)
+	}
+	if quotient == 0 {
+		return 0, fmt.Errorf("spec has zero slashing penalty quotient")
+	}
+	return uint64(v.Validator.EffectiveBalance) / quotient, nil
+}
+
+// slashingPenaltyParams returns the minimum slashing penalty quotient and
+// the proportional slashing multiplier of the fork active at the given
+// epoch.
+func (t *Testnet) slashingPenaltyParams(epoch common.Epoch) (quotient, multiplier uint64) {
+	switch {
+	case epoch >= t.spec.ELECTRA_FORK_EPOCH:
+		return uint64(t.spec.MIN_SLASHING_PENALTY_QUOTIENT_ELECTRA), uint64(t.spec.PROPORTIONAL_SLASHING_MULTIPLIER_BELLATRIX)
+	case epoch >= t.spec.BELLATRIX_FORK_EPOCH:
+		return uint64(t.spec.MIN_SLASHING_PENALTY_QUOTIENT_BELLATRIX), uint64(t.spec.PROPORTIONAL_SLASHING_MULTIPLIER_BELLATRIX)
+	case epoch >= t.spec.ALTAIR_FORK_EPOCH:
+		return uint64(t.spec.MIN_SLASHING_PENALTY_QUOTIENT_ALTAIR), uint64(t.spec.PROPORTIONAL_SLASHING_MULTIPLIER_ALTAIR)
+	default:
+		return uint64(t.spec.MIN_SLASHING_PENALTY_QUOTIENT), uint64(t.spec.PROPORTIONAL_SLASHING_MULTIPLIER)
+	}
+}
+
+// validatorBalanceAt reads the balance of a validator from the state at the
+// given slot.
+func validatorBalanceAt(ctx context.Context, bn *BeaconNode, validatorIndex common.ValidatorIndex, slot common.Slot) (common.Gwei, error) {
+	var balances []eth2api.ValidatorBalanceResponse
+	ids := []eth2api.ValidatorId{eth2api.ValidatorIdIndex(validatorIndex)}
+	if exists, err := beaconapi.StateValidatorBalances(ctx, bn.API, eth2api.StateIdSlot(slot), ids, &balances); err != nil {
+		return 0, fmt.Errorf("failed to get balance of validator %d at slot %d: %w", validatorIndex, slot, err)
+	} else if !exists || len(balances) == 0 {
+		return 0, fmt.Errorf("no balance of validator %d at slot %d", validatorIndex, slot)
+	}
+	return balances[0].Balance, nil
+}
+
+// waitForEpoch blocks until the wall clock has passed the end of the given
+// epoch.
+func (t *Testnet) waitForEpoch(ctx context.Context, epoch common.Epoch) error {
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for t.CurrentEpoch() <= epoch {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
+
+// VerifySlashingPenalties verifies the full penalty schedule of a validator
+// slashed in slashingEpoch: the initial penalty applied at the slashing, the
+// correlation penalty applied halfway through the withdrawability period,
+// and that the validator stops being selected for proposals. The expected
+// amounts are computed from the spec values of the fork active at each
+// stage, and mismatches are reported with expected vs actual balances.
+func (t *Testnet) VerifySlashingPenalties(ctx context.Context, validatorIndex common.ValidatorIndex, slashingEpoch common.Epoch) error {
+	bn := t.roundRobinBeacon()
+
+	// Stage 1: initial penalty, visible across the slashing epoch.
+	if err := t.waitForEpoch(ctx, slashingEpoch); err != nil {
+		return fmt.Errorf("initial penalty: %w", err)
+	}
+	epochStart, err := t.spec.EpochStartSlot(slashingEpoch)
+	if err != nil {
+		return err
+	}
+	epochEnd, err := t.spec.EpochStartSlot(slashingEpoch + 1)
+	if err != nil {
+		return err
+	}
+	var v eth2api.ValidatorResponse
+	if exists, err := beaconapi.StateValidator(ctx, bn.API, eth2api.StateIdSlot(epochStart),
+		eth2api.ValidatorIdIndex(validatorIndex), &v); err != nil || !exists {
+		return fmt.Errorf("initial penalty: failed to look up validator %d: %v", validatorIndex, err)
+	}
+	before, err := validatorBalanceAt(ctx, bn, validatorIndex, epochStart)
+	if err != nil {
+		return fmt.Errorf("initial penalty: %w", err)
+	}
+	after, err := validatorBalanceAt(ctx, bn, validatorIndex, epochEnd)
+	if err != nil {
+		return fmt.Errorf("initial penalty: %w", err)
+	}
+	quotient, multiplier := t.slashingPenaltyParams(slashingEpoch)
+	initialPenalty := common.Gwei(uint64(v.Validator.EffectiveBalance) / quotient)
+	if before < after || before-after < initialPenalty {
+		return fmt.Errorf("initial penalty: balance went %d -> %d gwei across epoch %d, expected a drop of at least %d",
+			before, after, slashingEpoch, initialPenalty)
+	}
+	t.t.Logf("initial slashing penalty applied: %d -> %d gwei (expected drop %d)", before, after, initialPenalty)
+
+	// Stage 2: correlation penalty, applied halfway to withdrawability.
+	halfway := slashingEpoch + t.spec.EPOCHS_PER_SLASHINGS_VECTOR/2
+	if err := t.waitForEpoch(ctx, halfway); err != nil {
+		return fmt.Errorf("correlation penalty: %w", err)
+	}
+	halfStart, err := t.spec.EpochStartSlot(halfway)
+	if err != nil {
+		return err
+	}
+	halfEnd, err := t.spec.EpochStartSlot(halfway + 1)
+	if err != nil {
+		return err
+	}
+	correlationPenalty, err := t.expectedCorrelationPenalty(ctx, bn, v.Validator.EffectiveBalance, multiplier, halfStart)
+	if err != nil {
+		return fmt.Errorf("correlation penalty: %w", err)
+	}
+	before, err = validatorBalanceAt(ctx, bn, validatorIndex, halfStart)
+	if err != nil {
+		return fmt.Errorf("correlation penalty: %w", err)
+	}
+	after, err = validatorBalanceAt(ctx, bn, validatorIndex, halfEnd)
+	if err != nil {
+		return fmt.Errorf("correlation penalty: %w", err)
+	}
+	if correlationPenalty > 0 && (before < after || before-after < correlationPenalty) {
+		return fmt.Errorf("correlation penalty: balance went %d -> %d gwei across epoch %d, expected a drop of at least %d",
+			before, after, halfway, correlationPenalty)
+	}
+	t.t.Logf("correlation penalty applied: %d -> %d gwei (expected drop %d)", before, after, correlationPenalty)
+
+	// Stage 3: a slashed validator must not be selected to propose.
+	var duties eth2api.DependentProposerDuty
+	if _, err := validatorapi.ProposerDuties(ctx, bn.API, t.CurrentEpoch(), &duties); err != nil {
+		return fmt.Errorf("duty check: %w", err)
+	}
+	for _, duty := range duties.Data {
+		if duty.ValidatorIndex == validatorIndex {
+			return fmt.Errorf("duty check: slashed validator %d still has proposer duty at slot %d", validatorIndex, duty.Slot)
+		}
+	}
+	return nil
+}
+
+// expectedCorrelationPenalty computes the correlation penalty of a slashed
+// validator per the spec, from the slashed and total effective balances of
+// the state at the given slot.
+func (t *Testnet) expectedCorrelationPenalty(ctx context.Context, bn *BeaconNode, effective common.Gwei, multiplier uint64, slot common.Slot) (common.Gwei, error) {
+	var validators []eth2api.ValidatorResponse
+	if exists, err := beaconapi.StateValidators(ctx, bn.API, eth2api.StateIdSlot(slot), nil, nil, &validators); err != nil || !exists {
+		return 0, fmt.Errorf("failed to get validators at slot %d: %v", slot, err)
+	}
+	epoch := t.spec.SlotToEpoch(slot)
+	var totalSlashed, totalBalance common.Gwei
+	for _, v := range validators {
+		if v.Validator.ActivationEpoch <= epoch && epoch < v.Validator.ExitEpoch {
+			totalBalance += v.Validator.EffectiveBalance
+		}
+		if v.Validator.Slashed {
+			totalSlashed += v.Validator.EffectiveBalance
+		}
+	}
+	if totalBalance == 0 {
+		return 0, fmt.Errorf("no active balance at slot %d", slot)
+	}
+	adjusted := totalSlashed * common.Gwei(multiplier)
+	if adjusted > totalBalance {
+		adjusted = totalBalance
+	}
+	increment := t.spec.EFFECTIVE_BALANCE_INCREMENT
+	return effective / increment * adjusted / totalBalance * increment, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/debugapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// beaconStateCacheKey identifies one cached full state fetch.
+type beaconStateCacheKey struct {
+	nodeIndex int
+	slot      common.Slot
+}
+
+// beaconStateCacheEntry is a cached full state, stamped with the wall-clock
+// slot it was fetched in: entries expire as soon as the clock moves on, so
+// concurrent waiters within one slot share a fetch but nothing stale survives.
+type beaconStateCacheEntry struct {
+	state     *eth2api.VersionedBeaconState
+	fetchedAt common.Slot
+}
+
+// BeaconStateForSlot returns the full beacon state of a node at a slot,
+// deduplicating fetches: full states are the most expensive objects the API
+// serves, and parallel WaitFor* helpers tend to ask for the same one in rapid
+// succession. Results are cached for the current wall-clock slot only.
+func (t *Testnet) BeaconStateForSlot(ctx context.Context, nodeIndex int, slot common.Slot) (*eth2api.VersionedBeaconState, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return nil, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	key := beaconStateCacheKey{nodeIndex, slot}
+	now := t.CurrentSlot()
+	if cached, ok := t.stateCache.Load(key); ok {
+		entry := cached.(beaconStateCacheEntry)
+		if entry.fetchedAt == now {
+			atomic.AddUint64(&t.stateCacheHits, 1)
+			return entry.state, nil
+		}
+		t.stateCache.Delete(key)
+	}
+	var state eth2api.VersionedBeaconState
+	if exists, err := debugapi.BeaconStateV2(ctx, t.stateClient(nodeIndex), eth2api.StateIdSlot(slot), &state); err != nil {
+		return nil, fmt.Errorf("failed to fetch state at slot %d from node %d: %w", slot, nodeIndex, err)
+	} else if !exists {
+		return nil, fmt.Errorf("node %d has no state at slot %d", nodeIndex, slot)
+	}
+	t.stateCache.Store(key, beaconStateCacheEntry{state: &state, fetchedAt: now})
+	return &state, nil
+}
+
+// StateCacheHits returns how many BeaconStateForSlot calls were answered from
+// the cache instead of hitting a beacon node.
+func (t *Testnet) StateCacheHits() uint64 {
+	return atomic.LoadUint64(&t.stateCacheHits)
+}
+
+// stateClient returns the API client used for full state fetches of the given
+// node, honoring per-node test overrides.
+func (t *Testnet) stateClient(nodeIndex int) eth2api.Client {
+	if cli, ok := t.stateClients[nodeIndex]; ok {
+		return cli
+	}
+	return t.beacons[nodeIndex].API
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/configs"
+)
+
+// countingBeaconClient is a beacon API stand-in that answers every request
+// with an empty success and counts how often it was asked.
+type countingBeaconClient struct {
+	calls int
+}
+
+func (c *countingBeaconClient) Request(ctx context.Context, req eth2api.PreparedRequest) eth2api.Response {
+	c.calls++
+	return emptyOKResponse{}
+}
+
+type emptyOKResponse struct{}
+
+func (emptyOKResponse) Decode(dest interface{}) (uint, error) {
+	return 200, nil
+}
+
+// TestBeaconStateCacheHits asks for the same state repeatedly within one slot
+// and checks that only the first call reaches the beacon client while the rest
+// are counted as cache hits.
+func TestBeaconStateCacheHits(t *testing.T) {
+	cli := &countingBeaconClient{}
+	spec := *configs.Mainnet
+	tn := &Testnet{
+		spec:         &spec,
+		genesisTime:  common.Timestamp(time.Now().Unix()),
+		beacons:      []*BeaconNode{{}},
+		stateClients: map[int]eth2api.Client{0: cli},
+	}
+
+	ctx := context.Background()
+	const requests = 5
+	for i := 0; i < requests; i++ {
+		if _, err := tn.BeaconStateForSlot(ctx, 0, 0); err != nil {
+			t.Fatalf("state request %d: %v", i, err)
+		}
+	}
+
+	if cli.calls != 1 {
+		t.Fatalf("beacon client saw %d state fetches, want 1", cli.calls)
+	}
+	if hits := tn.StateCacheHits(); hits != requests-1 {
+		t.Fatalf("cache recorded %d hits, want %d", hits, requests-1)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// stateRootAt returns the state root a node reports for a slot: the state
+// root of the block at that slot, or the stored state root when the slot has
+// no block.
+func stateRootAt(ctx context.Context, bn *BeaconNode, slot common.Slot) (common.Root, bool, error) {
+	block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+	if err != nil {
+		return common.Root{}, false, err
+	}
+	if exists {
+		return block.StateRoot(), true, nil
+	}
+	return beaconapi.StateRoot(ctx, bn.API, eth2api.StateIdSlot(slot))
+}
+
+// CompareStateRoots checks that all nodes agree on the beacon state root at
+// the given slot. It first compares the cheap state root references every
+// node serves; only when those diverge does it download the full states,
+// recompute their SSZ hash-tree-roots locally and report the per-node values,
+// so the error distinguishes a real state divergence from a node merely
+// serving a wrong root reference.
+func (t *Testnet) CompareStateRoots(ctx context.Context, slot common.Slot) error {
+	roots := make([]common.Root, len(t.beacons))
+	for i, bn := range t.beacons {
+		root, exists, err := stateRootAt(ctx, bn, slot)
+		if err != nil {
+			return fmt.Errorf("failed to get state root at slot %d from node %d: %w", slot, i, err)
+		}
+		if !exists {
+			return fmt.Errorf("node %d has no state at slot %d", i, slot)
+		}
+		roots[i] = root
+	}
+	mismatch := false
+	for i := 1; i < len(roots); i++ {
+		if roots[i] != roots[0] {
+			mismatch = true
+			break
+		}
+	}
+	if !mismatch {
+		t.t.Logf("all %d nodes agree on state root %s at slot %d", len(t.beacons), roots[0], slot)
+		return nil
+	}
+
+	// Referenced roots diverge: download the full states and recompute the
+	// roots ourselves, to pin the divergence on actual state content.
+	computed := make([]string, len(t.beacons))
+	for i := range t.beacons {
+		versioned, err := t.BeaconStateForSlot(ctx, i, slot)
+		if err != nil {
+			computed[i] = fmt.Sprintf("node %d: %v", i, err)
+			continue
+		}
+		view, err := versioned.Tree(t.spec)
+		if err != nil {
+			computed[i] = fmt.Sprintf("node %d: failed to rebuild state: %v", i, err)
+			continue
+		}
+		computed[i] = fmt.Sprintf("node %d: referenced %s, computed %s", i, roots[i], view.HashTreeRoot(tree.GetHashFn()))
+	}
+	return fmt.Errorf("state roots diverge at slot %d: %s", slot, strings.Join(computed, "; "))
+}
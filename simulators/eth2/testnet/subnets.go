@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/nodeapi"
+	"github.com/protolambda/eth2api/client/validatorapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/view"
+)
+
+// attnetsENREntry is the raw "attnets" key of a beacon node's ENR: the
+// 64-subnet subscription bitvector.
+type attnetsENREntry []byte
+
+func (attnetsENREntry) ENRKey() string { return "attnets" }
+
+// ConfigureSubnets asks a beacon node to prepare the given attestation
+// subnets, through the committee subscription endpoint that validator clients
+// use to announce upcoming duties. Each subnet is signaled as an aggregator
+// duty at the next slot, which makes the node subscribe to the subnet topic
+// rather than merely discover peers on it.
+func (t *Testnet) ConfigureSubnets(ctx context.Context, nodeIndex int, attestationSubnets []uint64) error {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	if len(attestationSubnets) == 0 {
+		return fmt.Errorf("no subnets given")
+	}
+	attSubnetCount := uint64(t.spec.ATTESTATION_SUBNET_COUNT)
+	signals := make([]*eth2api.BeaconCommitteeSubscribeSignal, 0, len(attestationSubnets))
+	for _, subnet := range attestationSubnets {
+		if subnet >= attSubnetCount {
+			return fmt.Errorf("subnet %d out of range, %d attestation subnets exist", subnet, attSubnetCount)
+		}
+		// With one committee per subnet, the committee index at the slot maps
+		// directly onto the subnet.
+		signals = append(signals, &eth2api.BeaconCommitteeSubscribeSignal{
+			ValidatorIndex:   0,
+			CommitteeIndex:   common.CommitteeIndex(subnet),
+			CommitteesAtSlot: view.Uint64View(attSubnetCount),
+			Slot:             t.CurrentSlot() + 1,
+			IsAggregator:     view.BoolView(true),
+		})
+	}
+	syncing, err := validatorapi.PrepareBeaconCommitteeSubnet(ctx, t.beacons[nodeIndex].API, signals)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe node %d to subnets: %w", nodeIndex, err)
+	}
+	if syncing {
+		return fmt.Errorf("node %d rejected subnet subscriptions, still syncing", nodeIndex)
+	}
+	t.t.Logf("node %d subscribed to attestation subnets %v", nodeIndex, attestationSubnets)
+	return nil
+}
+
+// VerifySubnetSubscription checks whether a beacon node has at least one
+// inbound peer advertising the given attestation subnet in the attnets field
+// of its ENR, i.e. whether the subscription actually attracted subnet peers.
+func (t *Testnet) VerifySubnetSubscription(ctx context.Context, nodeIndex int, subnet uint64) (bool, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return false, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	if subnet >= uint64(t.spec.ATTESTATION_SUBNET_COUNT) {
+		return false, fmt.Errorf("subnet %d out of range", subnet)
+	}
+	var peers []eth2api.Peer
+	if err := nodeapi.Peers(ctx, t.beacons[nodeIndex].API,
+		[]eth2api.ConnectionState{eth2api.ConnectionStateConnected},
+		[]eth2api.ConnectionDirection{eth2api.ConnectionDirectionInbound}, &peers); err != nil {
+		return false, fmt.Errorf("failed to get inbound peers of node %d: %w", nodeIndex, err)
+	}
+	for _, peer := range peers {
+		if peer.ENR == "" {
+			continue
+		}
+		record, err := enode.Parse(enode.ValidSchemes, peer.ENR)
+		if err != nil {
+			t.t.Logf("node %d: undecodable peer ENR %q: %v", nodeIndex, peer.ENR, err)
+			continue
+		}
+		var attnets attnetsENREntry
+		if err := record.Record().Load(&attnets); err != nil {
+			continue
+		}
+		if subnet/8 < uint64(len(attnets)) && attnets[subnet/8]&(1<<(subnet%8)) != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
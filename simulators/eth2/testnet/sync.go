@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/protolambda/eth2api/client/nodeapi"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// syncSlotsTolerance is how far a synced node's head may trail the clock
+// slot and still count as in sync.
+const syncSlotsTolerance = common.Slot(2)
+
+// syncWaitEpochLimit bounds WaitForNodeSynced: a node that has not caught up
+// after this many epochs is treated as failed.
+const syncWaitEpochLimit = 4
+
+// WaitForBackfillComplete waits until a checkpoint-synced node has
+// backfilled its historical chain, detected by the genesis block becoming
+// retrievable from it. The sync status endpoint of this API version does not
+// expose a backfill watermark, so block availability is the portable signal.
+// It times out after as many slot durations as have passed since genesis,
+// which bounds the work a backfill can reasonably need.
+func (t *Testnet) WaitForBackfillComplete(ctx context.Context, nodeIndex int) error {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	bn := t.beacons[nodeIndex]
+	deadline := time.Now().Add(time.Duration(t.CurrentSlot()) * t.slotDuration())
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			if time.Now().After(deadline) {
+				return fmt.Errorf("node %d did not finish backfill in time", nodeIndex)
+			}
+			_, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(0))
+			if err != nil {
+				t.t.Logf("failed to poll genesis block of node %d: %v", nodeIndex, err)
+				continue
+			}
+			if exists {
+				t.t.Logf("node %d backfilled down to the genesis block", nodeIndex)
+				return nil
+			}
+			t.t.Logf("node %d still backfilling, genesis block not yet available", nodeIndex)
+		}
+	}
+}
+
+// WaitForNodeSynced polls the sync status of a single node until both its
+// beacon node and its execution client report being in sync: the beacon
+// node's sync distance must be zero with the head within syncSlotsTolerance
+// of the clock slot, and the execution client's eth_syncing must report
+// false. Sync progress is logged on every poll. It fails if the node is not
+// synced after syncWaitEpochLimit epochs.
+func (t *Testnet) WaitForNodeSynced(ctx context.Context, nodeIndex int) error {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	bn, eth1 := t.beacons[nodeIndex], t.eth1[nodeIndex]
+	deadline := time.Now().Add(time.Duration(uint64(t.spec.SLOTS_PER_EPOCH)*syncWaitEpochLimit) * t.slotDuration())
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			if time.Now().After(deadline) {
+				return fmt.Errorf("node %d not synced after %d epochs", nodeIndex, syncWaitEpochLimit)
+			}
+			var status eth2api.SyncingStatus
+			if err := nodeapi.SyncingStatus(ctx, bn.API, &status); err != nil {
+				t.t.Logf("failed to poll sync status of node %d: %v", nodeIndex, err)
+				continue
+			}
+			clockSlot := t.CurrentSlot()
+			t.t.Logf("node %d sync progress: head slot %d, sync distance %d, clock slot %d",
+				nodeIndex, status.HeadSlot, status.SyncDistance, clockSlot)
+			if status.SyncDistance != 0 || status.HeadSlot+syncSlotsTolerance < clockSlot {
+				continue
+			}
+			progress, err := eth1.EthClient().SyncProgress(ctx)
+			if err != nil {
+				t.t.Logf("failed to poll eth1 sync status of node %d: %v", nodeIndex, err)
+				continue
+			}
+			if progress != nil {
+				t.t.Logf("node %d eth1 sync progress: block %d of %d", nodeIndex, progress.CurrentBlock, progress.HighestBlock)
+				continue
+			}
+			t.t.Logf("node %d is synced", nodeIndex)
+			return nil
+		}
+	}
+}
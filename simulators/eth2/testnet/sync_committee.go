@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	blsu "github.com/protolambda/bls12-381-util"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// syncAggregateSampleSlots is how many slots into a sync committee period the
+// handover assertion scans for a block with sync committee participation.
+const syncAggregateSampleSlots = 8
+
+// syncCommitteePeriodStart returns the first slot of a sync committee period.
+func (t *Testnet) syncCommitteePeriodStart(period uint64) (common.Slot, error) {
+	epoch := common.Epoch(period * uint64(t.spec.EPOCHS_PER_SYNC_COMMITTEE_PERIOD))
+	return t.spec.EpochStartSlot(epoch)
+}
+
+// syncCommitteeAtSlot fetches the validator indices of the sync committee
+// that is current at the given slot.
+func syncCommitteeAtSlot(ctx context.Context, bn *BeaconNode, slot common.Slot) ([]common.ValidatorIndex, error) {
+	var committee eth2api.SyncCommittees
+	if exists, err := beaconapi.SyncCommittees(ctx, bn.API, eth2api.StateIdSlot(slot), nil, &committee); err != nil {
+		return nil, fmt.Errorf("failed to get sync committee at slot %d: %w", slot, err)
+	} else if !exists {
+		return nil, fmt.Errorf("no state at slot %d to read the sync committee from", slot)
+	}
+	return committee.Validators, nil
+}
+
+// GetSyncCommitteeAssignment returns the validator indices of the sync
+// committee that is active in the given epoch, as reported by the given
+// node's head state.
+func (t *Testnet) GetSyncCommitteeAssignment(ctx context.Context, nodeIndex int, epoch common.Epoch) ([]uint64, error) {
+	if nodeIndex < 0 || nodeIndex >= len(t.beacons) {
+		return nil, fmt.Errorf("node index %d out of range", nodeIndex)
+	}
+	var committee eth2api.SyncCommittees
+	if exists, err := beaconapi.SyncCommittees(ctx, t.beacons[nodeIndex].API, eth2api.StateHead, &epoch, &committee); err != nil {
+		return nil, fmt.Errorf("failed to get sync committee of epoch %d from node %d: %w", epoch, nodeIndex, err)
+	} else if !exists {
+		return nil, fmt.Errorf("node %d has no head state", nodeIndex)
+	}
+	indices := make([]uint64, len(committee.Validators))
+	for i, index := range committee.Validators {
+		indices[i] = uint64(index)
+	}
+	return indices, nil
+}
+
+// AssertSyncCommitteeContainsValidators checks that all given validators are
+// members of the sync committee active in the given epoch, per the given
+// node. Use it to verify that controlled validators were assigned before
+// asserting on their sync aggregate contributions.
+func (t *Testnet) AssertSyncCommitteeContainsValidators(ctx context.Context, nodeIndex int, epoch common.Epoch, validatorIndices []uint64) error {
+	committee, err := t.GetSyncCommitteeAssignment(ctx, nodeIndex, epoch)
+	if err != nil {
+		return err
+	}
+	members := make(map[uint64]bool, len(committee))
+	for _, index := range committee {
+		members[index] = true
+	}
+	var missing []uint64
+	for _, index := range validatorIndices {
+		if !members[index] {
+			missing = append(missing, index)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("validators %v are not in the sync committee of epoch %d", missing, epoch)
+	}
+	return nil
+}
+
+// AssertSyncCommitteeHandover verifies the sync committee rotation at the
+// boundary between the given period and the next: the two committees must
+// differ, and blocks in both periods must carry sync aggregates that verify
+// against the committee of their own period. It waits until the next period
+// has produced enough slots to sample.
+func (t *Testnet) AssertSyncCommitteeHandover(ctx context.Context, period uint64) error {
+	startA, err := t.syncCommitteePeriodStart(period)
+	if err != nil {
+		return err
+	}
+	startB, err := t.syncCommitteePeriodStart(period + 1)
+	if err != nil {
+		return err
+	}
+
+	// Wait until the next period has a few slots worth of blocks.
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for t.CurrentSlot() < startB+syncAggregateSampleSlots {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	bn := t.roundRobinBeacon()
+	committeeA, err := syncCommitteeAtSlot(ctx, bn, startA)
+	if err != nil {
+		return err
+	}
+	committeeB, err := syncCommitteeAtSlot(ctx, bn, startB)
+	if err != nil {
+		return err
+	}
+	same := len(committeeA) == len(committeeB)
+	if same {
+		for i := range committeeA {
+			if committeeA[i] != committeeB[i] {
+				same = false
+				break
+			}
+		}
+	}
+	if same {
+		return fmt.Errorf("sync committee did not rotate between periods %d and %d", period, period+1)
+	}
+
+	if err := t.verifySyncAggregates(ctx, bn, startA, committeeA); err != nil {
+		return fmt.Errorf("period %d: %w", period, err)
+	}
+	if err := t.verifySyncAggregates(ctx, bn, startB, committeeB); err != nil {
+		return fmt.Errorf("period %d: %w", period+1, err)
+	}
+	t.t.Logf("sync committee handover between periods %d and %d verified", period, period+1)
+	return nil
+}
+
+// verifySyncAggregates checks the sync aggregate signatures of the blocks in
+// the first slots of a period against the given committee. At least one block
+// with sync committee participation must be found and verify.
+func (t *Testnet) verifySyncAggregates(ctx context.Context, bn *BeaconNode, periodStart common.Slot, committee []common.ValidatorIndex) error {
+	verified := 0
+	for slot := periodStart; slot < periodStart+syncAggregateSampleSlots; slot++ {
+		block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		agg, ok := block.SyncAggregate()
+		if !ok {
+			return fmt.Errorf("block at slot %d is pre-Altair and has no sync aggregate", slot)
+		}
+		var pubkeys []*blsu.Pubkey
+		for i := uint64(0); i < uint64(len(committee)); i++ {
+			if !agg.SyncCommitteeBits.GetBit(i) {
+				continue
+			}
+			v := t.Validators.ByIndex(committee[i])
+			if v == nil {
+				return fmt.Errorf("no known key material for sync committee member %d", committee[i])
+			}
+			var pubkey blsu.Pubkey
+			pubkeyBytes := [48]byte(v.PubKey)
+			if err := pubkey.Deserialize(&pubkeyBytes); err != nil {
+				return fmt.Errorf("bad pubkey of validator %d: %v", committee[i], err)
+			}
+			pubkeys = append(pubkeys, &pubkey)
+		}
+		if len(pubkeys) == 0 {
+			continue
+		}
+		// The aggregate signs the previous head, i.e. the block's parent
+		// root, under the sync committee domain of the previous slot.
+		domain := common.ComputeDomain(common.DOMAIN_SYNC_COMMITTEE, t.spec.ForkVersion(slot-1), t.genesisValidatorsRoot)
+		signingRoot := common.ComputeSigningRoot(block.ParentRoot(), domain)
+		var sig blsu.Signature
+		sigBytes := [96]byte(agg.SyncCommitteeSignature)
+		if err := sig.Deserialize(&sigBytes); err != nil {
+			return fmt.Errorf("bad sync aggregate signature at slot %d: %v", slot, err)
+		}
+		if !blsu.Eth2FastAggregateVerify(pubkeys, signingRoot[:], &sig) {
+			return fmt.Errorf("sync aggregate of block at slot %d does not verify against its period's committee", slot)
+		}
+		verified++
+	}
+	if verified == 0 {
+		return fmt.Errorf("no blocks with sync committee participation in slots %d-%d", periodStart, periodStart+syncAggregateSampleSlots-1)
+	}
+	return nil
+}
+
+// SyncParticipationByNode measures per-node sync committee participation over
+// a slot range: for every slot with a block, each sync committee member owes a
+// contribution, and the block's sync aggregate bits show who delivered. The
+// duties are attributed to the validator client owning each member, and the
+// fulfilled fraction per node is returned. Nodes without sync committee
+// members in the range are absent from the result.
+func (t *Testnet) SyncParticipationByNode(ctx context.Context, fromSlot, toSlot common.Slot) (map[int]float64, error) {
+	bn := t.roundRobinBeacon()
+	type tally struct{ owed, delivered int }
+	tallies := make(map[int]*tally)
+	var committee []common.ValidatorIndex
+	committeePeriod := uint64(0)
+	for slot := fromSlot; slot <= toSlot; slot++ {
+		period := uint64(t.spec.SlotToEpoch(slot)) / uint64(t.spec.EPOCHS_PER_SYNC_COMMITTEE_PERIOD)
+		if committee == nil || period != committeePeriod {
+			var err error
+			if committee, err = syncCommitteeAtSlot(ctx, bn, slot); err != nil {
+				return nil, err
+			}
+			committeePeriod = period
+		}
+		block, exists, err := fetchBlock(ctx, bn, eth2api.BlockIdSlot(slot))
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			// Without a block there is nothing to contribute to.
+			continue
+		}
+		agg, ok := block.SyncAggregate()
+		if !ok {
+			return nil, fmt.Errorf("block at slot %d is pre-Altair and has no sync aggregate", slot)
+		}
+		for i := uint64(0); i < uint64(len(committee)); i++ {
+			owner, err := t.OwnerOfValidator(committee[i])
+			if err != nil {
+				continue
+			}
+			s := tallies[owner]
+			if s == nil {
+				s = &tally{}
+				tallies[owner] = s
+			}
+			s.owed++
+			if agg.SyncCommitteeBits.GetBit(i) {
+				s.delivered++
+			}
+		}
+	}
+	out := make(map[int]float64, len(tallies))
+	for node, s := range tallies {
+		out[node] = float64(s.delivered) / float64(s.owed)
+	}
+	return out, nil
+}
+
+// RequireSyncParticipationAbove asserts that the given node's validators
+// delivered more than the given fraction of their sync committee duties over
+// the previous epoch. The node must have had sync committee members in that
+// epoch.
+func (t *Testnet) RequireSyncParticipationAbove(ctx context.Context, fraction float64, nodeIndex int) error {
+	epoch := t.CurrentEpoch()
+	if epoch == 0 {
+		return fmt.Errorf("no previous epoch to measure sync participation over")
+	}
+	startSlot, err := t.spec.EpochStartSlot(epoch - 1)
+	if err != nil {
+		return err
+	}
+	participation, err := t.SyncParticipationByNode(ctx, startSlot, startSlot+common.Slot(t.spec.SLOTS_PER_EPOCH)-1)
+	if err != nil {
+		return err
+	}
+	got, ok := participation[nodeIndex]
+	if !ok {
+		return fmt.Errorf("node %d had no sync committee members in epoch %d", nodeIndex, epoch-1)
+	}
+	t.t.Logf("node %d sync participation in epoch %d: %.2f", nodeIndex, epoch-1, got)
+	if got <= fraction {
+		return fmt.Errorf("node %d sync participation %.2f is not above %.2f", nodeIndex, got, fraction)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// WaitForTerminalPoWBlock blocks until the execution chain of node 0 has
+// accumulated the terminal total difficulty of the spec, returning the hash
+// and total difficulty of the terminal proof-of-work block. Once the
+// threshold is crossed it also requires the consensus layer's fork choice to
+// be responsive, so callers know both layers are ready for the merge
+// transition. Polls once per slot until the context ends.
+func (t *Testnet) WaitForTerminalPoWBlock(ctx context.Context) (ethcommon.Hash, *big.Int, error) {
+	ttdText, err := t.spec.TERMINAL_TOTAL_DIFFICULTY.MarshalText()
+	if err != nil {
+		return ethcommon.Hash{}, nil, fmt.Errorf("failed to read terminal total difficulty: %w", err)
+	}
+	ttd, ok := new(big.Int).SetString(string(ttdText), 10)
+	if !ok {
+		return ethcommon.Hash{}, nil, fmt.Errorf("unparseable terminal total difficulty %q", ttdText)
+	}
+	node := t.eth1[0]
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ethcommon.Hash{}, nil, fmt.Errorf("terminal total difficulty %v not reached: %w", ttd, ctx.Err())
+		case <-timer.C:
+			var head struct {
+				Number          hexutil.Uint64 `json:"number"`
+				Hash            ethcommon.Hash `json:"hash"`
+				TotalDifficulty *hexutil.Big   `json:"totalDifficulty"`
+			}
+			if err := node.RPC().CallContext(ctx, &head, "eth_getBlockByNumber", "latest", false); err != nil {
+				t.t.Logf("failed to poll execution head: %v", err)
+				continue
+			}
+			if head.TotalDifficulty == nil {
+				t.t.Logf("execution head %d reports no total difficulty", head.Number)
+				continue
+			}
+			total := (*big.Int)(head.TotalDifficulty)
+			if total.Cmp(ttd) < 0 {
+				t.t.Logf("total difficulty %v of %v at block %d", total, ttd, head.Number)
+				continue
+			}
+			// The terminal block is only useful once the consensus layer can
+			// act on it; its fork choice answering is the readiness signal.
+			if _, err := t.GetForkChoiceTree(ctx, 0); err != nil {
+				t.t.Logf("terminal block reached but consensus fork choice not ready: %v", err)
+				continue
+			}
+			t.t.Logf("terminal PoW block %s at height %d, total difficulty %v", head.Hash, head.Number, total)
+			return head.Hash, total, nil
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+
+	"github.com/ethereum/hive/hivesim"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+
+	"github.com/ethereum/hive/simulators/eth2/testnet/utils"
+)
+
+// Testnet tracks a running eth2 testnet: the participating clients, the
+// genesis information shared by all of them, and the key material driving
+// the validators.
+type Testnet struct {
+	t *hivesim.T
+
+	genesisTime           common.Timestamp
+	genesisValidatorsRoot common.Root
+	spec                  *common.Spec
+
+	eth1       []*Eth1Node
+	beacons    []*BeaconNode
+	validators []*ValidatorClient
+
+	// Validators holds the key material of the full validator set, in
+	// genesis order.
+	Validators utils.Validators
+	// ValidatorGroups optionally partitions the validator set into named
+	// groups for per-group analysis.
+	ValidatorGroups map[string]*utils.Validators
+
+	// rrCounter drives round-robin beacon node selection for submissions.
+	rrCounter uint64
+
+	// proxies are the beacon API proxies interposed on demand between a
+	// node's validator client and beacon node, by node index.
+	proxies map[int]*BeaconAPIProxy
+
+	// executionProxies are the recording proxies interposed at startup
+	// between a node's beacon node and execution client, by node index, for
+	// nodes with forkchoice tracking enabled.
+	executionProxies map[int]*ExecutionRPCProxy
+
+	// signers are the remote signer containers of nodes configured with
+	// UseRemoteSigner, by node index.
+	signers map[int]*RemoteSigner
+
+	// mockBuilders are the mock builders of builder-enabled nodes, by node
+	// index.
+	mockBuilders map[int]*MockBuilder
+
+	// blobbers are the blob gossip test doubles, if any. The first entry is
+	// the primary blobber of the single-blobber helpers.
+	blobbers []*Blobber
+
+	// blobberTransitions records the executed blobber action schedule.
+	blobberTransitionsMu sync.Mutex
+	blobberTransitions   []BlobberTransition
+
+	// duplicateNodes records the nodes whose validator client intentionally
+	// runs a copy of another node's keys, by node index, for doppelganger
+	// scenarios.
+	duplicateNodes map[int]int
+
+	// expectedExits tracks the validators whose exit was initiated by a test
+	// helper, so that safety checks can tell expected exits apart.
+	expectedExitsMu sync.Mutex
+	expectedExits   map[common.ValidatorIndex]bool
+
+	// expectedSlashings tracks the validators whose slashing a test expects,
+	// e.g. from an intentional doppelganger run without detection.
+	expectedSlashingsMu sync.Mutex
+	expectedSlashings   map[common.ValidatorIndex]bool
+
+	// expectedBadProposers tracks the validators whose keys a blobber holds,
+	// so participation and verification helpers can tell expected-bad
+	// proposers apart from honest ones when judging chain health.
+	expectedBadProposersMu sync.Mutex
+	expectedBadProposers   map[common.ValidatorIndex]bool
+
+	// ownerCache lazily maps validator indices to the node controlling
+	// them; nil means it needs rebuilding from the key partition.
+	ownerCacheMu sync.Mutex
+	ownerCache   map[common.ValidatorIndex]int
+
+	// elWithdrawalKeys holds the execution account keys of registered 0x01
+	// withdrawal addresses, for EIP-7002 withdrawal requests.
+	elWithdrawalKeysMu sync.Mutex
+	elWithdrawalKeys   map[ethcommon.Address]*ecdsa.PrivateKey
+
+	// builderSlots records the slots whose block came from an external
+	// builder, for latency attribution.
+	builderSlotsMu sync.Mutex
+	builderSlots   map[common.Slot]bool
+
+	// builderScenarioSlots records the slots the configured builder scenario
+	// disrupted, with what was done to each.
+	builderScenarioSlotsMu sync.Mutex
+	builderScenarioSlots   map[common.Slot]string
+
+	// dutyTracker is the background proposer duty tracker, if started.
+	dutyTracker *DutyTracker
+
+	// blobSpammerActive records that a blob spammer ran during the test, so
+	// the post-test data availability sweep knows there is blob data to check.
+	blobSpammerActive bool
+
+	// blobberErrorsFatal makes internal blobber errors fail polling waits
+	// instead of only logging warnings.
+	blobberErrorsFatal bool
+
+	// logEngineLatency makes wait helpers append the worst recent newPayload
+	// latency to their per-slot status lines.
+	logEngineLatency bool
+
+	// lazyBeaconState makes health checks batch-query only the testnet's own
+	// validators instead of pulling full registries.
+	lazyBeaconState bool
+
+	// stateCache deduplicates full beacon state fetches within a slot, keyed
+	// by node and state slot. stateCacheHits counts the fetches it absorbed.
+	stateCache     sync.Map
+	stateCacheHits uint64
+
+	// stateClients optionally overrides the API client used for full state
+	// fetches, by node index; tests install counting fakes here.
+	stateClients map[int]eth2api.Client
+}
+
+// markExpectedSlashing records that a test expects the given validator to be
+// slashed.
+func (t *Testnet) markExpectedSlashing(index common.ValidatorIndex) {
+	t.expectedSlashingsMu.Lock()
+	defer t.expectedSlashingsMu.Unlock()
+	if t.expectedSlashings == nil {
+		t.expectedSlashings = make(map[common.ValidatorIndex]bool)
+	}
+	t.expectedSlashings[index] = true
+}
+
+// isExpectedSlashing reports whether a test expects the given validator to
+// be slashed.
+func (t *Testnet) isExpectedSlashing(index common.ValidatorIndex) bool {
+	t.expectedSlashingsMu.Lock()
+	defer t.expectedSlashingsMu.Unlock()
+	return t.expectedSlashings[index]
+}
+
+// markExpectedExit records that a test helper initiated the exit of the
+// given validator.
+func (t *Testnet) markExpectedExit(index common.ValidatorIndex) {
+	t.expectedExitsMu.Lock()
+	defer t.expectedExitsMu.Unlock()
+	if t.expectedExits == nil {
+		t.expectedExits = make(map[common.ValidatorIndex]bool)
+	}
+	t.expectedExits[index] = true
+}
+
+// isExpectedExit reports whether a test helper initiated the exit of the
+// given validator.
+func (t *Testnet) isExpectedExit(index common.ValidatorIndex) bool {
+	t.expectedExitsMu.Lock()
+	defer t.expectedExitsMu.Unlock()
+	return t.expectedExits[index]
+}
+
+// markExpectedBadProposers records a set of validators as held by a blobber,
+// i.e. their proposals may legitimately be disruptive.
+func (t *Testnet) markExpectedBadProposers(keys utils.Validators) {
+	t.expectedBadProposersMu.Lock()
+	defer t.expectedBadProposersMu.Unlock()
+	if t.expectedBadProposers == nil {
+		t.expectedBadProposers = make(map[common.ValidatorIndex]bool)
+	}
+	for _, v := range keys {
+		t.expectedBadProposers[v.Index] = true
+	}
+}
+
+// IsExpectedBadProposer reports whether a blobber holds the given validator's
+// key, so a bad proposal from it is expected rather than a client fault.
+func (t *Testnet) IsExpectedBadProposer(index common.ValidatorIndex) bool {
+	t.expectedBadProposersMu.Lock()
+	defer t.expectedBadProposersMu.Unlock()
+	return t.expectedBadProposers[index]
+}
+
+// Spec returns the spec the testnet was launched with.
+func (t *Testnet) Spec() *common.Spec {
+	return t.spec
+}
+
+// GenesisTime returns the genesis timestamp of the testnet.
+func (t *Testnet) GenesisTime() common.Timestamp {
+	return t.genesisTime
+}
+
+// GenesisValidatorsRoot returns the genesis validators root of the testnet.
+func (t *Testnet) GenesisValidatorsRoot() common.Root {
+	return t.genesisValidatorsRoot
+}
+
+// CurrentSlot returns the slot the testnet is in, based on wall-clock time.
+func (t *Testnet) CurrentSlot() common.Slot {
+	return t.spec.TimeToSlot(common.Timestamp(time.Now().Unix()), t.genesisTime)
+}
+
+// CurrentEpoch returns the epoch the testnet is in, based on wall-clock time.
+func (t *Testnet) CurrentEpoch() common.Epoch {
+	return t.spec.SlotToEpoch(t.CurrentSlot())
+}
+
+// roundRobinBeacon returns the next beacon node to use for a submission,
+// spreading load over all nodes of the testnet.
+func (t *Testnet) roundRobinBeacon() *BeaconNode {
+	i := atomic.AddUint64(&t.rrCounter, 1)
+	return t.beacons[int(i)%len(t.beacons)]
+}
+
+// slotDuration returns the wall-clock duration of one slot.
+func (t *Testnet) slotDuration() time.Duration {
+	return time.Duration(t.spec.SECONDS_PER_SLOT) * time.Second
+}
+
+// WaitForFinality blocks until every beacon node reports the same finalized
+// checkpoint beyond genesis, polling once per slot. It returns the finalized
+// checkpoint, or an error if the context is canceled first.
+func (t *Testnet) WaitForFinality(ctx context.Context) (common.Checkpoint, error) {
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return common.Checkpoint{}, ctx.Err()
+		case <-timer.C:
+			if err := t.checkBlobberErrors(); err != nil {
+				return common.Checkpoint{}, err
+			}
+			var finalized common.Checkpoint
+			allFinal := true
+			for i, b := range t.beacons {
+				var checkpoints eth2api.FinalityCheckpoints
+				if exists, err := beaconapi.FinalityCheckpoints(ctx, b.API, eth2api.StateHead, &checkpoints); err != nil || !exists {
+					t.t.Logf("failed to poll finality of node %d: %v", i, err)
+					allFinal = false
+					break
+				}
+				if checkpoints.Finalized == (common.Checkpoint{}) {
+					allFinal = false
+					break
+				}
+				if i == 0 {
+					finalized = checkpoints.Finalized
+				} else if checkpoints.Finalized != finalized {
+					// Differing roots for the same finalized epoch is a real
+					// split. A node merely reporting an older (or newer) epoch
+					// is normal propagation skew around an epoch transition;
+					// keep polling until it catches up.
+					if checkpoints.Finalized.Epoch == finalized.Epoch {
+						return common.Checkpoint{}, fmt.Errorf("nodes disagree on finality: node 0 has %s, node %d has %s",
+							&finalized, i, &checkpoints.Finalized)
+					}
+					allFinal = false
+					break
+				}
+			}
+			if allFinal {
+				return finalized, nil
+			}
+		}
+	}
+}
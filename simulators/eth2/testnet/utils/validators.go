@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	blsu "github.com/protolambda/bls12-381-util"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// Validator holds the key material and genesis metadata of a single testnet
+// validator. The keys are insecure and deterministic, they exist only to
+// drive test scenarios.
+type Validator struct {
+	Index  common.ValidatorIndex
+	PubKey common.BLSPubkey
+
+	// SecretKey is the deserialized signing key, ready for use with blsu.
+	SecretKey *blsu.SecretKey
+	// SecretKeyBytes is the big-endian serialization of SecretKey, as needed
+	// for genesis construction and for exporting keys to validator clients.
+	SecretKeyBytes [32]byte
+
+	// Withdrawal key pair, retained so tests can sign credential changes.
+	WithdrawalSecretKey *blsu.SecretKey
+	WithdrawalPubKey    common.BLSPubkey
+
+	// WithdrawalCredentials as registered in the genesis state.
+	WithdrawalCredentials common.Root
+}
+
+// Validators is an ordered set of validators, indexable by validator index
+// and public key.
+type Validators []*Validator
+
+// ByIndex returns the validator with the given index, or nil if the set does
+// not contain it.
+func (vs Validators) ByIndex(index common.ValidatorIndex) *Validator {
+	for _, v := range vs {
+		if v.Index == index {
+			return v
+		}
+	}
+	return nil
+}
+
+// ByPubKey returns the validator with the given public key, or nil if the set
+// does not contain it.
+func (vs Validators) ByPubKey(pub common.BLSPubkey) *Validator {
+	for _, v := range vs {
+		if v.PubKey == pub {
+			return v
+		}
+	}
+	return nil
+}
+
+// Indices returns the validator indices of the set, in set order.
+func (vs Validators) Indices() []common.ValidatorIndex {
+	indices := make([]common.ValidatorIndex, len(vs))
+	for i, v := range vs {
+		indices[i] = v.Index
+	}
+	return indices
+}
+
+// curveOrder is the BLS12-381 curve order, used to reduce interop secret keys.
+var curveOrder, _ = new(big.Int).SetString("52435875175126190479447740508185965837690552500527637822603658699938581184513", 10)
+
+// interopSecretKey derives the insecure interop secret key for the given
+// seed bytes: the sha256 of the seed, interpreted as little-endian, reduced
+// mod the curve order.
+func interopSecretKey(seed []byte) (*blsu.SecretKey, [32]byte, error) {
+	h := sha256.Sum256(seed)
+	// interpret the hash as a little-endian integer
+	for j := 0; j < 16; j++ {
+		h[j], h[31-j] = h[31-j], h[j]
+	}
+	sk := new(big.Int).Mod(new(big.Int).SetBytes(h[:]), curveOrder)
+
+	var skBytes [32]byte
+	sk.FillBytes(skBytes[:])
+	secretKey := new(blsu.SecretKey)
+	if err := secretKey.Deserialize(&skBytes); err != nil {
+		return nil, skBytes, err
+	}
+	return secretKey, skBytes, nil
+}
+
+// GenerateInteropValidators derives n deterministic validators using the
+// insecure interop key derivation: the signing key of validator i is derived
+// from uint_to_bytes(i), and a separate withdrawal key from the signing key.
+// Withdrawal credentials are of the BLS (0x00) type, committing to the
+// withdrawal public key.
+func GenerateInteropValidators(n uint64) (Validators, error) {
+	vs := make(Validators, 0, n)
+	for i := uint64(0); i < n; i++ {
+		var input [32]byte
+		binary.LittleEndian.PutUint64(input[:8], i)
+		secretKey, skBytes, err := interopSecretKey(input[:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive secret key of validator %d: %v", i, err)
+		}
+		pub, err := blsu.SkToPk(secretKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute pubkey of validator %d: %v", i, err)
+		}
+		pubKey := common.BLSPubkey(pub.Serialize())
+
+		withdrawalKey, _, err := interopSecretKey(append(skBytes[:], []byte("withdrawal")...))
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive withdrawal key of validator %d: %v", i, err)
+		}
+		withdrawalPub, err := blsu.SkToPk(withdrawalKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute withdrawal pubkey of validator %d: %v", i, err)
+		}
+		withdrawalPubKey := common.BLSPubkey(withdrawalPub.Serialize())
+
+		withdrawal := common.Root(sha256.Sum256(withdrawalPubKey[:]))
+		withdrawal[0] = common.BLS_WITHDRAWAL_PREFIX
+
+		vs = append(vs, &Validator{
+			Index:                 common.ValidatorIndex(i),
+			PubKey:                pubKey,
+			SecretKey:             secretKey,
+			SecretKeyBytes:        skBytes,
+			WithdrawalSecretKey:   withdrawalKey,
+			WithdrawalPubKey:      withdrawalPubKey,
+			WithdrawalCredentials: withdrawal,
+		})
+	}
+	return vs, nil
+}
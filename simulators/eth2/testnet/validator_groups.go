@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/validatorapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+
+	"github.com/ethereum/hive/simulators/eth2/testnet/utils"
+)
+
+const (
+	// divergenceEpochLookback is how many completed epochs are compared by
+	// ReportValidatorGroupDivergence.
+	divergenceEpochLookback = 3
+	// divergenceSlotReportThreshold is the underperformance percentage above
+	// which the report includes a per-slot missed-duty breakdown.
+	divergenceSlotReportThreshold = 10.0
+	// divergenceSlotReportMax caps the number of slots in the breakdown.
+	divergenceSlotReportMax = 5
+)
+
+// GroupDivergenceReport compares the attestation rewards earned by two named
+// validator groups over a range of recent epochs.
+type GroupDivergenceReport struct {
+	GroupA string
+	GroupB string
+
+	// Per-validator average rewards of each group, over the compared epochs.
+	RewardA SignedGwei
+	RewardB SignedGwei
+
+	// RewardDeltaPercent is how far the worse group's reward is below the
+	// better group's, in percent of the better group's reward.
+	RewardDeltaPercent float64
+	WorseGroup         string
+
+	// MissedDutySlots lists the slots in which the worse group missed the
+	// most attestation duties, worst first. It is only populated when the
+	// divergence exceeds the report threshold.
+	MissedDutySlots []common.Slot
+}
+
+// ReportValidatorGroupDivergence sums the attestation rewards earned by two
+// validator groups over the last few completed epochs and reports how far
+// the worse group lags behind. When the divergence is large, the report also
+// includes the slots in which the worse group missed the most duties, to
+// point at the misbehaving portion of the schedule.
+func (t *Testnet) ReportValidatorGroupDivergence(ctx context.Context, groupA, groupB string) (*GroupDivergenceReport, error) {
+	a, ok := t.ValidatorGroups[groupA]
+	if !ok {
+		return nil, fmt.Errorf("unknown validator group %q", groupA)
+	}
+	b, ok := t.ValidatorGroups[groupB]
+	if !ok {
+		return nil, fmt.Errorf("unknown validator group %q", groupB)
+	}
+	lastCompleted := t.CurrentEpoch()
+	if lastCompleted == 0 {
+		return nil, fmt.Errorf("no completed epochs to compare yet")
+	}
+	lastCompleted -= 1
+	firstEpoch := common.Epoch(0)
+	if lastCompleted >= divergenceEpochLookback {
+		firstEpoch = lastCompleted - divergenceEpochLookback + 1
+	}
+
+	bn := t.roundRobinBeacon()
+	rewardA, missedA, err := t.groupRewards(ctx, bn, *a, firstEpoch, lastCompleted)
+	if err != nil {
+		return nil, err
+	}
+	rewardB, missedB, err := t.groupRewards(ctx, bn, *b, firstEpoch, lastCompleted)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &GroupDivergenceReport{
+		GroupA:  groupA,
+		GroupB:  groupB,
+		RewardA: rewardA,
+		RewardB: rewardB,
+	}
+	worseReward, betterReward, missed := rewardA, rewardB, missedA
+	report.WorseGroup = groupA
+	if rewardB < rewardA {
+		worseReward, betterReward, missed = rewardB, rewardA, missedB
+		report.WorseGroup = groupB
+	}
+	if betterReward > 0 {
+		report.RewardDeltaPercent = float64(betterReward-worseReward) / float64(betterReward) * 100.0
+	}
+	if report.RewardDeltaPercent > divergenceSlotReportThreshold {
+		report.MissedDutySlots = topMissedSlots(missed, divergenceSlotReportMax)
+	}
+	return report, nil
+}
+
+// groupRewards sums the per-validator average attestation rewards of a group
+// over the given epoch range and tallies missed duties per slot. A duty
+// counts as missed when the validator earned no head reward in the epoch.
+func (t *Testnet) groupRewards(ctx context.Context, bn *BeaconNode, group utils.Validators, firstEpoch, lastEpoch common.Epoch) (SignedGwei, map[common.Slot]int, error) {
+	indices := group.Indices()
+	missed := make(map[common.Slot]int)
+	var total SignedGwei
+	for epoch := firstEpoch; epoch <= lastEpoch; epoch++ {
+		rewards, err := attestationRewards(ctx, bn, epoch, indices)
+		if err != nil {
+			return 0, nil, err
+		}
+		var duties eth2api.DependentAttesterDuties
+		if syncing, err := validatorapi.AttesterDuties(ctx, bn.API, epoch, indices, &duties); err != nil {
+			return 0, nil, fmt.Errorf("failed to get attester duties of epoch %d: %w", epoch, err)
+		} else if syncing {
+			return 0, nil, fmt.Errorf("beacon node is syncing, cannot get duties of epoch %d", epoch)
+		}
+		dutySlots := make(map[common.ValidatorIndex]common.Slot, len(duties.Data))
+		for _, duty := range duties.Data {
+			dutySlots[duty.ValidatorIndex] = duty.Slot
+		}
+		for _, reward := range rewards {
+			total += reward.Total()
+			if reward.Head <= 0 {
+				if slot, ok := dutySlots[reward.ValidatorIndex]; ok {
+					missed[slot]++
+				}
+			}
+		}
+	}
+	if len(indices) > 0 {
+		total /= SignedGwei(len(indices))
+	}
+	return total, missed, nil
+}
+
+// topMissedSlots returns the slots with the highest miss counts, worst first.
+func topMissedSlots(missed map[common.Slot]int, max int) []common.Slot {
+	slots := make([]common.Slot, 0, len(missed))
+	for slot := range missed {
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		if missed[slots[i]] != missed[slots[j]] {
+			return missed[slots[i]] > missed[slots[j]]
+		}
+		return slots[i] < slots[j]
+	})
+	if len(slots) > max {
+		slots = slots[:max]
+	}
+	return slots
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/protolambda/eth2api"
+	"github.com/protolambda/eth2api/client/beaconapi"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// VerifyFullWithdrawal checks the exit-to-withdrawal sweep of a fully exited
+// validator end to end: it waits until the validator's withdrawable epoch
+// has passed, watches execution payloads until the sweep includes the full
+// withdrawal, confirms the beacon balance dropped to zero, and checks that
+// the 0x01 execution address holds at least the withdrawn amount. Each stage
+// has its own error so a failure points at where the sweep broke. The sweep
+// watch is bounded by one full sweep cycle: validator set size divided by
+// MAX_WITHDRAWALS_PER_PAYLOAD slots, plus an epoch of slack.
+func (t *Testnet) VerifyFullWithdrawal(ctx context.Context, validatorIndex common.ValidatorIndex) error {
+	bn := t.roundRobinBeacon()
+	timer := time.NewTicker(t.slotDuration())
+	defer timer.Stop()
+
+	// Stage 1: the validator must reach its withdrawable epoch.
+	var withdrawableEpoch common.Epoch
+	for {
+		var v eth2api.ValidatorResponse
+		if exists, err := beaconapi.StateValidator(ctx, bn.API, eth2api.StateHead, eth2api.ValidatorIdIndex(validatorIndex), &v); err != nil {
+			return fmt.Errorf("withdrawable wait: failed to look up validator %d: %w", validatorIndex, err)
+		} else if !exists {
+			return fmt.Errorf("withdrawable wait: validator %d not found", validatorIndex)
+		}
+		withdrawableEpoch = v.Validator.WithdrawableEpoch
+		if withdrawableEpoch == common.FAR_FUTURE_EPOCH {
+			return fmt.Errorf("withdrawable wait: validator %d has not initiated an exit", validatorIndex)
+		}
+		if t.CurrentEpoch() > withdrawableEpoch {
+			break
+		}
+		t.t.Logf("validator %d withdrawable at epoch %d, currently %d", validatorIndex, withdrawableEpoch, t.CurrentEpoch())
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("withdrawable wait: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+
+	// Stage 2: the sweep must include the full withdrawal within one cycle.
+	sweepSlots := common.Slot(uint64(len(t.Validators))/uint64(t.spec.MAX_WITHDRAWALS_PER_PAYLOAD) + uint64(t.spec.SLOTS_PER_EPOCH))
+	deadline := t.CurrentSlot() + sweepSlots
+	var (
+		withdrawal common.Withdrawal
+		lastSeen   common.Slot
+		found      bool
+	)
+	for !found {
+		if t.CurrentSlot() > deadline {
+			return fmt.Errorf("sweep: no withdrawal of validator %d within %d slots", validatorIndex, sweepSlots)
+		}
+		block, exists, err := fetchBlock(ctx, bn, eth2api.BlockHead)
+		if err != nil {
+			return fmt.Errorf("sweep: %w", err)
+		}
+		if exists && block.Slot() != lastSeen {
+			lastSeen = block.Slot()
+			for _, w := range block.Withdrawals() {
+				if w.ValidatorIndex == validatorIndex {
+					withdrawal = w
+					found = true
+					t.t.Logf("validator %d swept in slot %d: %d gwei to %s", validatorIndex, block.Slot(), w.Amount, w.Address.String())
+					break
+				}
+			}
+		}
+		if !found {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("sweep: %w", ctx.Err())
+			case <-timer.C:
+			}
+		}
+	}
+
+	// Stage 3: the beacon balance must be gone.
+	var v eth2api.ValidatorResponse
+	if exists, err := beaconapi.StateValidator(ctx, bn.API, eth2api.StateHead, eth2api.ValidatorIdIndex(validatorIndex), &v); err != nil || !exists {
+		return fmt.Errorf("balance check: failed to look up validator %d: %v", validatorIndex, err)
+	}
+	if v.Balance != 0 {
+		return fmt.Errorf("balance check: validator %d still holds %d gwei after full withdrawal", validatorIndex, v.Balance)
+	}
+
+	// Stage 4: the execution address must have received the funds.
+	address := ethcommon.BytesToAddress(withdrawal.Address[:])
+	balance, err := t.eth1[0].EthClient().BalanceAt(ctx, address, nil)
+	if err != nil {
+		return fmt.Errorf("execution balance check: %w", err)
+	}
+	wantWei := new(big.Int).Mul(big.NewInt(int64(withdrawal.Amount)), big.NewInt(1e9))
+	if balance.Cmp(wantWei) < 0 {
+		return fmt.Errorf("execution balance check: address %s holds %v wei, want at least %v", address, balance, wantWei)
+	}
+	t.t.Logf("full withdrawal of validator %d verified end to end", validatorIndex)
+	return nil
+}
+
+// VerifyWithdrawalRootInclusion cross-checks the withdrawals commitment of
+// every block in the slot range between the two layers: the SSZ root of the
+// withdrawals list in the beacon block's execution payload — the value an
+// ExecutionPayloadHeader carries as withdrawals_root — must equal the SSZ
+// root of the withdrawals the execution client serves for the same block
+// hash. All mismatches in the range are reported, not just the first. Slots
+// without a block or before Capella are skipped.
+func (t *Testnet) VerifyWithdrawalRootInclusion(ctx context.Context, fromSlot, toSlot common.Slot) error {
+	var issues []string
+	checked := 0
+	for slot := fromSlot; slot <= toSlot; slot++ {
+		block, exists, err := fetchBlock(ctx, t.roundRobinBeacon(), eth2api.BlockIdSlot(slot))
+		if err != nil {
+			return err
+		}
+		if !exists || (block.Capella == nil && block.Deneb == nil) {
+			continue
+		}
+		payload := block.executionPayloadEnvelope()
+		clWithdrawals := payload.Withdrawals()
+		clRoot := clWithdrawals.HashTreeRoot(t.spec, tree.GetHashFn())
+
+		// Re-read the same block from the execution layer and root what it
+		// serves as the withdrawals of that block.
+		var elBlock struct {
+			Number      *hexutil.Uint64 `json:"number"`
+			Withdrawals []struct {
+				Index          hexutil.Uint64    `json:"index"`
+				ValidatorIndex hexutil.Uint64    `json:"validatorIndex"`
+				Address        ethcommon.Address `json:"address"`
+				Amount         hexutil.Uint64    `json:"amount"`
+			} `json:"withdrawals"`
+		}
+		node := t.roundRobinEth1()
+		if err := node.RPC().CallContext(ctx, &elBlock, "eth_getBlockByHash", payload.BlockHash(), false); err != nil {
+			return fmt.Errorf("failed to get execution block of slot %d: %w", slot, err)
+		}
+		if elBlock.Number == nil {
+			issues = append(issues, fmt.Sprintf("slot %d: execution client does not know block %s", slot, payload.BlockHash()))
+			continue
+		}
+		elWithdrawals := make(common.Withdrawals, len(elBlock.Withdrawals))
+		for i, w := range elBlock.Withdrawals {
+			elWithdrawals[i] = common.Withdrawal{
+				Index:          common.WithdrawalIndex(w.Index),
+				ValidatorIndex: common.ValidatorIndex(w.ValidatorIndex),
+				Address:        common.Eth1Address(w.Address),
+				Amount:         common.Gwei(w.Amount),
+			}
+		}
+		elRoot := elWithdrawals.HashTreeRoot(t.spec, tree.GetHashFn())
+		if clRoot != elRoot {
+			issues = append(issues, fmt.Sprintf("slot %d: withdrawals root %s in the payload, %s from the execution client (%d vs %d withdrawals)",
+				slot, clRoot, elRoot, len(clWithdrawals), len(elWithdrawals)))
+			continue
+		}
+		checked++
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("withdrawal roots diverge between the layers: %s", strings.Join(issues, "; "))
+	}
+	t.t.Logf("withdrawal roots of %d blocks in slots %d-%d consistent across the layers", checked, fromSlot, toSlot)
+	return nil
+}